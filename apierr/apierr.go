@@ -0,0 +1,163 @@
+// Package apierr is the error envelope shared by every handler. Error responses look like
+//
+//	{
+//	  "error": "Failed to create user: email already exists",
+//	  "code": "conflict",
+//	  "details": [{"field": "email", "code": "taken", "message": "is already in use"}],
+//	  "request_id": "3f0c9a0e-..."
+//	}
+//
+// "error" is a message for people and may change; "code" is stable and meant for programs.
+// "details" is only present when individual fields are at fault.
+package apierr
+
+import (
+	"log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Stable error codes
+const (
+	CodeInvalidRequest         = "invalid_request"           // 400: malformed body, query or parameter
+	CodeValidationFailed       = "validation_failed"         // 400: body fields broke their rules, see details
+	CodeUnauthorized           = "unauthorized"              // 401: missing or invalid credentials
+	CodeForbidden              = "forbidden"                 // 403: authenticated but not allowed
+	CodePermissionDenied       = "permission_denied"         // 403: a specific permission is missing
+	CodeCSRFFailed             = "csrf_failed"               // 403: missing CSRF token or cross-origin request
+	CodeEmailNotVerified       = "email_not_verified"        // 403: login before verifying the email address
+	CodePasswordChangeRequired = "password_change_required"  // 403: the password must be changed first
+	CodeTwoFactorSetupRequired = "two_factor_setup_required" // 403: two-factor authentication must be set up first
+	CodeNotFound               = "not_found"                 // 404
+	CodeConflict               = "conflict"                  // 409: duplicate or still referenced data
+	CodeInvalidReference       = "invalid_reference"         // 422: refers to something that does not exist
+	CodeMissingValue           = "missing_value"             // 422: a value the record needs is missing
+	CodeInvalidValue           = "invalid_value"             // 422: a value the database cannot store
+	CodeRateLimited            = "rate_limited"              // 429
+	CodeInternal               = "internal_error"            // 500
+	CodeUpstreamUnavailable    = "upstream_unavailable"      // 502: a service we depend on failed
+)
+
+// FieldError is a problem with one field of the request
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error is an error response
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	Details []FieldError
+	// Extra holds additional top-level members such as "retry_after". Flags like
+	// "csrf_failed" predate codes and are kept for existing clients.
+	Extra map[string]interface{}
+	// cause is logged, never sent
+	cause error
+}
+
+// New returns an error response
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// With adds a top-level member to the response body
+func (e *Error) With(key string, value interface{}) *Error {
+	if e.Extra == nil {
+		e.Extra = map[string]interface{}{}
+	}
+	e.Extra[key] = value
+	return e
+}
+
+// WithDetails adds field-level details
+func (e *Error) WithDetails(details ...FieldError) *Error {
+	e.Details = append(e.Details, details...)
+	return e
+}
+
+// Respond writes e and aborts the remaining handlers. Server errors are logged with their
+// cause and the request ID.
+func Respond(c *gin.Context, e *Error) {
+	requestID := c.GetString("request_id")
+	if e.Status >= 500 {
+		if e.cause != nil {
+			log.Printf("[%s] %s %s: %s: %v", requestID, c.Request.Method, c.Request.URL.Path, e.Message, e.cause)
+		} else {
+			log.Printf("[%s] %s %s: %s", requestID, c.Request.Method, c.Request.URL.Path, e.Message)
+		}
+	}
+
+	body := gin.H{}
+	for key, value := range e.Extra {
+		body[key] = value
+	}
+	body["error"] = e.Message
+	body["code"] = e.Code
+	if len(e.Details) > 0 {
+		body["details"] = e.Details
+	}
+	if requestID != "" {
+		body["request_id"] = requestID
+	}
+	c.AbortWithStatusJSON(e.Status, body)
+}
+
+// BadRequest answers 400 invalid_request
+func BadRequest(c *gin.Context, message string) {
+	Respond(c, New(400, CodeInvalidRequest, message))
+}
+
+// Unauthorized answers 401 unauthorized
+func Unauthorized(c *gin.Context, message string) {
+	Respond(c, New(401, CodeUnauthorized, message))
+}
+
+// Forbidden answers 403 forbidden
+func Forbidden(c *gin.Context, message string) {
+	Respond(c, New(403, CodeForbidden, message))
+}
+
+// MissingPermission answers 403 permission_denied naming the permission
+func MissingPermission(c *gin.Context, permission string) {
+	Respond(c, New(403, CodePermissionDenied, "Missing permission: "+permission).With("permission", permission))
+}
+
+// NotFound answers 404 not_found
+func NotFound(c *gin.Context, message string) {
+	Respond(c, New(404, CodeNotFound, message))
+}
+
+// Conflict answers 409 conflict
+func Conflict(c *gin.Context, message string) {
+	Respond(c, New(409, CodeConflict, message))
+}
+
+// TooManyRequests answers 429 rate_limited
+func TooManyRequests(c *gin.Context, message string) {
+	Respond(c, New(429, CodeRateLimited, message))
+}
+
+// Internal answers 500 internal_error without a known cause
+func Internal(c *gin.Context, message string) {
+	Respond(c, New(500, CodeInternal, message))
+}
+
+// Fail answers an operation that failed with err. Database errors the client can fix
+// (duplicates, unknown references, missing values) are translated by FromDB; anything else
+// is logged and answered with a generic 500 that does not reveal err.
+func Fail(c *gin.Context, message string, err error) {
+	if e := FromDB(message, err); e != nil {
+		Respond(c, e)
+		return
+	}
+	e := New(500, CodeInternal, message)
+	e.cause = err
+	Respond(c, e)
+}
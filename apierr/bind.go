@@ -0,0 +1,138 @@
+package apierr
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// UseJSONFieldNames makes validation errors name fields as they are spelled in JSON
+// ("first_name" rather than "FirstName"). Call it once at startup, before any request is bound.
+func UseJSONFieldNames() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+}
+
+// Bind answers a failed bind with the error FromBind makes of it
+func Bind(c *gin.Context, err error, message string) {
+	Respond(c, FromBind(message, err))
+}
+
+// FromBind translates a failed bind into an error response. Rule violations and values of
+// the wrong JSON type (err may join both) give 400 validation_failed listing every
+// offending field; bodies that cannot be decoded at all give 400 invalid_request.
+// message describes what the endpoint expects.
+func FromBind(message string, err error) *Error {
+	var typeErr *json.UnmarshalTypeError
+	var violations validator.ValidationErrors
+	hasTypeErr := errors.As(err, &typeErr)
+	hasViolations := errors.As(err, &violations)
+	if !hasTypeErr && !hasViolations {
+		return New(400, CodeInvalidRequest, message)
+	}
+
+	e := New(400, CodeValidationFailed, message)
+	if hasTypeErr {
+		e.WithDetails(FieldError{Field: typeErr.Field, Code: "type", Message: "must be " + describeType(typeErr.Type)})
+	}
+	for _, violation := range violations {
+		if hasTypeErr && fieldPath(violation) == typeErr.Field {
+			continue // Left empty by the type error, already reported
+		}
+		e.WithDetails(FieldError{
+			Field:   fieldPath(violation),
+			Code:    violation.Tag(),
+			Message: describeRule(violation),
+		})
+	}
+	return e
+}
+
+// fieldPath is the field's path below the request type, e.g. "items[0].quantity"
+func fieldPath(violation validator.FieldError) string {
+	namespace := violation.Namespace()
+	if dot := strings.IndexByte(namespace, '.'); dot >= 0 {
+		return namespace[dot+1:]
+	}
+	return namespace
+}
+
+// describeRule explains a failed validator rule
+func describeRule(violation validator.FieldError) string {
+	switch violation.Tag() {
+	case "required":
+		return "is required"
+	case "notblank":
+		return "must not be blank"
+	case "email":
+		return "must be a valid email address"
+	case "url", "http_url":
+		return "must be a valid URL"
+	case "date":
+		return "must be a date (YYYY-MM-DD)"
+	case "clock":
+		return "must be a time of day (HH:MM)"
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(violation.Param(), " ", ", ")
+	case "min", "gte":
+		if unit := lengthUnit(violation.Kind()); unit != "" {
+			return "must have at least " + violation.Param() + " " + unit
+		}
+		return "must be at least " + violation.Param()
+	case "max", "lte":
+		if unit := lengthUnit(violation.Kind()); unit != "" {
+			return "must have at most " + violation.Param() + " " + unit
+		}
+		return "must be at most " + violation.Param()
+	case "gt":
+		return "must be greater than " + violation.Param()
+	case "lt":
+		return "must be less than " + violation.Param()
+	}
+	return "failed the " + violation.Tag() + " rule"
+}
+
+// lengthUnit is what min and max count for kinds measured by length
+func lengthUnit(kind reflect.Kind) string {
+	switch kind {
+	case reflect.String:
+		return "characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return "items"
+	}
+	return ""
+}
+
+// describeType names the JSON type a Go type is decoded from
+func describeType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	}
+	return "a " + t.Kind().String()
+}
@@ -0,0 +1,90 @@
+package apierr
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// keyColumns matches the columns in a pq error detail such as
+// `Key (email)=(a@b.c) already exists.` or `Key (room_id)=(x) is not present in table "rooms".`
+var keyColumns = regexp.MustCompile(`^Key \(([\w, ]+)\)=`)
+
+// referencingTable matches the table in `... is still referenced from table "mixes".`
+var referencingTable = regexp.MustCompile(`still referenced from table "([^"]+)"`)
+
+// FromDB translates the Postgres errors a client can fix into error responses, prefixing
+// their message with message ("Failed to create user: email already exists"):
+//
+//   - unique violation: 409 conflict
+//   - foreign key violation: 422 invalid_reference, or 409 conflict when deleting a row
+//     other rows still refer to
+//   - not-null violation: 422 missing_value
+//   - check violations and invalid input (bad dates, numbers out of range): 422 invalid_value
+//
+// It returns nil for any other error.
+func FromDB(message string, err error) *Error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return nil
+	}
+
+	columns := constraintColumns(pqErr)
+	switch {
+	case pqErr.Code == "23505": // unique_violation
+		e := New(409, CodeConflict, message+": "+describe(columns, "value")+" already exists")
+		for _, column := range columns {
+			e.WithDetails(FieldError{Field: column, Code: "taken", Message: "is already in use"})
+		}
+		return e
+
+	case pqErr.Code == "23503": // foreign_key_violation
+		if match := referencingTable.FindStringSubmatch(pqErr.Detail); match != nil {
+			return New(409, CodeConflict, message+": still referenced by "+match[1])
+		}
+		e := New(422, CodeInvalidReference, message+": "+describe(columns, "a referenced record")+" does not exist")
+		for _, column := range columns {
+			e.WithDetails(FieldError{Field: column, Code: "not_found", Message: "refers to a record that does not exist"})
+		}
+		return e
+
+	case pqErr.Code == "23502": // not_null_violation
+		e := New(422, CodeMissingValue, message+": "+describe([]string{pqErr.Column}, "a value")+" is required")
+		if pqErr.Column != "" {
+			e.WithDetails(FieldError{Field: pqErr.Column, Code: "required", Message: "is required"})
+		}
+		return e
+
+	case pqErr.Code == "23514" || pqErr.Code.Class() == "22": // check_violation, data_exception
+		e := New(422, CodeInvalidValue, message+": "+pqErr.Message)
+		if pqErr.Column != "" {
+			e.WithDetails(FieldError{Field: pqErr.Column, Code: "invalid", Message: pqErr.Message})
+		}
+		return e
+	}
+	return nil
+}
+
+// constraintColumns returns the columns named in the error's detail. Indexes on
+// expressions have no plain column names and give none.
+func constraintColumns(pqErr *pq.Error) []string {
+	match := keyColumns.FindStringSubmatch(pqErr.Detail)
+	if match == nil {
+		return nil
+	}
+	var columns []string
+	for _, column := range strings.Split(match[1], ",") {
+		columns = append(columns, strings.TrimSpace(column))
+	}
+	return columns
+}
+
+// describe joins column names for a message, or returns fallback when there are none
+func describe(columns []string, fallback string) string {
+	if len(columns) == 0 || columns[0] == "" {
+		return fallback
+	}
+	return strings.Join(columns, ", ")
+}
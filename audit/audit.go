@@ -0,0 +1,136 @@
+package audit
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"playtz-api/database"
+	"reflect"
+
+	"github.com/google/uuid"
+)
+
+// Entry is one audited request
+type Entry struct {
+	ActorID      string // User ID or API key ID
+	ActorType    string // "user" or "api_key"
+	ActorName    string // Username or API key name
+	Action       string // e.g. "news.delete", "orders.status.update"
+	Method       string
+	Route        string // Route pattern, e.g. /api/v1/orders/:id/status
+	ResourceType string
+	ResourceID   string
+	StatusCode   int
+	Before       map[string]interface{} // Changed fields before the request (nil for creates)
+	After        map[string]interface{} // Changed fields after the request (nil for deletes)
+	IP           string
+	UserAgent    string
+}
+
+// resourceTables maps the first path segment under /api/v1 to the table holding the resource.
+// Only these tables are ever snapshotted.
+var resourceTables = map[string]string{
+	"news":     "news",
+	"events":   "events",
+	"merch":    "merchandise",
+	"careers":  "careers",
+	"rooms":    "rooms",
+	"mixes":    "mixes",
+	"users":    "users",
+	"roles":    "roles",
+	"orders":   "orders",
+	"api-keys": "api_keys",
+}
+
+// redactedColumns never appear in the audit log
+var redactedColumns = []string{"password_hash", "totp_secret", "totp_last_step", "key_hash"}
+
+// TableFor returns the table behind a resource path segment ("" if it is not snapshotted)
+func TableFor(resourceType string) string {
+	return resourceTables[resourceType]
+}
+
+// Snapshot returns a resource row as JSON fields with secrets removed, or nil if it does not exist
+func Snapshot(resourceType, id string) map[string]interface{} {
+	table := TableFor(resourceType)
+	if table == "" || id == "" {
+		return nil
+	}
+
+	var raw []byte
+	// table comes from resourceTables, never from the request
+	err := database.DB.QueryRow("SELECT row_to_json(t) FROM "+table+" t WHERE id = $1", id).Scan(&raw)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("Failed to snapshot %s %s for audit: %v", table, id, err)
+		}
+		return nil
+	}
+
+	var row map[string]interface{}
+	if err := json.Unmarshal(raw, &row); err != nil {
+		return nil
+	}
+	for _, column := range redactedColumns {
+		delete(row, column)
+	}
+	return row
+}
+
+// Diff reduces two snapshots to the fields that differ. updated_at is ignored.
+func Diff(before, after map[string]interface{}) (map[string]interface{}, map[string]interface{}) {
+	if before == nil || after == nil {
+		return before, after
+	}
+
+	changedBefore := map[string]interface{}{}
+	changedAfter := map[string]interface{}{}
+	for key, value := range after {
+		if key != "updated_at" && !reflect.DeepEqual(before[key], value) {
+			changedBefore[key] = before[key]
+			changedAfter[key] = value
+		}
+	}
+	for key, value := range before {
+		if _, exists := after[key]; !exists {
+			changedBefore[key] = value
+		}
+	}
+	return changedBefore, changedAfter
+}
+
+// Record stores an audit entry. Failures are logged and never fail the request.
+func Record(entry Entry) {
+	before, err := marshalNullable(entry.Before)
+	if err != nil {
+		log.Printf("Failed to encode audit entry: %v", err)
+		return
+	}
+	after, err := marshalNullable(entry.After)
+	if err != nil {
+		log.Printf("Failed to encode audit entry: %v", err)
+		return
+	}
+
+	_, err = database.DB.Exec(`
+		INSERT INTO audit_logs (id, actor_id, actor_type, actor_name, action, method, route,
+		                        resource_type, resource_id, status_code, before, after, ip, user_agent)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+	`, uuid.New().String(), entry.ActorID, entry.ActorType, entry.ActorName, entry.Action, entry.Method, entry.Route,
+		entry.ResourceType, entry.ResourceID, entry.StatusCode, before, after, entry.IP, entry.UserAgent)
+	if err != nil {
+		log.Printf("Failed to record audit entry %s: %v", entry.Action, err)
+	}
+}
+
+// marshalNullable encodes a snapshot, keeping nil as SQL NULL
+func marshalNullable(fields map[string]interface{}) (interface{}, error) {
+	if fields == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,61 @@
+package audit
+
+import (
+	"log"
+	"os"
+	"playtz-api/database"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultRetentionDays is how long audit entries are kept when AUDIT_RETENTION_DAYS is not set
+const DefaultRetentionDays = 365
+
+var retentionOnce sync.Once
+
+// RetentionDays returns AUDIT_RETENTION_DAYS (default 365). 0 keeps entries forever.
+func RetentionDays() int {
+	days, err := strconv.Atoi(os.Getenv("AUDIT_RETENTION_DAYS"))
+	if err != nil || days < 0 {
+		return DefaultRetentionDays
+	}
+	return days
+}
+
+// StartRetention periodically deletes audit entries older than the retention period.
+// Safe to call more than once; only one goroutine is started.
+func StartRetention() {
+	retentionOnce.Do(func() {
+		days := RetentionDays()
+		if days == 0 {
+			log.Println("Audit log retention disabled - entries are kept forever")
+			return
+		}
+
+		go func() {
+			ticker := time.NewTicker(6 * time.Hour)
+			defer ticker.Stop()
+
+			for {
+				pruneAuditLogs(days)
+				<-ticker.C
+			}
+		}()
+	})
+}
+
+// pruneAuditLogs deletes entries older than the given number of days
+func pruneAuditLogs(days int) {
+	result, err := database.DB.Exec(
+		"DELETE FROM audit_logs WHERE created_at < CURRENT_TIMESTAMP - make_interval(days => $1)",
+		days,
+	)
+	if err != nil {
+		log.Printf("Failed to prune audit logs: %v", err)
+		return
+	}
+	if deleted, _ := result.RowsAffected(); deleted > 0 {
+		log.Printf("Pruned %d audit log entries older than %d days", deleted, days)
+	}
+}
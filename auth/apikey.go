@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"playtz-api/database"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// apiKeyTag starts every API key so leaked keys are easy to recognise
+const apiKeyTag = "ptz"
+
+// ErrAPIKeyInvalid is returned for unknown, malformed, revoked or expired keys
+var ErrAPIKeyInvalid = errors.New("invalid API key")
+
+// APIKey is an authenticated machine client
+type APIKey struct {
+	ID      string
+	Name    string
+	Scopes  []string
+	OwnerID string // User who created the key ("" once that user is deleted)
+}
+
+// GenerateAPIKey creates a new key of the form ptz_<prefix>_<secret>.
+// The prefix is stored in clear to find the key; only the SHA-256 of the whole key is stored.
+func GenerateAPIKey() (key, prefix string, err error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	prefix = hex.EncodeToString(b)
+
+	secret, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	return apiKeyTag + "_" + prefix + "_" + secret, prefix, nil
+}
+
+// ValidateAPIKey checks a presented key and records when it was last used
+func ValidateAPIKey(key string) (*APIKey, error) {
+	parts := strings.SplitN(key, "_", 3)
+	if len(parts) != 3 || parts[0] != apiKeyTag || parts[1] == "" || parts[2] == "" {
+		return nil, ErrAPIKeyInvalid
+	}
+
+	var apiKey APIKey
+	var keyHash string
+	var scopes pq.StringArray
+	var expiresAt, revokedAt sql.NullTime
+	err := database.DB.QueryRow(
+		"SELECT id, name, key_hash, scopes, expires_at, revoked_at, COALESCE(created_by, '') FROM api_keys WHERE prefix = $1",
+		parts[1],
+	).Scan(&apiKey.ID, &apiKey.Name, &keyHash, &scopes, &expiresAt, &revokedAt, &apiKey.OwnerID)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrAPIKeyInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(keyHash), []byte(HashToken(key))) != 1 {
+		return nil, ErrAPIKeyInvalid
+	}
+	if revokedAt.Valid || (expiresAt.Valid && time.Now().After(expiresAt.Time)) {
+		return nil, ErrAPIKeyInvalid
+	}
+
+	// Only write once a minute so busy clients don't turn every request into an UPDATE
+	database.DB.Exec(
+		"UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < CURRENT_TIMESTAMP - INTERVAL '1 minute')",
+		apiKey.ID,
+	)
+
+	apiKey.Scopes = []string(scopes)
+	return &apiKey, nil
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"playtz-api/database"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EmailVerificationTTL is how long an emailed verification link stays valid
+const EmailVerificationTTL = 24 * time.Hour
+
+// ErrVerificationTokenInvalid is returned for unknown, used or expired verification tokens
+var ErrVerificationTokenInvalid = errors.New("invalid or expired verification token")
+
+// CreateEmailVerificationToken issues a single-use email verification token for a user.
+// Any earlier unused token of the user is invalidated.
+func CreateEmailVerificationToken(userID string) (string, error) {
+	token, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		"UPDATE email_verification_tokens SET used_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND used_at IS NULL",
+		userID,
+	); err != nil {
+		return "", err
+	}
+
+	if _, err := tx.Exec(
+		"INSERT INTO email_verification_tokens (id, user_id, token_hash, expires_at) VALUES ($1, $2, $3, $4)",
+		uuid.New().String(), userID, HashToken(token), time.Now().Add(EmailVerificationTTL),
+	); err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// ConsumeEmailVerificationToken marks a verification token as used and returns its user ID
+func ConsumeEmailVerificationToken(token string) (string, error) {
+	var userID string
+	err := database.DB.QueryRow(`
+		UPDATE email_verification_tokens SET used_at = CURRENT_TIMESTAMP
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		RETURNING user_id
+	`, HashToken(token)).Scan(&userID)
+
+	if err == sql.ErrNoRows {
+		return "", ErrVerificationTokenInvalid
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return userID, nil
+}
@@ -6,10 +6,24 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 var jwtSecret []byte
 
+// AccessTokenTTL is the lifetime of access tokens; clients renew them via /auth/refresh
+const AccessTokenTTL = 15 * time.Minute
+
+// ChallengeTokenTTL is how long a user has to complete a two-factor login
+const ChallengeTokenTTL = 5 * time.Minute
+
+const (
+	tokenIssuer = "playtz-api"
+	// Access and challenge tokens use different audiences so one can never stand in for the other
+	accessAudience    = "playtz-api"
+	challengeAudience = "playtz-api:2fa"
+)
+
 // JWTClaims represents the JWT token claims
 type JWTClaims struct {
 	UserID   string `json:"user_id"`
@@ -17,16 +31,30 @@ type JWTClaims struct {
 	Email    string `json:"email"`
 	RoleID   string `json:"role_id"`
 	RoleName string `json:"role_name"`
+	// AccountType is "staff" or "listener"; tokens without it belong to staff
+	AccountType string `json:"account_type,omitempty"`
+	// SessionID is the login session the token belongs to; ending the session revokes the token
+	SessionID string `json:"sid,omitempty"`
+	// TwoFactorSetupRequired restricts the token to 2FA enrollment (role requires 2FA, user not enrolled)
+	TwoFactorSetupRequired bool `json:"mfa_setup_required,omitempty"`
+	// PasswordChangeRequired restricts the token to changing the password (admin-created accounts)
+	PasswordChangeRequired bool `json:"pwd_change_required,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// GetJWTSecret returns the JWT secret key
+// ChallengeClaims represents a pending two-factor login
+type ChallengeClaims struct {
+	UserID string `json:"user_id"`
+	jwt.RegisteredClaims
+}
+
+// GetJWTSecret returns the HS256 secret (JWT_SECRET)
 func GetJWTSecret() []byte {
 	if len(jwtSecret) == 0 {
 		secret := os.Getenv("JWT_SECRET")
 		if secret == "" {
-			// Default secret for development (should be changed in production)
-			secret = "your-secret-key-change-in-production"
+			// Default secret for development - refused in production by CheckSigningConfig
+			secret = defaultJWTSecret
 		}
 		jwtSecret = []byte(secret)
 	}
@@ -35,24 +63,29 @@ func GetJWTSecret() []byte {
 
 // GenerateToken generates a JWT token for a user
 func GenerateToken(userID, username, email, roleID, roleName string) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour) // Token expires in 24 hours
-
-	claims := &JWTClaims{
+	return GenerateTokenWithClaims(&JWTClaims{
 		UserID:   userID,
 		Username: username,
 		Email:    email,
 		RoleID:   roleID,
 		RoleName: roleName,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			Issuer:    "playtz-api",
-			Subject:   userID,
-		},
+	})
+}
+
+// GenerateTokenWithClaims signs an access token for the given user claims.
+// Registered claims (jti, expiry, issuer...) are filled in here.
+func GenerateTokenWithClaims(claims *JWTClaims) (string, error) {
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        uuid.New().String(), // jti - lets a single token be revoked
+		ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL)),
+		IssuedAt:  jwt.NewNumericDate(now),
+		Issuer:    tokenIssuer,
+		Subject:   claims.UserID,
+		Audience:  jwt.ClaimStrings{accessAudience},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(GetJWTSecret())
+	tokenString, err := signToken(claims)
 	if err != nil {
 		return "", err
 	}
@@ -64,14 +97,41 @@ func GenerateToken(userID, username, email, roleID, roleName string) (string, er
 func ValidateToken(tokenString string) (*JWTClaims, error) {
 	claims := &JWTClaims{}
 
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		// Validate signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("invalid signing method")
-		}
-		return GetJWTSecret(), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, claims, keyFunc, jwt.WithAudience(accessAudience))
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
 
+	return claims, nil
+}
+
+// GenerateChallengeToken issues a short-lived token proving the password step of a 2FA login
+func GenerateChallengeToken(userID string) (string, error) {
+	now := time.Now()
+	claims := &ChallengeClaims{
+		UserID: userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ChallengeTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    tokenIssuer,
+			Subject:   userID,
+			Audience:  jwt.ClaimStrings{challengeAudience},
+		},
+	}
+
+	return signToken(claims)
+}
+
+// ValidateChallengeToken validates a two-factor challenge token
+func ValidateChallengeToken(tokenString string) (*ChallengeClaims, error) {
+	claims := &ChallengeClaims{}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, keyFunc, jwt.WithAudience(challengeAudience))
 	if err != nil {
 		return nil, err
 	}
@@ -90,4 +150,3 @@ func ExtractTokenFromHeader(authHeader string) string {
 	}
 	return ""
 }
-
@@ -0,0 +1,293 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// defaultJWTSecret is the development fallback for HS256 signing
+const defaultJWTSecret = "your-secret-key-change-in-production"
+
+// hmacKeyID identifies tokens signed with JWT_SECRET
+const hmacKeyID = "hs256"
+
+// signingKey is a key the server signs or verifies tokens with
+type signingKey struct {
+	id      string
+	method  jwt.SigningMethod
+	private interface{} // nil for verification-only keys
+	public  interface{} // Public key, or the secret for HMAC
+}
+
+// keySet holds the current signing key and every key accepted for verification
+type keySet struct {
+	signing *signingKey
+	verify  map[string]*signingKey
+}
+
+var keys *keySet
+var keysErr error
+var keysOnce sync.Once
+
+// loadKeySet builds the key set from the environment once.
+//
+// JWT_SIGNING_KEY (PEM) or JWT_SIGNING_KEY_FILE selects an Ed25519 (EdDSA) or RSA (RS256) signing key.
+// JWT_VERIFICATION_KEYS holds extra PEM public keys still accepted during a rotation.
+// Without a signing key, tokens are signed with HS256 and JWT_SECRET. With one, HS256 tokens
+// are only still accepted when JWT_ACCEPT_HS256=true.
+func loadKeySet() (*keySet, error) {
+	keysOnce.Do(func() {
+		keys, keysErr = buildKeySet()
+	})
+	return keys, keysErr
+}
+
+func buildKeySet() (*keySet, error) {
+	set := &keySet{verify: make(map[string]*signingKey)}
+
+	signingPEM := pemFromEnv(os.Getenv("JWT_SIGNING_KEY"))
+	if path := os.Getenv("JWT_SIGNING_KEY_FILE"); signingPEM == "" && path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWT_SIGNING_KEY_FILE: %w", err)
+		}
+		signingPEM = string(data)
+	}
+
+	if signingPEM != "" {
+		key, err := parsePrivateKey(signingPEM)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWT signing key: %w", err)
+		}
+		set.signing = key
+		set.verify[key.id] = key
+	}
+
+	rest := []byte(pemFromEnv(os.Getenv("JWT_VERIFICATION_KEYS")))
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		key, err := parsePublicKey(block)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWT verification key: %w", err)
+		}
+		set.verify[key.id] = key
+	}
+
+	// HS256 signs when no asymmetric key is configured. After a switch to asymmetric keys it
+	// keeps verifying only on request, so existing sessions can be kept until they expire
+	if set.signing == nil || envBool("JWT_ACCEPT_HS256", false) {
+		hmacKey := &signingKey{
+			id:      hmacKeyID,
+			method:  jwt.SigningMethodHS256,
+			private: GetJWTSecret(),
+			public:  GetJWTSecret(),
+		}
+		set.verify[hmacKeyID] = hmacKey
+		if set.signing == nil {
+			set.signing = hmacKey
+		}
+	}
+
+	return set, nil
+}
+
+// CheckSigningConfig loads the signing keys and reports configuration problems.
+// In production (APP_ENV=production or GIN_MODE=release) the default HS256 secret is refused,
+// whether it signs tokens or is only still accepted for verification.
+func CheckSigningConfig() error {
+	set, err := loadKeySet()
+	if err != nil {
+		return err
+	}
+
+	if _, acceptsHMAC := set.verify[hmacKeyID]; isProduction() && acceptsHMAC && string(GetJWTSecret()) == defaultJWTSecret {
+		if set.signing.id == hmacKeyID {
+			return errors.New("JWT_SECRET is not set and no JWT_SIGNING_KEY is configured - refusing to use the default secret in production")
+		}
+		return errors.New("JWT_ACCEPT_HS256 is set but JWT_SECRET is not - refusing to accept tokens signed with the default secret in production")
+	}
+	return nil
+}
+
+// isProduction reports whether the server runs in production mode
+func isProduction() bool {
+	return strings.ToLower(os.Getenv("APP_ENV")) == "production" || os.Getenv("GIN_MODE") == "release"
+}
+
+// signToken signs claims with the current signing key and sets the kid header
+func signToken(claims jwt.Claims) (string, error) {
+	set, err := loadKeySet()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(set.signing.method, claims)
+	token.Header["kid"] = set.signing.id
+	return token.SignedString(set.signing.private)
+}
+
+// keyFunc returns the verification key for a token, chosen by its kid header
+func keyFunc(token *jwt.Token) (interface{}, error) {
+	set, err := loadKeySet()
+	if err != nil {
+		return nil, err
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		// Tokens issued before key IDs were introduced are HS256
+		kid = hmacKeyID
+	}
+
+	key, ok := set.verify[kid]
+	if !ok {
+		return nil, errors.New("unknown signing key")
+	}
+	// The algorithm is fixed by the key, never by the token
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, errors.New("invalid signing method")
+	}
+	return key.public, nil
+}
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"` // OKP
+	X   string `json:"x,omitempty"`   // OKP
+	N   string `json:"n,omitempty"`   // RSA
+	E   string `json:"e,omitempty"`   // RSA
+}
+
+// JWKS returns the public verification keys for /.well-known/jwks.json.
+// HMAC secrets are never published.
+func JWKS() ([]JWK, error) {
+	set, err := loadKeySet()
+	if err != nil {
+		return nil, err
+	}
+
+	jwks := []JWK{}
+	for _, key := range set.verify {
+		if jwk, ok := toJWK(key.public); ok {
+			jwk.Kid = key.id
+			jwks = append(jwks, jwk)
+		}
+	}
+	sort.Slice(jwks, func(i, j int) bool { return jwks[i].Kid < jwks[j].Kid })
+	return jwks, nil
+}
+
+// toJWK converts a public key to a JWK (kid is filled in by the caller)
+func toJWK(public interface{}) (JWK, bool) {
+	encode := base64.RawURLEncoding.EncodeToString
+	switch key := public.(type) {
+	case ed25519.PublicKey:
+		return JWK{Kty: "OKP", Use: "sig", Alg: "EdDSA", Crv: "Ed25519", X: encode(key)}, true
+	case *rsa.PublicKey:
+		return JWK{Kty: "RSA", Use: "sig", Alg: "RS256", N: encode(key.N.Bytes()), E: encode(big.NewInt(int64(key.E)).Bytes())}, true
+	}
+	return JWK{}, false
+}
+
+// keyID derives a stable kid from a public key (RFC 7638 thumbprint), so a key
+// keeps its ID when it moves from signing to verification-only during a rotation
+func keyID(public interface{}) string {
+	jwk, _ := toJWK(public)
+
+	// Members in lexicographic order, as required for the thumbprint
+	var members interface{}
+	if jwk.Kty == "OKP" {
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	} else {
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	}
+
+	data, _ := json.Marshal(members)
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// parsePrivateKey parses a PEM Ed25519 (PKCS#8) or RSA (PKCS#1/PKCS#8) private key
+func parsePrivateKey(data string) (*signingKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	var err error
+	if block.Type == "RSA PRIVATE KEY" {
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	} else {
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch key := parsed.(type) {
+	case ed25519.PrivateKey:
+		public := key.Public().(ed25519.PublicKey)
+		return &signingKey{id: keyID(public), method: jwt.SigningMethodEdDSA, private: key, public: public}, nil
+	case *rsa.PrivateKey:
+		return &signingKey{id: keyID(&key.PublicKey), method: jwt.SigningMethodRS256, private: key, public: &key.PublicKey}, nil
+	}
+	return nil, errors.New("unsupported key type (use Ed25519 or RSA)")
+}
+
+// parsePublicKey parses a PEM Ed25519 or RSA public key
+func parsePublicKey(block *pem.Block) (*signingKey, error) {
+	var parsed interface{}
+	var err error
+	if block.Type == "RSA PUBLIC KEY" {
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	} else {
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch key := parsed.(type) {
+	case ed25519.PublicKey:
+		return &signingKey{id: keyID(key), method: jwt.SigningMethodEdDSA, public: key}, nil
+	case *rsa.PublicKey:
+		return &signingKey{id: keyID(key), method: jwt.SigningMethodRS256, public: key}, nil
+	}
+	return nil, errors.New("unsupported key type (use Ed25519 or RSA)")
+}
+
+// pemFromEnv allows PEM values stored on one line with literal \n separators
+func pemFromEnv(value string) string {
+	return strings.ReplaceAll(value, `\n`, "\n")
+}
@@ -0,0 +1,214 @@
+package auth
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AttemptRecord tracks failed logins for one key (an account or a client IP)
+type AttemptRecord struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time // No attempt is accepted before this time
+}
+
+// LimiterStore persists failed login attempts
+type LimiterStore interface {
+	Get(key string) (AttemptRecord, error)
+	// Increment counts a failure at now in one atomic step, starting the count over when
+	// the previous failure is older than window, and returns the updated record. Concurrent
+	// failures must each be counted.
+	Increment(key string, now time.Time, window time.Duration) (AttemptRecord, error)
+	// Block makes key wait until the given time, never shortening a longer wait
+	Block(key string, until time.Time) error
+	Delete(key string) error
+}
+
+// LoginLimiter applies exponential backoff and temporary lockout to failed logins
+type LoginLimiter struct {
+	store LimiterStore
+
+	MaxAccountFailures int           // Failures before an account is locked
+	MaxIPFailures      int           // Failures before a client IP is locked; 0 turns per-IP limits off
+	LockoutDuration    time.Duration // How long a lockout lasts
+	FreeAttempts       int           // Failures allowed before backoff starts
+	BaseDelay          time.Duration // First backoff delay, doubled on every failure
+	MaxDelay           time.Duration // Backoff cap
+	Window             time.Duration // Failures older than this are forgotten
+}
+
+var limiter *LoginLimiter
+var limiterOnce sync.Once
+
+// GetLoginLimiter returns the singleton login limiter.
+// LOGIN_LIMITER_STORE=memory keeps attempts in process; the default stores them in Postgres
+// so every instance sees the same counters.
+func GetLoginLimiter() *LoginLimiter {
+	limiterOnce.Do(func() {
+		var store LimiterStore
+		if strings.ToLower(os.Getenv("LOGIN_LIMITER_STORE")) == "memory" {
+			store = NewMemoryLimiterStore()
+		} else {
+			store = NewPostgresLimiterStore()
+		}
+
+		limiter = NewLoginLimiter(store)
+		limiter.MaxAccountFailures = envInt("LOGIN_MAX_FAILURES", limiter.MaxAccountFailures)
+		limiter.MaxIPFailures = envInt("LOGIN_IP_MAX_FAILURES", limiter.MaxIPFailures)
+		limiter.LockoutDuration = time.Duration(envInt("LOGIN_LOCKOUT_MINUTES", int(limiter.LockoutDuration.Minutes()))) * time.Minute
+	})
+	return limiter
+}
+
+// NewLoginLimiter creates a limiter with default settings
+func NewLoginLimiter(store LimiterStore) *LoginLimiter {
+	return &LoginLimiter{
+		store:              store,
+		MaxAccountFailures: 10,
+		MaxIPFailures:      50,
+		LockoutDuration:    15 * time.Minute,
+		FreeAttempts:       3,
+		BaseDelay:          1 * time.Second,
+		MaxDelay:           5 * time.Minute,
+		Window:             1 * time.Hour,
+	}
+}
+
+// AccountKey returns the limiter key for a user account
+func AccountKey(userID string) string {
+	return "user:" + userID
+}
+
+// IPKey returns the limiter key for a client IP
+func IPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Blocked returns how long the key must wait before its next attempt (0 if allowed)
+func (l *LoginLimiter) Blocked(key string) (time.Duration, error) {
+	record, err := l.store.Get(key)
+	if err != nil {
+		return 0, err
+	}
+
+	wait := time.Until(record.LockedUntil)
+	if wait < 0 {
+		return 0, nil
+	}
+	return wait, nil
+}
+
+// Fail records a failed attempt and reports whether the key is now locked out
+func (l *LoginLimiter) Fail(key string, maxFailures int) (AttemptRecord, bool, error) {
+	now := time.Now()
+	record, err := l.store.Increment(key, now, l.Window)
+	if err != nil {
+		return AttemptRecord{}, false, err
+	}
+
+	locked := record.Failures >= maxFailures
+	until := now.Add(l.delay(record.Failures))
+	if locked {
+		until = now.Add(l.LockoutDuration)
+	}
+	if until.After(record.LockedUntil) {
+		if err := l.store.Block(key, until); err != nil {
+			return AttemptRecord{}, false, err
+		}
+		record.LockedUntil = until
+	}
+	return record, locked, nil
+}
+
+// Reset clears the failures of a key (successful login or admin unlock)
+func (l *LoginLimiter) Reset(key string) error {
+	return l.store.Delete(key)
+}
+
+// delay returns the backoff after the given number of failures
+func (l *LoginLimiter) delay(failures int) time.Duration {
+	if failures <= l.FreeAttempts {
+		return 0
+	}
+
+	delay := l.BaseDelay
+	for i := l.FreeAttempts + 1; i < failures && delay < l.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > l.MaxDelay {
+		delay = l.MaxDelay
+	}
+	return delay
+}
+
+// MemoryLimiterStore keeps attempt records in process memory
+type MemoryLimiterStore struct {
+	records map[string]AttemptRecord
+	mutex   sync.Mutex
+}
+
+// NewMemoryLimiterStore creates an in-memory limiter store
+func NewMemoryLimiterStore() *MemoryLimiterStore {
+	return &MemoryLimiterStore{records: make(map[string]AttemptRecord)}
+}
+
+// Get returns the record for key (zero value if none)
+func (s *MemoryLimiterStore) Get(key string) (AttemptRecord, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.records[key], nil
+}
+
+// Increment counts a failure for key
+func (s *MemoryLimiterStore) Increment(key string, now time.Time, window time.Duration) (AttemptRecord, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Drop records that can no longer block anyone so the map stays bounded
+	for k, r := range s.records {
+		if now.After(r.LockedUntil) && now.Sub(r.LastFailure) > 24*time.Hour {
+			delete(s.records, k)
+		}
+	}
+
+	record := s.records[key]
+	if now.Sub(record.LastFailure) > window {
+		record = AttemptRecord{}
+	}
+	record.Failures++
+	record.LastFailure = now
+	s.records[key] = record
+	return record, nil
+}
+
+// Block makes key wait until the given time
+func (s *MemoryLimiterStore) Block(key string, until time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	record := s.records[key]
+	if until.After(record.LockedUntil) {
+		record.LockedUntil = until
+		s.records[key] = record
+	}
+	return nil
+}
+
+// Delete removes the record for key
+func (s *MemoryLimiterStore) Delete(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+// envInt reads a positive integer from the environment
+func envInt(name string, fallback int) int {
+	if value, err := strconv.Atoi(os.Getenv(name)); err == nil && value > 0 {
+		return value
+	}
+	return fallback
+}
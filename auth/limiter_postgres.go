@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"database/sql"
+	"playtz-api/database"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PostgresLimiterStore keeps attempt records in the login_attempts table
+type PostgresLimiterStore struct{}
+
+// NewPostgresLimiterStore creates a Postgres-backed limiter store
+func NewPostgresLimiterStore() *PostgresLimiterStore {
+	return &PostgresLimiterStore{}
+}
+
+// Get returns the record for key (zero value if none)
+func (s *PostgresLimiterStore) Get(key string) (AttemptRecord, error) {
+	var record AttemptRecord
+	var lockedUntil sql.NullTime
+	err := database.DB.QueryRow(
+		"SELECT failures, last_failure, locked_until FROM login_attempts WHERE key = $1",
+		key,
+	).Scan(&record.Failures, &record.LastFailure, &lockedUntil)
+
+	if err == sql.ErrNoRows {
+		return AttemptRecord{}, nil
+	}
+	if err != nil {
+		return AttemptRecord{}, err
+	}
+
+	record.LockedUntil = lockedUntil.Time
+	return record, nil
+}
+
+// Increment counts a failure for key in a single statement, so concurrent failures cannot
+// overwrite each other
+func (s *PostgresLimiterStore) Increment(key string, now time.Time, window time.Duration) (AttemptRecord, error) {
+	var record AttemptRecord
+	var lockedUntil sql.NullTime
+	err := database.DB.QueryRow(`
+		INSERT INTO login_attempts (key, failures, last_failure)
+		VALUES ($1, 1, $2)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_attempts.last_failure < $3 THEN 1 ELSE login_attempts.failures + 1 END,
+			locked_until = CASE WHEN login_attempts.last_failure < $3 THEN NULL ELSE login_attempts.locked_until END,
+			last_failure = $2
+		RETURNING failures, last_failure, locked_until
+	`, key, now, now.Add(-window)).Scan(&record.Failures, &record.LastFailure, &lockedUntil)
+	if err != nil {
+		return AttemptRecord{}, err
+	}
+
+	record.LockedUntil = lockedUntil.Time
+	return record, nil
+}
+
+// Block makes key wait until the given time
+func (s *PostgresLimiterStore) Block(key string, until time.Time) error {
+	_, err := database.DB.Exec(
+		"UPDATE login_attempts SET locked_until = GREATEST(COALESCE(locked_until, $2), $2) WHERE key = $1",
+		key, until,
+	)
+	return err
+}
+
+// Delete removes the record for key
+func (s *PostgresLimiterStore) Delete(key string) error {
+	_, err := database.DB.Exec("DELETE FROM login_attempts WHERE key = $1", key)
+	return err
+}
+
+// RecordLockout stores a lockout event so it shows up on the admin dashboard
+func RecordLockout(userID, ip, identifier string, lockedUntil time.Time) error {
+	var userIDValue interface{}
+	if userID != "" {
+		userIDValue = userID
+	}
+
+	_, err := database.DB.Exec(
+		"INSERT INTO login_lockouts (id, user_id, ip, identifier, locked_until) VALUES ($1, $2, $3, $4, $5)",
+		uuid.New().String(), userIDValue, ip, identifier, lockedUntil,
+	)
+	return err
+}
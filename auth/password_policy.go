@@ -0,0 +1,229 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/rand"
+	_ "embed"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"playtz-api/database"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+//go:embed common_passwords.txt
+var commonPasswordsFile string
+
+// PasswordViolation is one rule a password fails
+type PasswordViolation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicy describes what a password must satisfy
+type PasswordPolicy struct {
+	MinLength     int
+	RequireLower  bool
+	RequireUpper  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	RejectCommon  bool // Reject passwords from the bundled common-passwords list
+	HistorySize   int  // Number of previous passwords that cannot be reused (0 disables)
+}
+
+// bcrypt ignores everything after 72 bytes
+const maxPasswordBytes = 72
+
+var passwordPolicy *PasswordPolicy
+var passwordPolicyOnce sync.Once
+
+var commonPasswords map[string]bool
+var commonPasswordsOnce sync.Once
+
+// GetPasswordPolicy returns the password policy configured from the environment:
+// PASSWORD_MIN_LENGTH, PASSWORD_REQUIRE_LOWER/UPPER/DIGIT/SYMBOL, PASSWORD_REJECT_COMMON and PASSWORD_HISTORY
+func GetPasswordPolicy() *PasswordPolicy {
+	passwordPolicyOnce.Do(func() {
+		passwordPolicy = &PasswordPolicy{
+			MinLength:     envInt("PASSWORD_MIN_LENGTH", 10),
+			RequireLower:  envBool("PASSWORD_REQUIRE_LOWER", true),
+			RequireUpper:  envBool("PASSWORD_REQUIRE_UPPER", true),
+			RequireDigit:  envBool("PASSWORD_REQUIRE_DIGIT", true),
+			RequireSymbol: envBool("PASSWORD_REQUIRE_SYMBOL", false),
+			RejectCommon:  envBool("PASSWORD_REJECT_COMMON", true),
+			HistorySize:   5,
+		}
+		// 0 is allowed here and turns the reuse check off
+		if value, err := strconv.Atoi(os.Getenv("PASSWORD_HISTORY")); err == nil && value >= 0 {
+			passwordPolicy.HistorySize = value
+		}
+	})
+	return passwordPolicy
+}
+
+// Check returns the rules the password breaks, not counting reuse
+func (p *PasswordPolicy) Check(password string) []PasswordViolation {
+	violations := []PasswordViolation{}
+
+	if len([]rune(password)) < p.MinLength {
+		violations = append(violations, PasswordViolation{"too_short", fmt.Sprintf("Password must be at least %d characters", p.MinLength)})
+	}
+	if len(password) > maxPasswordBytes {
+		violations = append(violations, PasswordViolation{"too_long", fmt.Sprintf("Password must be at most %d bytes", maxPasswordBytes)})
+	}
+
+	var hasLower, hasUpper, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+	if p.RequireLower && !hasLower {
+		violations = append(violations, PasswordViolation{"missing_lowercase", "Password must contain a lowercase letter"})
+	}
+	if p.RequireUpper && !hasUpper {
+		violations = append(violations, PasswordViolation{"missing_uppercase", "Password must contain an uppercase letter"})
+	}
+	if p.RequireDigit && !hasDigit {
+		violations = append(violations, PasswordViolation{"missing_digit", "Password must contain a digit"})
+	}
+	if p.RequireSymbol && !hasSymbol {
+		violations = append(violations, PasswordViolation{"missing_symbol", "Password must contain a symbol"})
+	}
+
+	if p.RejectCommon && isCommonPassword(password) {
+		violations = append(violations, PasswordViolation{"common_password", "Password is too common"})
+	}
+
+	return violations
+}
+
+// Validate checks the password against the policy, including reuse of the
+// user's current and recent passwords (userID may be empty for new users)
+func (p *PasswordPolicy) Validate(userID, password string) ([]PasswordViolation, error) {
+	violations := p.Check(password)
+	if userID == "" || p.HistorySize == 0 {
+		return violations, nil
+	}
+
+	reused, err := p.isReused(userID, password)
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		violations = append(violations, PasswordViolation{
+			"reused_password",
+			fmt.Sprintf("Password must differ from your last %d passwords", p.HistorySize),
+		})
+	}
+	return violations, nil
+}
+
+// isReused compares the password with the current hash and the last HistorySize hashes
+func (p *PasswordPolicy) isReused(userID, password string) (bool, error) {
+	rows, err := database.DB.Query(`
+		(SELECT password_hash FROM users WHERE id = $1)
+		UNION ALL
+		(SELECT password_hash FROM password_history WHERE user_id = $1 ORDER BY created_at DESC LIMIT $2)
+	`, userID, p.HistorySize)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return false, err
+		}
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// RecordPasswordHistory stores the hash a user is moving away from and prunes
+// entries beyond the configured history size. Call it before overwriting users.password_hash.
+func RecordPasswordHistory(userID string) error {
+	size := GetPasswordPolicy().HistorySize
+	if size == 0 {
+		return nil
+	}
+
+	_, err := database.DB.Exec(`
+		INSERT INTO password_history (id, user_id, password_hash)
+		SELECT $2, id, password_hash FROM users WHERE id = $1 AND password_hash IS NOT NULL
+	`, userID, uuid.New().String())
+	if err != nil {
+		return err
+	}
+
+	_, err = database.DB.Exec(`
+		DELETE FROM password_history WHERE user_id = $1 AND id NOT IN (
+			SELECT id FROM password_history WHERE user_id = $1 ORDER BY created_at DESC LIMIT $2
+		)
+	`, userID, size)
+	return err
+}
+
+// GeneratePassword returns a random password that satisfies the policy
+func (p *PasswordPolicy) GeneratePassword() (string, error) {
+	const alphabet = "abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789!@#$%&*-_"
+
+	length := p.MinLength
+	if length < 16 {
+		length = 16
+	}
+
+	for attempt := 0; attempt < 100; attempt++ {
+		b := make([]byte, length)
+		for i := range b {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+			if err != nil {
+				return "", err
+			}
+			b[i] = alphabet[n.Int64()]
+		}
+		if password := string(b); len(p.Check(password)) == 0 {
+			return password, nil
+		}
+	}
+	return "", errors.New("password policy cannot be satisfied by generated passwords")
+}
+
+// isCommonPassword reports whether the password is on the bundled list (case-insensitive)
+func isCommonPassword(password string) bool {
+	commonPasswordsOnce.Do(func() {
+		commonPasswords = make(map[string]bool)
+		scanner := bufio.NewScanner(strings.NewReader(commonPasswordsFile))
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+				commonPasswords[strings.ToLower(line)] = true
+			}
+		}
+	})
+	return commonPasswords[strings.ToLower(password)]
+}
+
+// envBool reads a boolean from the environment
+func envBool(name string, fallback bool) bool {
+	if value, err := strconv.ParseBool(os.Getenv(name)); err == nil {
+		return value
+	}
+	return fallback
+}
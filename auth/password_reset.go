@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"playtz-api/database"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PasswordResetTTL is how long an emailed reset link stays valid
+const PasswordResetTTL = 1 * time.Hour
+
+// ErrResetTokenInvalid is returned for unknown, used or expired reset tokens
+var ErrResetTokenInvalid = errors.New("invalid or expired reset token")
+
+// CreatePasswordResetToken issues a single-use reset token for a user.
+// Any earlier unused token of the user is invalidated.
+func CreatePasswordResetToken(userID string) (string, error) {
+	token, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		"UPDATE password_reset_tokens SET used_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND used_at IS NULL",
+		userID,
+	); err != nil {
+		return "", err
+	}
+
+	if _, err := tx.Exec(
+		"INSERT INTO password_reset_tokens (id, user_id, token_hash, expires_at) VALUES ($1, $2, $3, $4)",
+		uuid.New().String(), userID, HashToken(token), time.Now().Add(PasswordResetTTL),
+	); err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// ConsumePasswordResetToken marks a reset token as used and returns its user ID
+func ConsumePasswordResetToken(token string) (string, error) {
+	var userID string
+	err := database.DB.QueryRow(`
+		UPDATE password_reset_tokens SET used_at = CURRENT_TIMESTAMP
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		RETURNING user_id
+	`, HashToken(token)).Scan(&userID)
+
+	if err == sql.ErrNoRows {
+		return "", ErrResetTokenInvalid
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return userID, nil
+}
+
+// LookupPasswordResetToken returns the user ID of a valid reset token without using it up,
+// so the new password can be validated before the token is consumed
+func LookupPasswordResetToken(token string) (string, error) {
+	var userID string
+	err := database.DB.QueryRow(
+		"SELECT user_id FROM password_reset_tokens WHERE token_hash = $1 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP",
+		HashToken(token),
+	).Scan(&userID)
+
+	if err == sql.ErrNoRows {
+		return "", ErrResetTokenInvalid
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return userID, nil
+}
@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"database/sql"
+	"playtz-api/database"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// permissionEntry holds the cached permissions of a single user
+type permissionEntry struct {
+	permissions map[string]bool
+	expiresAt   time.Time
+}
+
+// PermissionCache caches role permissions per user
+type PermissionCache struct {
+	entries map[string]*permissionEntry
+	mutex   sync.RWMutex
+	ttl     time.Duration
+}
+
+var permissionCache *PermissionCache
+var permissionOnce sync.Once
+
+// GetPermissionCache returns the singleton permission cache
+func GetPermissionCache() *PermissionCache {
+	permissionOnce.Do(func() {
+		permissionCache = &PermissionCache{
+			entries: make(map[string]*permissionEntry),
+			ttl:     5 * time.Minute, // Upper bound for stale permissions on other instances
+		}
+	})
+	return permissionCache
+}
+
+// Permissions returns the permission set of a user, loading it from the database on a cache miss
+func (p *PermissionCache) Permissions(userID string) (map[string]bool, error) {
+	p.mutex.RLock()
+	entry, exists := p.entries[userID]
+	p.mutex.RUnlock()
+
+	if exists && time.Now().Before(entry.expiresAt) {
+		return entry.permissions, nil
+	}
+
+	permissions, err := loadUserPermissions(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mutex.Lock()
+	p.entries[userID] = &permissionEntry{
+		permissions: permissions,
+		expiresAt:   time.Now().Add(p.ttl),
+	}
+	p.mutex.Unlock()
+
+	return permissions, nil
+}
+
+// InvalidateUser drops the cached permissions of a single user
+func (p *PermissionCache) InvalidateUser(userID string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	delete(p.entries, userID)
+}
+
+// InvalidateAll drops every cached entry (used when a role changes)
+func (p *PermissionCache) InvalidateAll() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.entries = make(map[string]*permissionEntry)
+}
+
+// loadUserPermissions fetches the permissions granted by the user's role.
+// Inactive users and inactive roles get an empty set.
+func loadUserPermissions(userID string) (map[string]bool, error) {
+	var permissions pq.StringArray
+	err := database.DB.QueryRow(`
+		SELECT r.permissions
+		FROM users u
+		JOIN roles r ON u.role_id = r.id
+		WHERE u.id = $1 AND u.active = true AND r.active = true
+	`, userID).Scan(&permissions)
+
+	if err == sql.ErrNoRows {
+		return map[string]bool{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]bool, len(permissions))
+	for _, permission := range permissions {
+		result[permission] = true
+	}
+	return result, nil
+}
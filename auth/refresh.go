@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"playtz-api/database"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshTokenTTL is how long a refresh token stays usable
+const RefreshTokenTTL = 30 * 24 * time.Hour
+
+var (
+	// ErrRefreshTokenInvalid is returned for unknown, expired or revoked refresh tokens
+	ErrRefreshTokenInvalid = errors.New("invalid or expired refresh token")
+	// ErrRefreshTokenReused is returned when an already rotated refresh token is presented again
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+)
+
+// IssueRefreshToken creates a new opaque refresh token for a user.
+// An empty familyID starts a new token family (i.e. a new login).
+func IssueRefreshToken(userID, familyID string) (string, error) {
+	return issueRefreshToken(database.DB, userID, familyID)
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one in the same family.
+// The family ID is the ID of the login session the token belongs to.
+// Presenting a token that was already rotated revokes the whole family.
+func RotateRefreshToken(token string) (userID, familyID, newToken string, err error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return "", "", "", err
+	}
+	defer tx.Rollback()
+
+	tokenHash := HashToken(token)
+
+	var expiresAt time.Time
+	err = tx.QueryRow(`
+		UPDATE refresh_tokens SET used_at = CURRENT_TIMESTAMP
+		WHERE token_hash = $1 AND used_at IS NULL AND revoked_at IS NULL
+		RETURNING user_id, family_id, expires_at
+	`, tokenHash).Scan(&userID, &familyID, &expiresAt)
+
+	if err == sql.ErrNoRows {
+		// Either unknown, revoked, or already used - find out which
+		var usedAt, revokedAt sql.NullTime
+		err = tx.QueryRow(
+			"SELECT family_id, used_at, revoked_at FROM refresh_tokens WHERE token_hash = $1",
+			tokenHash,
+		).Scan(&familyID, &usedAt, &revokedAt)
+		if err == sql.ErrNoRows {
+			return "", "", "", ErrRefreshTokenInvalid
+		}
+		if err != nil {
+			return "", "", "", err
+		}
+
+		if usedAt.Valid && !revokedAt.Valid {
+			// A rotated token came back: assume it was stolen and kill the family and its session
+			if _, err := tx.Exec(
+				"UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE family_id = $1 AND revoked_at IS NULL",
+				familyID,
+			); err != nil {
+				return "", "", "", err
+			}
+			if _, err := tx.Exec(
+				"UPDATE user_sessions SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND revoked_at IS NULL",
+				familyID,
+			); err != nil {
+				return "", "", "", err
+			}
+			if err := tx.Commit(); err != nil {
+				return "", "", "", err
+			}
+			return "", "", "", ErrRefreshTokenReused
+		}
+		return "", "", "", ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return "", "", "", err
+	}
+
+	if time.Now().After(expiresAt) {
+		return "", "", "", ErrRefreshTokenInvalid
+	}
+
+	newToken, err = issueRefreshToken(tx, userID, familyID)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", "", "", err
+	}
+
+	return userID, familyID, newToken, nil
+}
+
+// RevokeRefreshTokenFamily revokes every token in the family of the given token
+// and ends the session it belongs to
+func RevokeRefreshTokenFamily(token string) error {
+	var familyID string
+	err := database.DB.QueryRow(
+		"SELECT family_id FROM refresh_tokens WHERE token_hash = $1",
+		HashToken(token),
+	).Scan(&familyID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = database.DB.Exec(
+		"UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE family_id = $1 AND revoked_at IS NULL",
+		familyID,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.DB.Exec(
+		"UPDATE user_sessions SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND revoked_at IS NULL",
+		familyID,
+	)
+	return err
+}
+
+// HashToken returns the hex encoded SHA-256 of an opaque token.
+// Opaque tokens are high-entropy, so a fast hash is sufficient.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateOpaqueToken returns a random URL-safe token
+func GenerateOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func issueRefreshToken(db execer, userID, familyID string) (string, error) {
+	token, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	if familyID == "" {
+		familyID = uuid.New().String()
+	}
+
+	_, err = db.Exec(
+		"INSERT INTO refresh_tokens (id, user_id, family_id, token_hash, expires_at) VALUES ($1, $2, $3, $4, $5)",
+		uuid.New().String(), userID, familyID, HashToken(token), time.Now().Add(RefreshTokenTTL),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"log"
+	"playtz-api/database"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var cleanupOnce sync.Once
+
+// RevokeToken revokes a single access token by its jti until it would have expired anyway
+func RevokeToken(claims *JWTClaims) error {
+	if claims.ID == "" {
+		return nil // Tokens issued before jti was introduced simply expire
+	}
+
+	expiresAt := time.Now().Add(AccessTokenTTL)
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+
+	_, err := database.DB.Exec(
+		"INSERT INTO token_revocations (id, jti, user_id, expires_at) VALUES ($1, $2, $3, $4)",
+		uuid.New().String(), claims.ID, claims.UserID, expiresAt,
+	)
+	return err
+}
+
+// RevokeAllUserTokens revokes every access token issued to a user so far,
+// along with all of the user's refresh tokens and sessions
+func RevokeAllUserTokens(userID string) error {
+	return RevokeUserTokensExcept(userID, "")
+}
+
+// RevokeUserTokensExcept is RevokeAllUserTokens sparing one session, so a password change can
+// start the caller's new session first and keep it. An empty sessionID spares nothing.
+func RevokeUserTokensExcept(userID, sessionID string) error {
+	// iat has second precision, so every token issued in the second of the revocation is
+	// revoked too (revoked_at >= iat); only the spared session is exempt
+	_, err := database.DB.Exec(
+		"INSERT INTO token_revocations (id, user_id, revoked_at, expires_at, except_session_id) VALUES ($1, $2, CURRENT_TIMESTAMP, $3, NULLIF($4, ''))",
+		uuid.New().String(), userID, time.Now().Add(AccessTokenTTL), sessionID,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.DB.Exec(
+		"UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND family_id <> $2 AND revoked_at IS NULL",
+		userID, sessionID,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.DB.Exec(
+		"UPDATE user_sessions SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL",
+		userID, sessionID,
+	)
+	return err
+}
+
+// IsTokenRevoked reports whether an access token was revoked, either by jti,
+// by a user-wide revocation issued after the token or by ending its session
+func IsTokenRevoked(claims *JWTClaims) (bool, error) {
+	var issuedAt time.Time
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Time
+	}
+
+	var revoked bool
+	err := database.DB.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM token_revocations
+			WHERE jti = $1
+			   OR (user_id = $2 AND jti IS NULL AND revoked_at >= $3
+			       AND (except_session_id IS NULL OR except_session_id <> $4))
+		) OR EXISTS(
+			SELECT 1 FROM user_sessions WHERE id = $4 AND revoked_at IS NOT NULL
+		)
+	`, claims.ID, claims.UserID, issuedAt, claims.SessionID).Scan(&revoked)
+	if err != nil {
+		return false, err
+	}
+
+	return revoked, nil
+}
+
+// ConsumeChallenge marks a challenge as used after a successful code, so it cannot log in twice.
+// It returns false if the challenge was already used.
+func ConsumeChallenge(claims *ChallengeClaims) (bool, error) {
+	expiresAt := time.Now().Add(ChallengeTokenTTL)
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+
+	result, err := database.DB.Exec(`
+		INSERT INTO token_revocations (id, jti, user_id, expires_at)
+		SELECT $1, $2, $3, $4
+		WHERE NOT EXISTS (SELECT 1 FROM token_revocations WHERE jti = $2)
+	`, uuid.New().String(), claims.ID, claims.UserID, expiresAt)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected == 1, nil
+}
+
+// ChallengeUsed reports whether a challenge has already completed a login
+func ChallengeUsed(claims *ChallengeClaims) (bool, error) {
+	var used bool
+	err := database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM token_revocations WHERE jti = $1)", claims.ID).Scan(&used)
+	return used, err
+}
+
+// StartTokenCleanup periodically prunes expired revocations, refresh tokens and reset tokens.
+// Safe to call more than once; only one cleanup goroutine is started.
+func StartTokenCleanup() {
+	cleanupOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(10 * time.Minute)
+			defer ticker.Stop()
+
+			for {
+				pruneExpiredTokens()
+				<-ticker.C
+			}
+		}()
+	})
+}
+
+// pruneExpiredTokens deletes rows that can no longer affect authentication
+func pruneExpiredTokens() {
+	if _, err := database.DB.Exec("DELETE FROM token_revocations WHERE expires_at < CURRENT_TIMESTAMP"); err != nil {
+		log.Printf("Failed to prune token revocations: %v", err)
+	}
+	if _, err := database.DB.Exec("DELETE FROM refresh_tokens WHERE expires_at < CURRENT_TIMESTAMP"); err != nil {
+		log.Printf("Failed to prune refresh tokens: %v", err)
+	}
+	if _, err := database.DB.Exec("DELETE FROM password_reset_tokens WHERE expires_at < CURRENT_TIMESTAMP"); err != nil {
+		log.Printf("Failed to prune password reset tokens: %v", err)
+	}
+	if _, err := database.DB.Exec("DELETE FROM email_verification_tokens WHERE expires_at < CURRENT_TIMESTAMP"); err != nil {
+		log.Printf("Failed to prune email verification tokens: %v", err)
+	}
+	if _, err := database.DB.Exec(
+		"DELETE FROM login_attempts WHERE last_failure < CURRENT_TIMESTAMP - INTERVAL '1 day' AND (locked_until IS NULL OR locked_until < CURRENT_TIMESTAMP)",
+	); err != nil {
+		log.Printf("Failed to prune login attempts: %v", err)
+	}
+	if _, err := database.DB.Exec("DELETE FROM oidc_states WHERE expires_at < CURRENT_TIMESTAMP"); err != nil {
+		log.Printf("Failed to prune SSO login states: %v", err)
+	}
+	// Sessions idle past the refresh token lifetime cannot be resumed; revoked ones only
+	// matter until their last access token expires
+	if _, err := database.DB.Exec(
+		"DELETE FROM user_sessions WHERE last_seen_at < $1 OR revoked_at < $2",
+		time.Now().Add(-RefreshTokenTTL), time.Now().Add(-AccessTokenTTL),
+	); err != nil {
+		log.Printf("Failed to prune sessions: %v", err)
+	}
+}
@@ -1,122 +1,127 @@
 package auth
 
 import (
-	"crypto/rand"
-	"encoding/base64"
-	"sync"
+	"errors"
+	"playtz-api/database"
 	"time"
+
+	"github.com/google/uuid"
 )
 
-// Session represents a user session
+// sessionTouchInterval limits how often last_seen_at is written for a session
+const sessionTouchInterval = 1 * time.Minute
+
+// ErrSessionNotFound is returned for sessions that do not exist or belong to another user
+var ErrSessionNotFound = errors.New("session not found")
+
+// Session is one login on one device. Its ID is also the family ID of the login's
+// refresh tokens and the sid claim of its access tokens.
 type Session struct {
-	ID        string
-	UserID    string
-	Username  string
-	Email     string
-	RoleID    string
-	RoleName  string
-	LastActivity time.Time
-	ExpiresAt    time.Time
+	ID         string
+	UserID     string
+	UserAgent  string
+	IP         string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
 }
 
-// SessionStore manages user sessions
-type SessionStore struct {
-	sessions map[string]*Session
-	mutex    sync.RWMutex
-	timeout  time.Duration
+// StartSession records a new login and returns its ID
+func StartSession(userID, userAgent, ip string) (string, error) {
+	sessionID := uuid.New().String()
+	_, err := database.DB.Exec(
+		"INSERT INTO user_sessions (id, user_id, user_agent, ip) VALUES ($1, $2, $3, $4)",
+		sessionID, userID, userAgent, ip,
+	)
+	if err != nil {
+		return "", err
+	}
+	return sessionID, nil
 }
 
-var store *SessionStore
-var once sync.Once
-
-// GetSessionStore returns the singleton session store
-func GetSessionStore() *SessionStore {
-	once.Do(func() {
-		store = &SessionStore{
-			sessions: make(map[string]*Session),
-			timeout:  10 * time.Minute, // 10 minute inactivity timeout
-		}
-		// Start cleanup goroutine
-		go store.cleanup()
-	})
-	return store
+// TouchSession records that a session was just used from the given IP.
+// Writes are throttled to one per sessionTouchInterval.
+func TouchSession(sessionID, ip string) error {
+	_, err := database.DB.Exec(`
+		UPDATE user_sessions SET last_seen_at = CURRENT_TIMESTAMP, ip = $2
+		WHERE id = $1 AND revoked_at IS NULL AND last_seen_at < $3
+	`, sessionID, ip, time.Now().Add(-sessionTouchInterval))
+	return err
 }
 
-// CreateSession creates a new session for a user
-func (s *SessionStore) CreateSession(userID, username, email, roleID, roleName string) string {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-
-	sessionID := generateSessionID()
-	now := time.Now()
-	
-	session := &Session{
-		ID:          sessionID,
-		UserID:      userID,
-		Username:    username,
-		Email:       email,
-		RoleID:      roleID,
-		RoleName:    roleName,
-		LastActivity: now,
-		ExpiresAt:    now.Add(s.timeout),
+// ListSessions returns the active sessions of a user, most recently used first
+func ListSessions(userID string) ([]Session, error) {
+	rows, err := database.DB.Query(`
+		SELECT id, user_id, COALESCE(user_agent, ''), COALESCE(ip, ''), created_at, last_seen_at
+		FROM user_sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND last_seen_at > $2
+		ORDER BY last_seen_at DESC
+	`, userID, time.Now().Add(-RefreshTokenTTL))
+	if err != nil {
+		return nil, err
 	}
-
-	s.sessions[sessionID] = session
-	return sessionID
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var session Session
+		if err := rows.Scan(&session.ID, &session.UserID, &session.UserAgent, &session.IP,
+			&session.CreatedAt, &session.LastSeenAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
 }
 
-// GetSession retrieves a session by ID and updates last activity
-func (s *SessionStore) GetSession(sessionID string) (*Session, bool) {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-
-	session, exists := s.sessions[sessionID]
-	if !exists {
-		return nil, false
+// RevokeSession ends one session of a user: its refresh tokens stop working and
+// its access tokens are rejected by IsTokenRevoked
+func RevokeSession(userID, sessionID string) error {
+	result, err := database.DB.Exec(
+		"UPDATE user_sessions SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL",
+		sessionID, userID,
+	)
+	if err != nil {
+		return err
 	}
-
-	// Check if session expired
-	if time.Now().After(session.ExpiresAt) {
-		delete(s.sessions, sessionID)
-		return nil, false
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return ErrSessionNotFound
 	}
 
-	// Update last activity
-	session.LastActivity = time.Now()
-	session.ExpiresAt = session.LastActivity.Add(s.timeout)
-	
-	return session, true
-}
-
-// DeleteSession removes a session
-func (s *SessionStore) DeleteSession(sessionID string) {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-	delete(s.sessions, sessionID)
+	_, err = database.DB.Exec(
+		"UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE family_id = $1 AND revoked_at IS NULL",
+		sessionID,
+	)
+	return err
 }
 
-// cleanup removes expired sessions periodically
-func (s *SessionStore) cleanup() {
-	ticker := time.NewTicker(1 * time.Minute)
-	defer ticker.Stop()
+// RevokeOtherSessions ends every session of a user except the given one and returns how many were ended
+func RevokeOtherSessions(userID, keepSessionID string) (int, error) {
+	rows, err := database.DB.Query(`
+		UPDATE user_sessions SET revoked_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL
+		RETURNING id
+	`, userID, keepSessionID)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
 
-	for range ticker.C {
-		s.mutex.Lock()
-		now := time.Now()
-		for id, session := range s.sessions {
-			// Remove if expired or inactive for more than timeout
-			if now.After(session.ExpiresAt) || now.Sub(session.LastActivity) > s.timeout {
-				delete(s.sessions, id)
-			}
+	var sessionIDs []string
+	for rows.Next() {
+		var sessionID string
+		if err := rows.Scan(&sessionID); err != nil {
+			return 0, err
 		}
-		s.mutex.Unlock()
+		sessionIDs = append(sessionIDs, sessionID)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
 	}
-}
 
-// generateSessionID generates a random session ID
-func generateSessionID() string {
-	b := make([]byte, 32)
-	rand.Read(b)
-	return base64.URLEncoding.EncodeToString(b)
+	// Refresh tokens from before sessions were recorded have no session row; revoke them too
+	_, err = database.DB.Exec(
+		"UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND family_id <> $2 AND revoked_at IS NULL",
+		userID, keepSessionID,
+	)
+	return len(sessionIDs), err
 }
-
@@ -3,11 +3,15 @@ package database
 import (
 	"database/sql"
 	"embed"
+	"log"
 )
 
 //go:embed schema.sql
 var schemaFS embed.FS
 
+// TrigramSearch reports whether pg_trgm is available, so search can also match titles by similarity
+var TrigramSearch bool
+
 // Migrate runs the database migrations
 func Migrate() error {
 	if DB == nil {
@@ -26,5 +30,20 @@ func Migrate() error {
 		return err
 	}
 
+	enableTrigramSearch()
 	return nil
 }
+
+// enableTrigramSearch installs pg_trgm and the trigram title index. Creating an extension needs
+// privileges some hosts do not grant; search then falls back to full-text matching only.
+func enableTrigramSearch() {
+	if _, err := DB.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm"); err != nil {
+		log.Printf("pg_trgm is not available, search will not match titles by similarity: %v", err)
+		return
+	}
+	_, err := DB.Exec("CREATE INDEX IF NOT EXISTS idx_search_documents_title_trgm ON search_documents USING gin(title gin_trgm_ops)")
+	if err != nil {
+		log.Printf("Failed to create the trigram search index: %v", err)
+	}
+	TrigramSearch = true
+}
@@ -267,13 +267,408 @@ BEGIN
     END IF;
 END $$;
 
--- Full-text search indexes (using GIN for better text search performance)
--- Note: These require the pg_trgm extension for trigram matching
--- CREATE EXTENSION IF NOT EXISTS pg_trgm;
+-- ============================================
+-- AUTHENTICATION
+-- ============================================
+
+-- Refresh tokens (opaque, stored as SHA-256 hashes)
+-- Tokens issued from the same login share a family_id; reusing a rotated token revokes the family
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id VARCHAR(50) PRIMARY KEY,
+    user_id VARCHAR(50) REFERENCES users(id) ON DELETE CASCADE,
+    family_id VARCHAR(50) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP, -- Set when the token is rotated
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user ON refresh_tokens(user_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family ON refresh_tokens(family_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_expires ON refresh_tokens(expires_at);
+
+-- Revoked access tokens
+-- A row with jti revokes one token; a row with only user_id revokes every token issued before revoked_at
+CREATE TABLE IF NOT EXISTS token_revocations (
+    id VARCHAR(50) PRIMARY KEY,
+    jti VARCHAR(50),
+    user_id VARCHAR(50),
+    revoked_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL -- Pruned once every affected token has expired
+);
+
+CREATE INDEX IF NOT EXISTS idx_token_revocations_jti ON token_revocations(jti);
+CREATE INDEX IF NOT EXISTS idx_token_revocations_user ON token_revocations(user_id);
+CREATE INDEX IF NOT EXISTS idx_token_revocations_expires ON token_revocations(expires_at);
+
+-- Session spared by a user-wide revocation (the one started by a password change)
+DO $$ 
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns 
+        WHERE table_name = 'token_revocations' AND column_name = 'except_session_id'
+    ) THEN
+        ALTER TABLE token_revocations ADD COLUMN except_session_id VARCHAR(50);
+    END IF;
+END $$;
+
+-- Two-factor authentication (TOTP) columns
+DO $$ 
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns 
+        WHERE table_name = 'users' AND column_name = 'totp_secret'
+    ) THEN
+        ALTER TABLE users ADD COLUMN totp_secret VARCHAR(64); -- Base32, set on enrollment
+    END IF;
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns 
+        WHERE table_name = 'users' AND column_name = 'totp_enabled'
+    ) THEN
+        ALTER TABLE users ADD COLUMN totp_enabled BOOLEAN DEFAULT false;
+    END IF;
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns 
+        WHERE table_name = 'users' AND column_name = 'totp_last_step'
+    ) THEN
+        ALTER TABLE users ADD COLUMN totp_last_step BIGINT DEFAULT 0; -- Last accepted time step, blocks code replay
+    END IF;
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns 
+        WHERE table_name = 'roles' AND column_name = 'require_2fa'
+    ) THEN
+        ALTER TABLE roles ADD COLUMN require_2fa BOOLEAN DEFAULT false;
+    END IF;
+END $$;
+
+-- One-time 2FA recovery codes (SHA-256 hashes)
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id VARCHAR(50) PRIMARY KEY,
+    user_id VARCHAR(50) REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user ON recovery_codes(user_id);
+
+-- Password reset tokens (single use, SHA-256 hashes)
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    id VARCHAR(50) PRIMARY KEY,
+    user_id VARCHAR(50) REFERENCES users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user ON password_reset_tokens(user_id);
+
+-- Failed login counters used by the Postgres login limiter (key is "user:<id>" or "ip:<addr>")
+CREATE TABLE IF NOT EXISTS login_attempts (
+    key VARCHAR(255) PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP
+);
+
+-- Lockouts triggered by repeated failed logins (shown on the admin dashboard)
+CREATE TABLE IF NOT EXISTS login_lockouts (
+    id VARCHAR(50) PRIMARY KEY,
+    user_id VARCHAR(50) REFERENCES users(id) ON DELETE SET NULL, -- NULL for IP lockouts
+    ip VARCHAR(64),
+    identifier VARCHAR(255), -- Username or email that was tried
+    locked_until TIMESTAMP NOT NULL,
+    unlocked_at TIMESTAMP,
+    unlocked_by VARCHAR(50),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_lockouts_user ON login_lockouts(user_id);
+CREATE INDEX IF NOT EXISTS idx_login_lockouts_created ON login_lockouts(created_at DESC);
+
+-- API keys for machine clients (website, mobile app, playout automation)
+-- Keys look like ptz_<prefix>_<secret>; only the SHA-256 of the full key is stored
+CREATE TABLE IF NOT EXISTS api_keys (
+    id VARCHAR(50) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    prefix VARCHAR(16) NOT NULL UNIQUE,
+    key_hash VARCHAR(64) NOT NULL,
+    scopes TEXT[] NOT NULL, -- Subset of the permission strings used in roles.permissions
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    created_by VARCHAR(50) REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Previous password hashes, checked so recent passwords cannot be reused
+CREATE TABLE IF NOT EXISTS password_history (
+    id VARCHAR(50) PRIMARY KEY,
+    user_id VARCHAR(50) REFERENCES users(id) ON DELETE CASCADE,
+    password_hash VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_history_user ON password_history(user_id, created_at DESC);
+
+-- Single sign-on (OpenID Connect) login states: single use, deleted by the callback
+CREATE TABLE IF NOT EXISTS oidc_states (
+    state VARCHAR(64) PRIMARY KEY,
+    nonce VARCHAR(64) NOT NULL,
+    code_verifier VARCHAR(64) NOT NULL, -- PKCE verifier, never sent to the browser
+    redirect_to TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+-- Link between a user and their identity at the SSO provider
+DO $$ 
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns 
+        WHERE table_name = 'users' AND column_name = 'oidc_issuer'
+    ) THEN
+        ALTER TABLE users ADD COLUMN oidc_issuer VARCHAR(255);
+    END IF;
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns 
+        WHERE table_name = 'users' AND column_name = 'oidc_subject'
+    ) THEN
+        ALTER TABLE users ADD COLUMN oidc_subject VARCHAR(255);
+    END IF;
+END $$;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_oidc_identity ON users(oidc_issuer, oidc_subject);
+
+-- Listener accounts: self-registered users who are not station staff
+DO $$ 
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns 
+        WHERE table_name = 'users' AND column_name = 'account_type'
+    ) THEN
+        ALTER TABLE users ADD COLUMN account_type VARCHAR(20) NOT NULL DEFAULT 'staff'; -- 'staff' or 'listener'
+    END IF;
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns 
+        WHERE table_name = 'users' AND column_name = 'email_verified'
+    ) THEN
+        ALTER TABLE users ADD COLUMN email_verified BOOLEAN DEFAULT false;
+    END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS idx_users_account_type ON users(account_type);
+
+-- Email verification tokens for listener registration (single use, SHA-256 hashes)
+CREATE TABLE IF NOT EXISTS email_verification_tokens (
+    id VARCHAR(50) PRIMARY KEY,
+    user_id VARCHAR(50) REFERENCES users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
 
--- Text search indexes for common search fields
--- CREATE INDEX IF NOT EXISTS idx_news_title_trgm ON news USING gin(title gin_trgm_ops);
--- CREATE INDEX IF NOT EXISTS idx_news_content_trgm ON news USING gin(content gin_trgm_ops);
--- CREATE INDEX IF NOT EXISTS idx_events_title_trgm ON events USING gin(title gin_trgm_ops);
--- CREATE INDEX IF NOT EXISTS idx_merchandise_name_trgm ON merchandise USING gin(name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_email_verification_tokens_user ON email_verification_tokens(user_id);
 
+-- Audit log of mutating API requests (before/after hold only the changed fields, secrets removed)
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id VARCHAR(50) PRIMARY KEY,
+    actor_id VARCHAR(50), -- User ID or API key ID (no foreign key: entries outlive deleted users)
+    actor_type VARCHAR(20), -- 'user' or 'api_key'
+    actor_name VARCHAR(255),
+    action VARCHAR(100) NOT NULL, -- e.g. 'news.delete', 'orders.status.update'
+    method VARCHAR(10) NOT NULL,
+    route VARCHAR(255) NOT NULL,
+    resource_type VARCHAR(50),
+    resource_id VARCHAR(50),
+    status_code INTEGER NOT NULL,
+    before JSONB,
+    after JSONB,
+    ip VARCHAR(64),
+    user_agent TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_created ON audit_logs(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_actor ON audit_logs(actor_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_resource ON audit_logs(resource_type, resource_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_action ON audit_logs(action);
+
+-- Login sessions (one per device login)
+-- id is also the family_id of the session's refresh tokens and the sid claim of its access tokens
+CREATE TABLE IF NOT EXISTS user_sessions (
+    id VARCHAR(50) PRIMARY KEY,
+    user_id VARCHAR(50) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    user_agent TEXT,
+    ip VARCHAR(64),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_sessions_user ON user_sessions(user_id);
+CREATE INDEX IF NOT EXISTS idx_user_sessions_last_seen ON user_sessions(last_seen_at);
+
+-- Slugs: unique human-readable addresses generated from titles (see package slugs)
+DO $$ 
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns 
+        WHERE table_name = 'news' AND column_name = 'slug'
+    ) THEN
+        ALTER TABLE news ADD COLUMN slug VARCHAR(255);
+    END IF;
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns 
+        WHERE table_name = 'events' AND column_name = 'slug'
+    ) THEN
+        ALTER TABLE events ADD COLUMN slug VARCHAR(255);
+    END IF;
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns 
+        WHERE table_name = 'mixes' AND column_name = 'slug'
+    ) THEN
+        ALTER TABLE mixes ADD COLUMN slug VARCHAR(255);
+    END IF;
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns 
+        WHERE table_name = 'rooms' AND column_name = 'slug'
+    ) THEN
+        ALTER TABLE rooms ADD COLUMN slug VARCHAR(255);
+    END IF;
+END $$;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_news_slug ON news(slug);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_events_slug ON events(slug);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_mixes_slug ON mixes(slug);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_rooms_slug ON rooms(slug);
+
+-- Former slugs of renamed resources, so old links redirect to the current slug
+CREATE TABLE IF NOT EXISTS slug_aliases (
+    resource_type VARCHAR(20) NOT NULL, -- 'news', 'events', 'mixes' or 'rooms'
+    slug VARCHAR(255) NOT NULL,
+    resource_id VARCHAR(50) NOT NULL, -- No foreign key: the table depends on resource_type
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (resource_type, slug)
+);
+
+CREATE INDEX IF NOT EXISTS idx_slug_aliases_resource ON slug_aliases(resource_type, resource_id);
+
+-- Full-text search indexes
+-- search_documents holds one row per searchable resource and is kept in sync by the triggers below.
+-- Matching uses the weighted tsvector (title A, subtitle B, body C) plus trigram word similarity
+-- on the title for typos and partial words. pg_trgm and its title index are set up by Migrate
+-- (database/migrate.go), since not every host lets the app create extensions.
+
+CREATE TABLE IF NOT EXISTS search_documents (
+    resource_type VARCHAR(20) NOT NULL, -- news, events, mixes, tracks, merch, careers
+    resource_id VARCHAR(50) NOT NULL,
+    parent_id VARCHAR(50), -- The room of a mix, the mix of a track
+    title TEXT NOT NULL,
+    subtitle TEXT, -- Author, artist, location or department
+    body TEXT,
+    visible BOOLEAN NOT NULL DEFAULT false, -- Published/active content; only visible rows are searched
+    document TSVECTOR NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (resource_type, resource_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_search_documents_document ON search_documents USING gin(document);
+CREATE INDEX IF NOT EXISTS idx_search_documents_parent ON search_documents(parent_id);
+
+CREATE OR REPLACE FUNCTION search_upsert(p_type TEXT, p_id TEXT, p_parent TEXT, p_title TEXT, p_subtitle TEXT, p_body TEXT, p_visible BOOLEAN)
+RETURNS void AS $$
+BEGIN
+    INSERT INTO search_documents (resource_type, resource_id, parent_id, title, subtitle, body, visible, document, updated_at)
+    VALUES (
+        p_type, p_id, p_parent, COALESCE(p_title, ''), p_subtitle, p_body, COALESCE(p_visible, false),
+        setweight(to_tsvector('english', COALESCE(p_title, '')), 'A') ||
+        setweight(to_tsvector('english', COALESCE(p_subtitle, '')), 'B') ||
+        setweight(to_tsvector('english', COALESCE(p_body, '')), 'C'),
+        CURRENT_TIMESTAMP
+    )
+    ON CONFLICT (resource_type, resource_id) DO UPDATE SET
+        parent_id = EXCLUDED.parent_id,
+        title = EXCLUDED.title,
+        subtitle = EXCLUDED.subtitle,
+        body = EXCLUDED.body,
+        visible = EXCLUDED.visible,
+        document = EXCLUDED.document,
+        updated_at = CURRENT_TIMESTAMP;
+END;
+$$ LANGUAGE plpgsql;
+
+-- A mix is visible when it and its room are active; its tracks follow the mix
+CREATE OR REPLACE FUNCTION search_mix_visible(p_mix_id TEXT)
+RETURNS BOOLEAN AS $$
+    SELECT COALESCE(m.active, false) AND COALESCE(r.active, true)
+    FROM mixes m
+    LEFT JOIN rooms r ON r.id = m.room_id
+    WHERE m.id = p_mix_id;
+$$ LANGUAGE sql STABLE;
+
+CREATE OR REPLACE FUNCTION search_index() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        DELETE FROM search_documents
+        WHERE resource_type = CASE TG_TABLE_NAME WHEN 'merchandise' THEN 'merch' ELSE TG_TABLE_NAME END
+          AND resource_id = OLD.id;
+        RETURN OLD;
+    END IF;
+
+    CASE TG_TABLE_NAME
+        WHEN 'news' THEN
+            PERFORM search_upsert('news', NEW.id, NULL, NEW.title, NEW.author, NEW.content, NEW.published);
+        WHEN 'events' THEN
+            PERFORM search_upsert('events', NEW.id, NULL, NEW.title, NEW.location, NEW.description, NEW.active);
+        WHEN 'merchandise' THEN
+            PERFORM search_upsert('merch', NEW.id, NULL, NEW.name, NULL, NEW.description, NEW.active AND COALESCE(NEW.stock, 0) > 0);
+        WHEN 'careers' THEN
+            PERFORM search_upsert('careers', NEW.id, NULL, NEW.title, concat_ws(' - ', NEW.department, NEW.location), NEW.description, NEW.active);
+        WHEN 'mixes' THEN
+            PERFORM search_upsert('mixes', NEW.id, NEW.room_id, NEW.title, NEW.artist, NEW.description, search_mix_visible(NEW.id));
+            UPDATE search_documents SET visible = search_mix_visible(NEW.id)
+            WHERE resource_type = 'tracks' AND parent_id = NEW.id;
+        WHEN 'tracks' THEN
+            PERFORM search_upsert('tracks', NEW.id, NEW.mix_id, NEW.title, NEW.artist, NULL, search_mix_visible(NEW.mix_id));
+    END CASE;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Activating or deactivating a room shows or hides its mixes and their tracks
+CREATE OR REPLACE FUNCTION search_index_room() RETURNS trigger AS $$
+BEGIN
+    UPDATE search_documents SET visible = search_mix_visible(resource_id)
+    WHERE resource_type = 'mixes' AND parent_id = NEW.id;
+    UPDATE search_documents SET visible = search_mix_visible(parent_id)
+    WHERE resource_type = 'tracks' AND parent_id IN (SELECT id FROM mixes WHERE room_id = NEW.id);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS search_index ON news;
+CREATE TRIGGER search_index AFTER INSERT OR UPDATE OR DELETE ON news FOR EACH ROW EXECUTE FUNCTION search_index();
+DROP TRIGGER IF EXISTS search_index ON events;
+CREATE TRIGGER search_index AFTER INSERT OR UPDATE OR DELETE ON events FOR EACH ROW EXECUTE FUNCTION search_index();
+DROP TRIGGER IF EXISTS search_index ON merchandise;
+CREATE TRIGGER search_index AFTER INSERT OR UPDATE OR DELETE ON merchandise FOR EACH ROW EXECUTE FUNCTION search_index();
+DROP TRIGGER IF EXISTS search_index ON careers;
+CREATE TRIGGER search_index AFTER INSERT OR UPDATE OR DELETE ON careers FOR EACH ROW EXECUTE FUNCTION search_index();
+DROP TRIGGER IF EXISTS search_index ON mixes;
+CREATE TRIGGER search_index AFTER INSERT OR UPDATE OR DELETE ON mixes FOR EACH ROW EXECUTE FUNCTION search_index();
+DROP TRIGGER IF EXISTS search_index ON tracks;
+CREATE TRIGGER search_index AFTER INSERT OR UPDATE OR DELETE ON tracks FOR EACH ROW EXECUTE FUNCTION search_index();
+DROP TRIGGER IF EXISTS search_index_room ON rooms;
+CREATE TRIGGER search_index_room AFTER UPDATE OF active ON rooms FOR EACH ROW EXECUTE FUNCTION search_index_room();
+
+-- Index rows written before the triggers existed: a no-op update fires the trigger.
+-- Matches nothing once everything is indexed.
+UPDATE news SET id = id WHERE id NOT IN (SELECT resource_id FROM search_documents WHERE resource_type = 'news');
+UPDATE events SET id = id WHERE id NOT IN (SELECT resource_id FROM search_documents WHERE resource_type = 'events');
+UPDATE merchandise SET id = id WHERE id NOT IN (SELECT resource_id FROM search_documents WHERE resource_type = 'merch');
+UPDATE careers SET id = id WHERE id NOT IN (SELECT resource_id FROM search_documents WHERE resource_type = 'careers');
+UPDATE mixes SET id = id WHERE id NOT IN (SELECT resource_id FROM search_documents WHERE resource_type = 'mixes');
+UPDATE tracks SET id = id WHERE id NOT IN (SELECT resource_id FROM search_documents WHERE resource_type = 'tracks');
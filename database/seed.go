@@ -1,9 +1,13 @@
 package database
 
 import (
+	"crypto/rand"
 	"database/sql"
+	"encoding/base64"
 	"fmt"
 	"log"
+	"os"
+	"playtz-api/models"
 
 	"github.com/google/uuid"
 	"github.com/lib/pq"
@@ -24,22 +28,9 @@ func SeedAdmin() error {
 	if err == sql.ErrNoRows {
 		// Create admin role
 		adminRoleID = uuid.New().String()
-		adminPermissions := []string{
-			"users.read", "users.write", "users.delete",
-			"roles.read", "roles.write", "roles.delete",
-			"news.read", "news.write", "news.delete",
-			"events.read", "events.write", "events.delete",
-			"mixes.read", "mixes.write", "mixes.delete",
-			"merchandise.read", "merchandise.write", "merchandise.delete",
-			"orders.read", "orders.write", "orders.delete",
-			"careers.read", "careers.write", "careers.delete",
-			"rooms.read", "rooms.write", "rooms.delete",
-			"admin.dashboard", "admin.settings",
-		}
-
 		_, err = DB.Exec(
 			"INSERT INTO roles (id, name, description, permissions, active) VALUES ($1, $2, $3, $4, $5)",
-			adminRoleID, "admin", "Administrator with full system access", pq.Array(adminPermissions), true,
+			adminRoleID, "admin", "Administrator with full system access", pq.Array(models.AllPermissions), true,
 		)
 		if err != nil {
 			return fmt.Errorf("failed to create admin role: %w", err)
@@ -48,17 +39,33 @@ func SeedAdmin() error {
 	} else if err != nil {
 		return fmt.Errorf("error checking for admin role: %w", err)
 	} else {
-		log.Println("✅ Admin role exists")
+		// Keep the admin role in sync with the permission catalogue
+		_, err = DB.Exec(
+			"UPDATE roles SET permissions = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2",
+			pq.Array(models.AllPermissions), adminRoleID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to update admin role permissions: %w", err)
+		}
+		log.Println("✅ Admin role exists - permissions synced")
 	}
 
 	// Check if admin user exists
-	var adminUserID string
-	err = DB.QueryRow("SELECT id FROM users WHERE username = 'admin' OR email = 'admin@playtz.com'").Scan(&adminUserID)
+	var adminUserID, adminPasswordHash string
+	err = DB.QueryRow("SELECT id, password_hash FROM users WHERE username = 'admin' OR email = 'admin@playtz.com'").Scan(&adminUserID, &adminPasswordHash)
 
 	if err == sql.ErrNoRows {
-		// Create admin user
+		// Create admin user with ADMIN_INITIAL_PASSWORD or a generated password.
+		// Either way it must be changed on first login.
 		adminUserID = uuid.New().String()
-		password := "admin123" // Default password
+		password := os.Getenv("ADMIN_INITIAL_PASSWORD")
+		generated := password == ""
+		if generated {
+			password, err = generateInitialPassword()
+			if err != nil {
+				return fmt.Errorf("failed to generate admin password: %w", err)
+			}
+		}
 
 		// Hash password
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -68,33 +75,82 @@ func SeedAdmin() error {
 
 		_, err = DB.Exec(
 			"INSERT INTO users (id, email, username, password_hash, first_name, last_name, role_id, active, password_change_required) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)",
-			adminUserID, "admin@playtz.com", "admin", string(hashedPassword), "Admin", "User", adminRoleID, true, false,
+			adminUserID, "admin@playtz.com", "admin", string(hashedPassword), "Admin", "User", adminRoleID, true, true,
 		)
 		if err != nil {
 			return fmt.Errorf("failed to create admin user: %w", err)
 		}
-		log.Println("✅ Created admin user (username: admin, password: admin123)")
+		if generated {
+			// Printed once - the password is not stored anywhere else
+			log.Printf("✅ Created admin user (username: admin, initial password: %s) - change it on first login", password)
+		} else {
+			log.Println("✅ Created admin user (username: admin, password from ADMIN_INITIAL_PASSWORD) - change it on first login")
+		}
 	} else if err != nil {
 		return fmt.Errorf("error checking for admin user: %w", err)
 	} else {
-		// Admin user exists - ensure it has admin role and correct password
-		password := "admin123"
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-		if err != nil {
-			return fmt.Errorf("failed to hash password: %w", err)
-		}
-
-		// Update admin user to ensure correct role and password
+		// Admin user exists - ensure it has the admin role. The password is left alone and an
+		// admin deactivated by an operator stays deactivated; use the password reset flow if it is lost.
 		_, err = DB.Exec(
-			"UPDATE users SET role_id = $1, password_hash = $2, active = true, password_change_required = false, updated_at = CURRENT_TIMESTAMP WHERE id = $3",
-			adminRoleID, string(hashedPassword), adminUserID,
+			"UPDATE users SET role_id = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2",
+			adminRoleID, adminUserID,
 		)
 		if err != nil {
 			return fmt.Errorf("failed to update admin user: %w", err)
 		}
-		log.Println("✅ Admin user exists - password reset to admin123 and role verified")
+		log.Println("✅ Admin user exists - role verified")
+
+		// Older versions reset the admin password to a well-known default on every start
+		if hasDefaultPassword(adminPasswordHash) {
+			_, err = DB.Exec(
+				"UPDATE users SET password_change_required = true, updated_at = CURRENT_TIMESTAMP WHERE id = $1",
+				adminUserID,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to flag admin password: %w", err)
+			}
+			log.Println("⚠️  Admin user still has a default password - it must be changed on next login")
+		}
 	}
 
 	return nil
 }
 
+// defaultAdminPasswords are passwords earlier versions or setup guides gave the admin user
+var defaultAdminPasswords = []string{"admin123", "admin", "password", "changeme"}
+
+// hasDefaultPassword reports whether a bcrypt hash is of one of defaultAdminPasswords
+func hasDefaultPassword(hash string) bool {
+	for _, password := range defaultAdminPasswords {
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// generateInitialPassword returns a random password with upper, lower, digit and symbol characters
+func generateInitialPassword() (string, error) {
+	b := make([]byte, 15)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b) + "Aa1!", nil
+}
+
+// SeedListenerRole creates the role given to self-registered listeners if it doesn't exist.
+// It has no permissions; listeners are also kept off staff routes by their account type.
+func SeedListenerRole() error {
+	if DB == nil {
+		return fmt.Errorf("database connection not initialized")
+	}
+
+	_, err := DB.Exec(
+		"INSERT INTO roles (id, name, description, permissions, active) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (name) DO NOTHING",
+		uuid.New().String(), models.ListenerRoleName, "Self-registered listener account (no staff access)", pq.Array([]string{}), true,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create listener role: %w", err)
+	}
+	return nil
+}
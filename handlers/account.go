@@ -0,0 +1,331 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"playtz-api/apierr"
+	"playtz-api/auth"
+	"playtz-api/database"
+	"playtz-api/mailer"
+	"playtz-api/models"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// RegisterRequest represents a listener self-registration
+type RegisterRequest struct {
+	Email     string `json:"email" binding:"required,email,max=255"`
+	Password  string `json:"password" binding:"required"`
+	Username  string `json:"username" binding:"max=100"` // Optional - derived from the email when empty
+	FirstName string `json:"first_name" binding:"max=100"`
+	LastName  string `json:"last_name" binding:"max=100"`
+}
+
+// VerifyEmailRequest confirms an email address with an emailed token
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// Profile is the signed-in account as seen by its owner
+type Profile struct {
+	ID            string `json:"id"`
+	Email         string `json:"email"`
+	Username      string `json:"username"`
+	FirstName     string `json:"first_name"`
+	LastName      string `json:"last_name"`
+	AccountType   string `json:"account_type"`
+	EmailVerified bool   `json:"email_verified"`
+	CreatedAt     string `json:"created_at"`
+}
+
+// UpdateProfileRequest represents a profile update by the account owner
+type UpdateProfileRequest struct {
+	FirstName string `json:"first_name" binding:"max=100"`
+	LastName  string `json:"last_name" binding:"max=100"`
+}
+
+// profileFields are the members a PUT of the profile must send
+var profileFields = []string{"first_name", "last_name"}
+
+// registeredMessage is the answer to every registration, so it does not reveal which emails have accounts
+const registeredMessage = "Check your email to verify your address before logging in."
+
+// Register creates a listener account with the listener role and emails a verification link.
+// The account cannot log in until the email address is verified. If the email already has an
+// account, its owner is emailed a notice instead and the response is the same.
+func Register(c *gin.Context) {
+	var req RegisterRequest
+	if !bindJSON(c, &req, "A valid email and a password are required") {
+		return
+	}
+	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
+	req.Username = strings.TrimSpace(req.Username)
+
+	if !checkPasswordPolicy(c, "", req.Password) {
+		return
+	}
+
+	// Hash before the lookup so both outcomes take as long
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		apierr.Fail(c, "Failed to hash password", err)
+		return
+	}
+
+	var existingEmail, existingFirstName string
+	err = database.DB.QueryRow(
+		"SELECT email, COALESCE(first_name, '') FROM users WHERE LOWER(email) = $1",
+		req.Email,
+	).Scan(&existingEmail, &existingFirstName)
+	if err == nil {
+		go func() {
+			if err := mailer.Get().Send(accountExistsEmail(existingEmail, existingFirstName)); err != nil {
+				log.Printf("Failed to send account exists email: %v", err)
+			}
+		}()
+		c.JSON(202, gin.H{"message": registeredMessage})
+		return
+	}
+	if err != sql.ErrNoRows {
+		apierr.Fail(c, "Failed to create account", err)
+		return
+	}
+
+	var exists bool
+	username := req.Username
+	if username == "" {
+		var err error
+		if username, err = uniqueUsername(req.Email); err != nil {
+			apierr.Fail(c, "Failed to create account", err)
+			return
+		}
+	} else {
+		database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE username = $1)", username).Scan(&exists)
+		if exists {
+			apierr.Conflict(c, "Username is already taken")
+			return
+		}
+	}
+
+	var roleID string
+	err = database.DB.QueryRow("SELECT id FROM roles WHERE name = $1", models.ListenerRoleName).Scan(&roleID)
+	if err != nil {
+		apierr.Fail(c, "Registration is not available", err)
+		return
+	}
+
+	userID := uuid.New().String()
+	_, err = database.DB.Exec(`
+		INSERT INTO users (id, email, username, password_hash, first_name, last_name, role_id, active,
+		                   account_type, email_verified)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, true, $8, false)
+	`, userID, req.Email, username, string(hashedPassword), req.FirstName, req.LastName, roleID, models.AccountTypeListener)
+	if err != nil {
+		apierr.Fail(c, "Failed to create account", err)
+		return
+	}
+
+	if err := sendVerificationEmail(userID, req.Email, req.FirstName); err != nil {
+		log.Printf("Failed to send verification email: %v", err)
+	}
+
+	c.JSON(202, gin.H{"message": registeredMessage})
+}
+
+// VerifyEmail confirms a listener's email address using an emailed token
+func VerifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+	if !bindJSON(c, &req, "Token is required") {
+		return
+	}
+
+	userID, err := auth.ConsumeEmailVerificationToken(req.Token)
+	if err == auth.ErrVerificationTokenInvalid {
+		apierr.BadRequest(c, "Invalid or expired verification token")
+		return
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to verify email", err)
+		return
+	}
+
+	_, err = database.DB.Exec(
+		"UPDATE users SET email_verified = true, updated_at = CURRENT_TIMESTAMP WHERE id = $1",
+		userID,
+	)
+	if err != nil {
+		apierr.Fail(c, "Failed to verify email", err)
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "Email verified. You can now log in."})
+}
+
+// ResendVerification emails a new verification link to an unverified listener account.
+// The response is the same whether or not the account exists.
+func ResendVerification(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if !bindJSON(c, &req, "A valid email address is required") {
+		return
+	}
+
+	var userID, email, firstName string
+	err := database.DB.QueryRow(`
+		SELECT id, email, COALESCE(first_name, '') FROM users
+		WHERE LOWER(email) = LOWER($1) AND account_type = $2 AND COALESCE(email_verified, false) = false AND active = true
+	`, req.Email, models.AccountTypeListener).Scan(&userID, &email, &firstName)
+
+	if err == nil {
+		if err := sendVerificationEmail(userID, email, firstName); err != nil {
+			log.Printf("Failed to send verification email: %v", err)
+		}
+	}
+
+	c.JSON(200, gin.H{"message": "If an unverified account with that email exists, a new link has been sent"})
+}
+
+// GetProfile returns the signed-in account
+func GetProfile(c *gin.Context) {
+	profile, err := loadProfile(c.GetString("user_id"))
+	if err == sql.ErrNoRows {
+		apierr.NotFound(c, "Account not found")
+		return
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch profile", err)
+		return
+	}
+
+	c.JSON(200, profile)
+}
+
+// UpdateProfile replaces the name of the signed-in account
+func UpdateProfile(c *gin.Context) {
+	var req UpdateProfileRequest
+	if !replaceJSON(c, &req, "Invalid request body", profileFields...) {
+		return
+	}
+
+	saveProfile(c, c.GetString("user_id"), &req)
+}
+
+// PatchProfile changes the parts of the signed-in account's name sent in a JSON merge patch
+func PatchProfile(c *gin.Context) {
+	profile, err := loadProfile(c.GetString("user_id"))
+	if err == sql.ErrNoRows {
+		apierr.NotFound(c, "Account not found")
+		return
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch profile", err)
+		return
+	}
+
+	req := UpdateProfileRequest{FirstName: profile.FirstName, LastName: profile.LastName}
+	if !patchJSON(c, &req, "Invalid request body") {
+		return
+	}
+
+	saveProfile(c, profile.ID, &req)
+}
+
+// saveProfile writes req over the name of the account and answers with its profile
+func saveProfile(c *gin.Context, userID string, req *UpdateProfileRequest) {
+	_, err := database.DB.Exec(
+		"UPDATE users SET first_name = $1, last_name = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $3",
+		req.FirstName, req.LastName, userID,
+	)
+	if err != nil {
+		apierr.Fail(c, "Failed to update profile", err)
+		return
+	}
+
+	profile, err := loadProfile(userID)
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch profile", err)
+		return
+	}
+
+	c.JSON(200, profile)
+}
+
+// loadProfile fetches the profile of an account by ID
+func loadProfile(userID string) (*Profile, error) {
+	var profile Profile
+	var createdAt time.Time
+	err := database.DB.QueryRow(`
+		SELECT id, email, username, COALESCE(first_name, ''), COALESCE(last_name, ''),
+		       account_type, COALESCE(email_verified, false), created_at
+		FROM users WHERE id = $1
+	`, userID).Scan(&profile.ID, &profile.Email, &profile.Username, &profile.FirstName, &profile.LastName,
+		&profile.AccountType, &profile.EmailVerified, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	profile.CreatedAt = createdAt.Format(time.RFC3339)
+	return &profile, nil
+}
+
+// sendVerificationEmail issues a verification token and emails it in the background
+func sendVerificationEmail(userID, email, firstName string) error {
+	token, err := auth.CreateEmailVerificationToken(userID)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		if err := mailer.Get().Send(verificationEmail(email, firstName, token)); err != nil {
+			log.Printf("Failed to send verification email: %v", err)
+		}
+	}()
+	return nil
+}
+
+// accountExistsEmail tells the owner of an account that someone tried to register with its email
+func accountExistsEmail(email, firstName string) mailer.Message {
+	greeting := "Hello,"
+	if firstName != "" {
+		greeting = "Hello " + firstName + ","
+	}
+
+	return mailer.Message{
+		To:      email,
+		Subject: "You already have a Playtz 102.9 account",
+		Body: fmt.Sprintf(
+			"%s\n\nSomeone tried to create a new account with this email address, but you already have one. If it was you, log in instead, or use \"Forgot password\" if you cannot remember your password.\n\nIf it was not you, you can ignore this email. Your account has not been changed.\n",
+			greeting,
+		),
+	}
+}
+
+// verificationEmail builds the email verification email for a listener
+func verificationEmail(email, firstName, token string) mailer.Message {
+	// Link target is the website page that posts to /auth/verify-email
+	verifyURL := os.Getenv("EMAIL_VERIFICATION_URL")
+	if verifyURL == "" {
+		verifyURL = "https://playtzadmin.vercel.app/verify-email"
+	}
+	link := verifyURL + "?token=" + url.QueryEscape(token)
+
+	greeting := "Hello,"
+	if firstName != "" {
+		greeting = "Hello " + firstName + ","
+	}
+
+	return mailer.Message{
+		To:      email,
+		Subject: "Confirm your Playtz 102.9 account",
+		Body: fmt.Sprintf(
+			"%s\n\nThanks for signing up. Confirm your email address within %d hours using the link below:\n\n%s\n\nIf you did not create an account, you can ignore this email.\n",
+			greeting, int(auth.EmailVerificationTTL.Hours()), link,
+		),
+	}
+}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"playtz-api/apierr"
+	"playtz-api/auth"
 	"playtz-api/database"
 	"time"
 
@@ -14,6 +16,19 @@ type AdminDashboardData struct {
 	RecentNews   []NewsArticle `json:"recent_news,omitempty"`
 	RecentEvents []Event `json:"recent_events,omitempty"`
 	RecentOrders []Order `json:"recent_orders,omitempty"`
+	RecentLockouts []LoginLockout `json:"recent_lockouts,omitempty"`
+}
+
+// LoginLockout represents an account or client IP locked out after failed logins
+type LoginLockout struct {
+	ID          string  `json:"id"`
+	UserID      *string `json:"user_id,omitempty"`
+	Username    *string `json:"username,omitempty"`
+	IP          string  `json:"ip"`
+	Identifier  string  `json:"identifier"` // Username or email that was tried
+	LockedUntil string  `json:"locked_until"`
+	Active      bool    `json:"active"`
+	CreatedAt   string  `json:"created_at"`
 }
 
 // Stats represents dashboard statistics
@@ -24,6 +39,7 @@ type Stats struct {
 	TotalMerchandise int `json:"total_merchandise"`
 	TotalOrders     int `json:"total_orders"`
 	PendingOrders   int `json:"pending_orders"`
+	ActiveLockouts  int `json:"active_lockouts"`
 }
 
 // GetAdminDashboard returns dashboard data based on user role
@@ -50,7 +66,7 @@ func GetAdminDashboard(c *gin.Context) {
 	)
 
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to fetch user data"})
+		apierr.Fail(c, "Failed to fetch user data", err)
 		return
 	}
 
@@ -70,6 +86,7 @@ func GetAdminDashboard(c *gin.Context) {
 	database.DB.QueryRow("SELECT COUNT(*) FROM merchandise").Scan(&stats.TotalMerchandise)
 	database.DB.QueryRow("SELECT COUNT(*) FROM orders").Scan(&stats.TotalOrders)
 	database.DB.QueryRow("SELECT COUNT(*) FROM orders WHERE status = 'pending'").Scan(&stats.PendingOrders)
+	database.DB.QueryRow("SELECT COUNT(*) FROM login_lockouts WHERE locked_until > CURRENT_TIMESTAMP AND unlocked_at IS NULL").Scan(&stats.ActiveLockouts)
 
 	dashboardData := AdminDashboardData{
 		User:  user,
@@ -92,6 +109,11 @@ func GetAdminDashboard(c *gin.Context) {
 		dashboardData.RecentOrders = getRecentOrders(5)
 	}
 
+	// Lockouts are shown to anyone who can manage users
+	if permissions, err := auth.GetPermissionCache().Permissions(userIDStr); err == nil && permissions["users.read"] {
+		dashboardData.RecentLockouts = getRecentLockouts(10)
+	}
+
 	c.JSON(200, dashboardData)
 }
 
@@ -173,3 +195,29 @@ func getRecentOrders(limit int) []Order {
 	return orders
 }
 
+func getRecentLockouts(limit int) []LoginLockout {
+	rows, err := database.DB.Query(`
+		SELECT l.id, l.user_id, u.username, l.ip, l.identifier, l.locked_until,
+		       l.locked_until > CURRENT_TIMESTAMP AND l.unlocked_at IS NULL, l.created_at
+		FROM login_lockouts l
+		LEFT JOIN users u ON l.user_id = u.id
+		ORDER BY l.created_at DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return []LoginLockout{}
+	}
+	defer rows.Close()
+
+	var lockouts []LoginLockout
+	for rows.Next() {
+		var lockout LoginLockout
+		var lockedUntil, createdAt time.Time
+		rows.Scan(&lockout.ID, &lockout.UserID, &lockout.Username, &lockout.IP,
+			&lockout.Identifier, &lockedUntil, &lockout.Active, &createdAt)
+		lockout.LockedUntil = lockedUntil.Format(time.RFC3339)
+		lockout.CreatedAt = createdAt.Format(time.RFC3339)
+		lockouts = append(lockouts, lockout)
+	}
+	return lockouts
+}
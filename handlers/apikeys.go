@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"database/sql"
+	"playtz-api/apierr"
+	"playtz-api/auth"
+	"playtz-api/database"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// APIKey represents an API key for a machine client
+type APIKey struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	Scopes     []string `json:"scopes"`
+	Key        string   `json:"key,omitempty"` // Only returned when the key is created
+	ExpiresAt  *string  `json:"expires_at"`
+	LastUsedAt *string  `json:"last_used_at"`
+	RevokedAt  *string  `json:"revoked_at"`
+	CreatedBy  *string  `json:"created_by"`
+	CreatedAt  string   `json:"created_at"`
+}
+
+// CreateAPIKeyRequest represents a request to create an API key
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required,notblank,max=255"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1,dive,notblank"`
+	ExpiresAt *time.Time `json:"expires_at"` // Optional, RFC3339
+}
+
+const apiKeyColumns = "id, name, prefix, scopes, expires_at, last_used_at, revoked_at, created_by, created_at"
+
+// GetAPIKeys returns all API keys (never the keys themselves)
+func GetAPIKeys(c *gin.Context) {
+	rows, err := database.DB.Query("SELECT " + apiKeyColumns + " FROM api_keys ORDER BY created_at DESC")
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch API keys", err)
+		return
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			continue
+		}
+		keys = append(keys, *key)
+	}
+
+	c.JSON(200, keys)
+}
+
+// CreateAPIKey creates an API key scoped to a subset of permissions.
+// The key is returned once; only its hash is stored.
+func CreateAPIKey(c *gin.Context) {
+	var req CreateAPIKeyRequest
+	if !bindJSON(c, &req, "Name and scopes are required") {
+		return
+	}
+	if unknown := unknownPermissions(req.Scopes); len(unknown) > 0 {
+		apierr.BadRequest(c, "Unknown permissions: "+strings.Join(unknown, ", "))
+		return
+	}
+	if req.ExpiresAt != nil && req.ExpiresAt.Before(time.Now()) {
+		apierr.BadRequest(c, "Expiry must be in the future")
+		return
+	}
+
+	// A key can never do more than the caller who creates it
+	if !requireGrantable(c, req.Scopes) {
+		return
+	}
+
+	key, prefix, err := auth.GenerateAPIKey()
+	if err != nil {
+		apierr.Fail(c, "Failed to generate API key", err)
+		return
+	}
+
+	// A key created with another key belongs to that key's owner, whose permissions bound both
+	var createdBy interface{}
+	if userID := c.GetString("user_id"); userID != "" {
+		createdBy = userID
+	} else if ownerID := c.GetString("api_key_owner_id"); ownerID != "" {
+		createdBy = ownerID
+	}
+
+	id := uuid.New().String()
+	_, err = database.DB.Exec(
+		"INSERT INTO api_keys (id, name, prefix, key_hash, scopes, expires_at, created_by) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+		id, req.Name, prefix, auth.HashToken(key), pq.Array(req.Scopes), req.ExpiresAt, createdBy,
+	)
+	if err != nil {
+		apierr.Fail(c, "Failed to create API key", err)
+		return
+	}
+
+	apiKey, err := loadAPIKey(id)
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch API key", err)
+		return
+	}
+	apiKey.Key = key
+
+	c.JSON(201, apiKey)
+}
+
+// RevokeAPIKey revokes an API key; it stops working immediately
+func RevokeAPIKey(c *gin.Context) {
+	id := c.Param("id")
+
+	result, err := database.DB.Exec(
+		"UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND revoked_at IS NULL",
+		id,
+	)
+	if err != nil {
+		apierr.Fail(c, "Failed to revoke API key", err)
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		apierr.NotFound(c, "API key not found or already revoked")
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "API key revoked"})
+}
+
+// loadAPIKey fetches a single API key by ID
+func loadAPIKey(id string) (*APIKey, error) {
+	return scanAPIKey(database.DB.QueryRow("SELECT "+apiKeyColumns+" FROM api_keys WHERE id = $1", id))
+}
+
+// scanAPIKey scans a row selected with apiKeyColumns
+func scanAPIKey(row interface{ Scan(...interface{}) error }) (*APIKey, error) {
+	var key APIKey
+	var scopes pq.StringArray
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+	var createdAt time.Time
+	err := row.Scan(&key.ID, &key.Name, &key.Prefix, &scopes, &expiresAt, &lastUsedAt, &revokedAt, &key.CreatedBy, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	key.Scopes = []string(scopes)
+	key.ExpiresAt = formatNullTime(expiresAt)
+	key.LastUsedAt = formatNullTime(lastUsedAt)
+	key.RevokedAt = formatNullTime(revokedAt)
+	key.CreatedAt = createdAt.Format(time.RFC3339)
+	return &key, nil
+}
+
+// formatNullTime formats a nullable timestamp as RFC3339
+func formatNullTime(t sql.NullTime) *string {
+	if !t.Valid {
+		return nil
+	}
+	formatted := t.Time.Format(time.RFC3339)
+	return &formatted
+}
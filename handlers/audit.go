@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"playtz-api/apierr"
+	"playtz-api/audit"
+	"playtz-api/database"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditLogEntry represents one recorded request
+type AuditLogEntry struct {
+	ID           string          `json:"id"`
+	ActorID      string          `json:"actor_id,omitempty"`
+	ActorType    string          `json:"actor_type,omitempty"`
+	ActorName    string          `json:"actor_name,omitempty"`
+	Action       string          `json:"action"`
+	Method       string          `json:"method"`
+	Route        string          `json:"route"`
+	ResourceType string          `json:"resource_type,omitempty"`
+	ResourceID   string          `json:"resource_id,omitempty"`
+	StatusCode   int             `json:"status_code"`
+	Before       json.RawMessage `json:"before,omitempty"`
+	After        json.RawMessage `json:"after,omitempty"`
+	IP           string          `json:"ip,omitempty"`
+	UserAgent    string          `json:"user_agent,omitempty"`
+	CreatedAt    string          `json:"created_at"`
+}
+
+// auditFilters maps query parameters to audit_logs columns matched exactly
+var auditFilters = map[string]string{
+	"actor_id":      "actor_id",
+	"actor_type":    "actor_type",
+	"action":        "action",
+	"resource_type": "resource_type",
+	"resource_id":   "resource_id",
+	"status_code":   "status_code",
+}
+
+// GetAuditLogs returns audit entries, newest first.
+// Filters: actor_id, actor_type, action, resource_type, resource_id, status_code, from, to (RFC3339).
+// Pagination: page (default 1), limit (default 50, max 200).
+func GetAuditLogs(c *gin.Context) {
+	conditions := []string{}
+	args := []interface{}{}
+	for param, column := range auditFilters {
+		if value := c.Query(param); value != "" {
+			args = append(args, value)
+			conditions = append(conditions, fmt.Sprintf("%s = $%d", column, len(args)))
+		}
+	}
+	for param, operator := range map[string]string{"from": ">=", "to": "<"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			apierr.BadRequest(c, param+" must be an RFC3339 timestamp")
+			return
+		}
+		args = append(args, t)
+		conditions = append(conditions, fmt.Sprintf("created_at %s $%d", operator, len(args)))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	var total int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM audit_logs "+where, args...).Scan(&total); err != nil {
+		apierr.Fail(c, "Failed to fetch audit log", err)
+		return
+	}
+
+	args = append(args, limit, (page-1)*limit)
+	rows, err := database.DB.Query(fmt.Sprintf(`
+		SELECT id, actor_id, actor_type, actor_name, action, method, route, resource_type, resource_id,
+		       status_code, before, after, ip, user_agent, created_at
+		FROM audit_logs
+		%s
+		ORDER BY created_at DESC
+		LIMIT $%d OFFSET $%d
+	`, where, len(args)-1, len(args)), args...)
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch audit log", err)
+		return
+	}
+	defer rows.Close()
+
+	entries := []AuditLogEntry{}
+	for rows.Next() {
+		var entry AuditLogEntry
+		var actorID, actorType, actorName, resourceType, resourceID, ip, userAgent sql.NullString
+		var before, after []byte
+		var createdAt time.Time
+		err := rows.Scan(&entry.ID, &actorID, &actorType, &actorName, &entry.Action, &entry.Method, &entry.Route,
+			&resourceType, &resourceID, &entry.StatusCode, &before, &after, &ip, &userAgent, &createdAt)
+		if err != nil {
+			continue
+		}
+		entry.ActorID = actorID.String
+		entry.ActorType = actorType.String
+		entry.ActorName = actorName.String
+		entry.ResourceType = resourceType.String
+		entry.ResourceID = resourceID.String
+		entry.Before = before
+		entry.After = after
+		entry.IP = ip.String
+		entry.UserAgent = userAgent.String
+		entry.CreatedAt = createdAt.Format(time.RFC3339)
+		entries = append(entries, entry)
+	}
+
+	c.JSON(200, gin.H{
+		"data": entries,
+		"pagination": gin.H{
+			"page":        page,
+			"limit":       limit,
+			"total":       total,
+			"total_pages": (total + limit - 1) / limit,
+		},
+	})
+}
+
+// recordAccountEvent audits a security change to a user account made through the auth endpoints,
+// which the Audit middleware (staff routes only) never sees. The actor is the signed-in caller,
+// or the account itself for flows without a session such as a password reset or an SSO login.
+func recordAccountEvent(c *gin.Context, action, userID string) {
+	entry := audit.Entry{
+		ActorID:      userID,
+		ActorType:    "user",
+		Action:       action,
+		Method:       c.Request.Method,
+		Route:        c.FullPath(),
+		ResourceType: "users",
+		ResourceID:   userID,
+		StatusCode:   200,
+		IP:           c.ClientIP(),
+		UserAgent:    c.Request.UserAgent(),
+	}
+	if callerID := c.GetString("user_id"); callerID != "" {
+		entry.ActorID, entry.ActorName = callerID, c.GetString("username")
+	} else {
+		database.DB.QueryRow("SELECT username FROM users WHERE id = $1", userID).Scan(&entry.ActorName)
+	}
+
+	audit.Record(entry)
+}
@@ -2,8 +2,15 @@ package handlers
 
 import (
 	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"playtz-api/apierr"
 	"playtz-api/auth"
 	"playtz-api/database"
+	"playtz-api/middleware"
+	"playtz-api/models"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -18,18 +25,39 @@ type LoginRequest struct {
 
 // LoginResponse represents the login response
 type LoginResponse struct {
-	Success   bool   `json:"success"`
-	Message   string `json:"message"`
-	Token     string `json:"token,omitempty"`     // JWT token
-	SessionID string `json:"session_id,omitempty"` // Deprecated: use token instead
-	User      *User  `json:"user,omitempty"`
+	Success      bool   `json:"success"`
+	Message      string `json:"message"`
+	Token        string `json:"token,omitempty"`         // JWT access token
+	RefreshToken string `json:"refresh_token,omitempty"` // Opaque token for /auth/refresh
+	ExpiresIn    int    `json:"expires_in,omitempty"`    // Access token lifetime in seconds
+	SessionID    string `json:"session_id,omitempty"`    // Deprecated: use token instead
+	User         *User  `json:"user,omitempty"`
+
+	// Set instead of tokens when the user has 2FA enabled; complete via /auth/2fa/login
+	TwoFactorRequired bool   `json:"two_factor_required,omitempty"`
+	ChallengeToken    string `json:"challenge_token,omitempty"`
+
+	// The token only works on /auth/change-password until the password is changed
+	PasswordChangeRequired bool `json:"password_change_required,omitempty"`
+}
+
+// RefreshRequest represents a token refresh request
+// The refresh_token cookie is used when the body is empty
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
 }
 
 // Login handles user authentication
 func Login(c *gin.Context) {
 	var req LoginRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": "Username and password are required"})
+	if !bindJSON(c, &req, "Username and password are required") {
+		return
+	}
+
+	// Refuse early if this client IP is backing off or locked out
+	limiter := auth.GetLoginLimiter()
+	ipKey := auth.IPKey(c.ClientIP())
+	if loginBlocked(c, limiter, ipKey) {
 		return
 	}
 
@@ -38,38 +66,54 @@ func Login(c *gin.Context) {
 	var passwordHash string
 	var createdAt, updatedAt time.Time
 	var roleName *string
+	var emailVerified bool
 
 	err := database.DB.QueryRow(`
 		SELECT u.id, u.email, u.username, u.password_hash, u.first_name, u.last_name, 
-		       u.role_id, u.active, u.created_at, u.updated_at, r.name as role_name
+		       u.role_id, u.active, u.account_type, COALESCE(u.email_verified, false),
+		       u.created_at, u.updated_at, r.name as role_name
 		FROM users u
 		LEFT JOIN roles r ON u.role_id = r.id
 		WHERE u.username = $1 OR u.email = $1
 	`, req.Username).Scan(
 		&user.ID, &user.Email, &user.Username, &passwordHash,
 		&user.FirstName, &user.LastName, &user.RoleID, &user.Active,
+		&user.AccountType, &emailVerified,
 		&createdAt, &updatedAt, &roleName,
 	)
 
 	if err == sql.ErrNoRows {
-		c.JSON(401, gin.H{"error": "Invalid username or password"})
+		recordLoginFailure(limiter, "", req.Username, c.ClientIP())
+		apierr.Unauthorized(c, "Invalid username or password")
 		return
 	}
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to authenticate user"})
+		apierr.Fail(c, "Failed to authenticate user", err)
+		return
+	}
+
+	// A locked account stays locked even if the right password is supplied
+	if loginBlocked(c, limiter, auth.AccountKey(user.ID)) {
 		return
 	}
 
 	// Check if user is active
 	if !user.Active {
-		c.JSON(403, gin.H{"error": "Account is inactive"})
+		apierr.Forbidden(c, "Account is inactive")
 		return
 	}
 
 	// Verify password
 	err = bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(req.Password))
 	if err != nil {
-		c.JSON(401, gin.H{"error": "Invalid username or password"})
+		recordLoginFailure(limiter, user.ID, req.Username, c.ClientIP())
+		apierr.Unauthorized(c, "Invalid username or password")
+		return
+	}
+
+	// Listeners must confirm their email address before the first login
+	if user.AccountType == models.AccountTypeListener && !emailVerified {
+		apierr.Respond(c, apierr.New(403, apierr.CodeEmailNotVerified, "Email address not verified - check your inbox or request a new link").With("email_verification_required", true))
 		return
 	}
 
@@ -80,34 +124,325 @@ func Login(c *gin.Context) {
 	user.CreatedAt = createdAt.Format(time.RFC3339)
 	user.UpdatedAt = updatedAt.Format(time.RFC3339)
 
-	// Generate JWT token
-	token, err := auth.GenerateToken(
-		user.ID,
-		user.Username,
-		user.Email,
-		user.RoleID,
-		user.RoleName,
-	)
+	// Users with 2FA enabled must complete a second step before getting tokens
+	var totpEnabled bool
+	database.DB.QueryRow("SELECT COALESCE(totp_enabled, false) FROM users WHERE id = $1", user.ID).Scan(&totpEnabled)
+	if totpEnabled {
+		challengeToken, err := auth.GenerateChallengeToken(user.ID)
+		if err != nil {
+			apierr.Fail(c, "Failed to generate two-factor challenge", err)
+			return
+		}
+		c.JSON(200, LoginResponse{
+			Success:           false,
+			Message:           "Two-factor authentication required",
+			TwoFactorRequired: true,
+			ChallengeToken:    challengeToken,
+		})
+		return
+	}
+
+	// The IP counter is left alone so one valid account cannot be used to reset it. With 2FA
+	// the account is only reset once the code is checked (see TwoFactorLogin).
+	if err := limiter.Reset(auth.AccountKey(user.ID)); err != nil {
+		log.Printf("Failed to reset login attempts for user %s: %v", user.ID, err)
+	}
+
+	completeLogin(c, &user, "Login successful")
+}
+
+// loginBlocked writes a 429 with Retry-After if the limiter key must wait
+func loginBlocked(c *gin.Context, limiter *auth.LoginLimiter, key string) bool {
+	wait, err := limiter.Blocked(key)
+	if err != nil {
+		apierr.Fail(c, "Failed to authenticate user", err)
+		return true
+	}
+	if wait <= 0 {
+		return false
+	}
+
+	retryAfter := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	apierr.Respond(c, apierr.New(429, apierr.CodeRateLimited, "Too many failed login attempts - please try again later").With("retry_after", retryAfter))
+	return true
+}
+
+// recordLoginFailure counts a failed login against the client IP (unless per-IP limits are off)
+// and, when known, the account. Crossing the failure threshold locks the key out and records the lockout.
+func recordLoginFailure(limiter *auth.LoginLimiter, userID, identifier, ip string) {
+	if limiter.MaxIPFailures > 0 {
+		if record, locked, err := limiter.Fail(auth.IPKey(ip), limiter.MaxIPFailures); err != nil {
+			log.Printf("Failed to record login failure for IP %s: %v", ip, err)
+		} else if locked {
+			if err := auth.RecordLockout("", ip, identifier, record.LockedUntil); err != nil {
+				log.Printf("Failed to record lockout: %v", err)
+			}
+		}
+	}
+
+	if userID == "" {
+		return
+	}
+	if record, locked, err := limiter.Fail(auth.AccountKey(userID), limiter.MaxAccountFailures); err != nil {
+		log.Printf("Failed to record login failure for user %s: %v", userID, err)
+	} else if locked {
+		if err := auth.RecordLockout(userID, ip, identifier, record.LockedUntil); err != nil {
+			log.Printf("Failed to record lockout: %v", err)
+		}
+	}
+}
+
+// completeLogin issues an access token and a new refresh token family, sets the
+// auth cookies and writes the login response
+func completeLogin(c *gin.Context, user *User, message string) {
+	tokens, err := issueLoginTokens(c, user)
+	if err != nil {
+		apierr.Fail(c, "Failed to generate token", err)
+		return
+	}
+	respondLogin(c, user, tokens, message)
+}
+
+// respondLogin answers with the tokens of a new session
+func respondLogin(c *gin.Context, user *User, tokens *loginTokens, message string) {
+	c.JSON(200, LoginResponse{
+		Success:      true,
+		Message:      message,
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    int(auth.AccessTokenTTL.Seconds()),
+		SessionID:    tokens.AccessToken, // Return token as session_id for backward compatibility
+		User:         user,
+
+		PasswordChangeRequired: tokens.Claims.PasswordChangeRequired,
+	})
+}
+
+// loginTokens are the credentials issued by a successful login
+type loginTokens struct {
+	AccessToken  string
+	RefreshToken string
+	Claims       *auth.JWTClaims
+}
+
+// issueLoginTokens records a new session for the login, issues its access token and
+// first refresh token and sets the auth cookies
+func issueLoginTokens(c *gin.Context, user *User) (*loginTokens, error) {
+	sessionID, err := auth.StartSession(user.ID, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		return nil, err
+	}
+	return issueSessionTokens(c, user, sessionID)
+}
+
+// issueSessionTokens issues the access token and first refresh token of a started session
+// and sets the auth cookies
+func issueSessionTokens(c *gin.Context, user *User, sessionID string) (*loginTokens, error) {
+	token, claims, err := generateAccessToken(user, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The session ID doubles as the refresh token family
+	refreshToken, err := auth.IssueRefreshToken(user.ID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	setAuthCookies(c, token, refreshToken)
+
+	return &loginTokens{AccessToken: token, RefreshToken: refreshToken, Claims: claims}, nil
+}
+
+// generateAccessToken issues an access token for a session reflecting the user's current security state
+func generateAccessToken(user *User, sessionID string) (string, *auth.JWTClaims, error) {
+	var totpEnabled, require2FA, passwordChangeRequired bool
+	var accountType string
+	err := database.DB.QueryRow(`
+		SELECT COALESCE(u.totp_enabled, false), COALESCE(r.require_2fa, false),
+		       COALESCE(u.password_change_required, false), u.account_type
+		FROM users u
+		LEFT JOIN roles r ON u.role_id = r.id
+		WHERE u.id = $1
+	`, user.ID).Scan(&totpEnabled, &require2FA, &passwordChangeRequired, &accountType)
+	if err != nil {
+		return "", nil, err
+	}
+
+	claims := &auth.JWTClaims{
+		UserID:                 user.ID,
+		Username:               user.Username,
+		Email:                  user.Email,
+		RoleID:                 user.RoleID,
+		RoleName:               user.RoleName,
+		AccountType:            accountType,
+		SessionID:              sessionID,
+		TwoFactorSetupRequired: require2FA && !totpEnabled,
+		PasswordChangeRequired: passwordChangeRequired,
+	}
+	token, err := auth.GenerateTokenWithClaims(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, claims, nil
+}
+
+// Refresh exchanges a refresh token for a new access token and a rotated refresh token
+func Refresh(c *gin.Context) {
+	var req RefreshRequest
+	// Body is optional - browsers send the refresh_token cookie instead
+	_ = c.ShouldBindJSON(&req)
+
+	refreshToken := req.RefreshToken
+	if refreshToken == "" {
+		refreshToken, _ = c.Cookie("refresh_token")
+		// Browsers send the cookie on cross-site requests too
+		if refreshToken != "" && !middleware.CheckCSRF(c) {
+			return
+		}
+	}
+	if refreshToken == "" {
+		apierr.Unauthorized(c, "Refresh token required")
+		return
+	}
+
+	userID, sessionID, newRefreshToken, err := auth.RotateRefreshToken(refreshToken)
+	if err == auth.ErrRefreshTokenReused {
+		clearAuthCookies(c)
+		apierr.Unauthorized(c, "Refresh token already used - all sessions from this login were revoked")
+		return
+	}
+	if err == auth.ErrRefreshTokenInvalid {
+		clearAuthCookies(c)
+		apierr.Unauthorized(c, "Invalid or expired refresh token")
+		return
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to refresh token", err)
+		return
+	}
+
+	user, err := loadUser(userID)
+	if err == sql.ErrNoRows || (err == nil && !user.Active) {
+		auth.RevokeRefreshTokenFamily(newRefreshToken)
+		clearAuthCookies(c)
+		apierr.Unauthorized(c, "Account is inactive or no longer exists")
+		return
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch user", err)
+		return
+	}
+
+	token, claims, err := generateAccessToken(user, sessionID)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to generate token"})
+		apierr.Fail(c, "Failed to generate token", err)
 		return
 	}
 
-	// Set JWT token as cookie
-	// For cross-origin (Vercel), we need SameSite=None and Secure=true
-	c.Header("Set-Cookie", "token="+token+"; Path=/; Max-Age=86400; HttpOnly; Secure; SameSite=None")
+	if err := auth.TouchSession(sessionID, c.ClientIP()); err != nil {
+		log.Printf("Failed to update session %s: %v", sessionID, err)
+	}
+
+	setAuthCookies(c, token, newRefreshToken)
 
 	c.JSON(200, LoginResponse{
-		Success:   true,
-		Message:   "Login successful",
-		Token:     token,
-		SessionID: token, // Return token as session_id for backward compatibility
-		User:      &user,
+		Success:      true,
+		Message:      "Token refreshed",
+		Token:        token,
+		RefreshToken: newRefreshToken,
+		ExpiresIn:    int(auth.AccessTokenTTL.Seconds()),
+		User:         user,
+
+		PasswordChangeRequired: claims.PasswordChangeRequired,
 	})
 }
 
+// setAuthCookies sets the access token and refresh token cookies (an empty refreshToken leaves it untouched)
+// For cross-origin (Vercel), we need SameSite=None and Secure=true
+func setAuthCookies(c *gin.Context, token, refreshToken string) {
+	c.Writer.Header().Add("Set-Cookie", fmt.Sprintf(
+		"token=%s; Path=/; Max-Age=%d; HttpOnly; Secure; SameSite=None",
+		token, int(auth.AccessTokenTTL.Seconds()),
+	))
+	if refreshToken == "" {
+		return
+	}
+	// The refresh token is only ever sent to the auth endpoints
+	c.Writer.Header().Add("Set-Cookie", fmt.Sprintf(
+		"refresh_token=%s; Path=/api/v1/auth; Max-Age=%d; HttpOnly; Secure; SameSite=None",
+		refreshToken, int(auth.RefreshTokenTTL.Seconds()),
+	))
+}
+
+// GetCSRFToken returns the CSRF token to send as X-CSRF-Token on cookie-authenticated
+// POST/PUT/PATCH/DELETE requests, issuing a new csrf_token cookie if there is none yet
+func GetCSRFToken(c *gin.Context) {
+	token, err := c.Cookie(middleware.CSRFCookieName)
+	if err != nil || token == "" {
+		token, err = auth.GenerateOpaqueToken()
+		if err != nil {
+			apierr.Fail(c, "Failed to generate CSRF token", err)
+			return
+		}
+		c.Writer.Header().Add("Set-Cookie", fmt.Sprintf(
+			"%s=%s; Path=/; Max-Age=%d; HttpOnly; Secure; SameSite=None",
+			middleware.CSRFCookieName, token, int(auth.RefreshTokenTTL.Seconds()),
+		))
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(200, gin.H{
+		"csrf_token": token,
+		"header":     middleware.CSRFHeaderName,
+	})
+}
+
+// clearAuthCookies expires the access token and refresh token cookies
+func clearAuthCookies(c *gin.Context) {
+	c.Writer.Header().Add("Set-Cookie", "token=; Path=/; Max-Age=0; HttpOnly; Secure; SameSite=None")
+	c.Writer.Header().Add("Set-Cookie", "refresh_token=; Path=/api/v1/auth; Max-Age=0; HttpOnly; Secure; SameSite=None")
+}
+
 // Logout handles user logout
 func Logout(c *gin.Context) {
+	// Tokens sent by the client win over cookies; cookies, which browsers also send on
+	// cross-site requests, are only used with a valid CSRF token
+	token := auth.ExtractTokenFromHeader(c.GetHeader("Authorization"))
+	var req RefreshRequest
+	_ = c.ShouldBindJSON(&req)
+	refreshToken := req.RefreshToken
+
+	cookieToken, _ := c.Cookie("token")
+	cookieRefreshToken, _ := c.Cookie("refresh_token")
+	if (token == "" && cookieToken != "") || (refreshToken == "" && cookieRefreshToken != "") {
+		if !middleware.CheckCSRF(c) {
+			return
+		}
+		if token == "" {
+			token = cookieToken
+		}
+		if refreshToken == "" {
+			refreshToken = cookieRefreshToken
+		}
+	}
+
+	// Revoke the access token server-side so a copied token stops working
+	if token != "" {
+		if claims, err := auth.ValidateToken(token); err == nil {
+			auth.RevokeToken(claims)
+			if claims.SessionID != "" {
+				auth.RevokeSession(claims.UserID, claims.SessionID)
+			}
+		}
+	}
+
+	// Revoke the refresh token family so the login cannot be renewed
+	if refreshToken != "" {
+		auth.RevokeRefreshTokenFamily(refreshToken)
+	}
+
 	// Clear JWT token cookie
 	// Set multiple cookie clearing headers to ensure it works across browsers
 	c.Header("Set-Cookie", "token=; Path=/; Max-Age=0; HttpOnly; Secure; SameSite=None")
@@ -119,6 +454,7 @@ func Logout(c *gin.Context) {
 	// Additional cookie clearing for better compatibility
 	c.SetCookie("token", "", -1, "/", "", true, true)
 	c.SetCookie("session_id", "", -1, "/", "", true, true)
+	c.SetCookie("refresh_token", "", -1, "/api/v1/auth", "", true, true)
 
 	c.JSON(200, gin.H{
 		"message": "Logged out successfully",
@@ -151,6 +487,11 @@ func GetCurrentUserOptional(c *gin.Context) {
 		return
 	}
 
+	if revoked, err := auth.IsTokenRevoked(claims); err != nil || revoked {
+		c.JSON(200, gin.H{"authenticated": false, "user": nil})
+		return
+	}
+
 	// Get fresh user data from database
 	var user User
 	var createdAt, updatedAt time.Time
@@ -158,13 +499,13 @@ func GetCurrentUserOptional(c *gin.Context) {
 
 	err = database.DB.QueryRow(`
 		SELECT u.id, u.email, u.username, u.first_name, u.last_name, 
-		       u.role_id, u.active, u.created_at, u.updated_at, r.name as role_name
+		       u.role_id, u.active, u.account_type, u.created_at, u.updated_at, r.name as role_name
 		FROM users u
 		LEFT JOIN roles r ON u.role_id = r.id
 		WHERE u.id = $1
 	`, claims.UserID).Scan(
 		&user.ID, &user.Email, &user.Username,
-		&user.FirstName, &user.LastName, &user.RoleID, &user.Active,
+		&user.FirstName, &user.LastName, &user.RoleID, &user.Active, &user.AccountType,
 		&createdAt, &updatedAt, &roleName,
 	)
 
@@ -181,7 +522,12 @@ func GetCurrentUserOptional(c *gin.Context) {
 	user.UpdatedAt = updatedAt.Format(time.RFC3339)
 
 	// Authenticated - return user
-	c.JSON(200, gin.H{"authenticated": true, "user": user})
+	c.JSON(200, gin.H{
+		"authenticated":             true,
+		"user":                      user,
+		"two_factor_setup_required": claims.TwoFactorSetupRequired,
+		"password_change_required":  claims.PasswordChangeRequired,
+	})
 }
 
 // GetCurrentUser returns the current authenticated user (requires auth)
@@ -189,7 +535,7 @@ func GetCurrentUser(c *gin.Context) {
 	// Get user ID from JWT claims
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(401, gin.H{"error": "Not authenticated"})
+		apierr.Unauthorized(c, "Not authenticated")
 		return
 	}
 
@@ -213,7 +559,7 @@ func GetCurrentUser(c *gin.Context) {
 	)
 
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to fetch user"})
+		apierr.Fail(c, "Failed to fetch user", err)
 		return
 	}
 
@@ -229,7 +575,7 @@ func GetCurrentUser(c *gin.Context) {
 // ChangePasswordRequest represents password change request
 type ChangePasswordRequest struct {
 	CurrentPassword string `json:"current_password" binding:"required"`
-	NewPassword     string `json:"new_password" binding:"required,min=6"`
+	NewPassword     string `json:"new_password" binding:"required"`
 }
 
 // ChangePassword handles password change for authenticated users
@@ -237,15 +583,14 @@ func ChangePassword(c *gin.Context) {
 	// Get user ID from JWT claims
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(401, gin.H{"error": "Not authenticated"})
+		apierr.Unauthorized(c, "Not authenticated")
 		return
 	}
 
 	userIDStr := userID.(string)
 
 	var req ChangePasswordRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": "Current password and new password (min 6 characters) are required"})
+	if !bindJSON(c, &req, "Current password and new password are required") {
 		return
 	}
 
@@ -257,25 +602,35 @@ func ChangePassword(c *gin.Context) {
 	).Scan(&passwordHash)
 
 	if err == sql.ErrNoRows {
-		c.JSON(404, gin.H{"error": "User not found"})
+		apierr.NotFound(c, "User not found")
 		return
 	}
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to fetch user"})
+		apierr.Fail(c, "Failed to fetch user", err)
 		return
 	}
 
 	// Verify current password
 	err = bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(req.CurrentPassword))
 	if err != nil {
-		c.JSON(401, gin.H{"error": "Current password is incorrect"})
+		apierr.Unauthorized(c, "Current password is incorrect")
+		return
+	}
+
+	if !checkPasswordPolicy(c, userIDStr, req.NewPassword) {
 		return
 	}
 
 	// Hash new password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to hash new password"})
+		apierr.Fail(c, "Failed to hash new password", err)
+		return
+	}
+
+	// Keep the old hash so it cannot be reused
+	if err := auth.RecordPasswordHistory(userIDStr); err != nil {
+		apierr.Fail(c, "Failed to update password", err)
 		return
 	}
 
@@ -286,10 +641,34 @@ func ChangePassword(c *gin.Context) {
 	)
 
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to update password"})
+		apierr.Fail(c, "Failed to update password", err)
+		return
+	}
+
+	// Start a fresh session for this client so a forced change does not send the user back to
+	// the login page, then sign out every other session. Its tokens are issued after the
+	// revocation, which spares only this session.
+	sessionID, err := auth.StartSession(userIDStr, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		apierr.Fail(c, "Failed to start session", err)
+		return
+	}
+	if err := auth.RevokeUserTokensExcept(userIDStr, sessionID); err != nil {
+		apierr.Fail(c, "Password changed but failed to revoke existing sessions", err)
 		return
 	}
 
-	c.JSON(200, gin.H{"message": "Password changed successfully"})
+	user, err := loadUser(userIDStr)
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch user", err)
+		return
+	}
+	tokens, err := issueSessionTokens(c, user, sessionID)
+	if err != nil {
+		apierr.Fail(c, "Failed to generate token", err)
+		return
+	}
+	recordAccountEvent(c, "users.password.change", userIDStr)
+	respondLogin(c, user, tokens, "Password changed successfully")
 }
 
@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"database/sql"
+	"playtz-api/apierr"
 	"playtz-api/database"
+	"playtz-api/listing"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,22 +14,46 @@ import (
 // Career represents a career listing
 type Career struct {
 	ID           string `json:"id"`
-	Title        string `json:"title"`
+	Title        string `json:"title" binding:"required,notblank,max=255"`
 	Description  string `json:"description,omitempty"`
-	Department   string `json:"department,omitempty"`
-	Location     string `json:"location,omitempty"`
-	Type         string `json:"type,omitempty"`
+	Department   string `json:"department,omitempty" binding:"max=100"`
+	Location     string `json:"location,omitempty" binding:"max=255"`
+	Type         string `json:"type,omitempty" binding:"omitempty,oneof=full-time part-time contract"`
 	Requirements string `json:"requirements,omitempty"`
 	Active       bool   `json:"active"`
 	CreatedAt    string `json:"created_at,omitempty"`
 	UpdatedAt    string `json:"updated_at,omitempty"`
 }
 
-// GetCareers returns all career listings
+// careersListing is what GET /careers can be filtered and sorted by
+var careersListing = &listing.Spec{
+	From: "careers",
+	Filters: map[string]listing.Filter{
+		"active":     {Column: "active", Type: listing.Bool},
+		"department": {Column: "department"},
+		"location":   {Column: "location"},
+		"type":       {Column: "type"},
+		"created":    {Column: "created_at", Type: listing.Time, Range: true},
+	},
+	Sorts: map[string]string{
+		"created_at": "created_at",
+		"title":      "title",
+		"department": "COALESCE(department, '')",
+	},
+	DefaultSort: "-created_at",
+}
+
+// GetCareers returns a page of career listings
 func GetCareers(c *gin.Context) {
-	rows, err := database.DB.Query("SELECT id, title, description, department, location, type, active, created_at, updated_at FROM careers ORDER BY created_at DESC")
+	q, err := listing.Parse(c, careersListing)
+	if err != nil {
+		apierr.BadRequest(c, err.Error())
+		return
+	}
+
+	rows, err := q.Rows("SELECT id, title, description, department, location, type, active, created_at, updated_at FROM careers")
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to fetch careers"})
+		apierr.Fail(c, "Failed to fetch careers", err)
 		return
 	}
 	defer rows.Close()
@@ -45,40 +71,54 @@ func GetCareers(c *gin.Context) {
 		careers = append(careers, career)
 	}
 
-	c.JSON(200, careers)
+	page, err := listing.Paginate(q, careers, func(career Career) string { return career.ID })
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch careers", err)
+		return
+	}
+
+	c.JSON(200, page)
 }
 
+// careerFields are the members a PUT of a career listing must send
+var careerFields = []string{"title", "description", "department", "location", "type", "active"}
+
 // GetCareerByID returns a specific career listing
 func GetCareerByID(c *gin.Context) {
-	id := c.Param("id")
+	career, err := loadCareer(c.Param("id"))
+	if err == sql.ErrNoRows {
+		apierr.NotFound(c, "Career listing not found")
+		return
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch career", err)
+		return
+	}
 
+	c.JSON(200, career)
+}
+
+// loadCareer fetches a career listing by ID
+func loadCareer(id string) (*Career, error) {
 	var career Career
 	var createdAt, updatedAt time.Time
 	err := database.DB.QueryRow(
 		"SELECT id, title, description, department, location, type, active, created_at, updated_at FROM careers WHERE id = $1",
 		id,
 	).Scan(&career.ID, &career.Title, &career.Description, &career.Department, &career.Location, &career.Type, &career.Active, &createdAt, &updatedAt)
-
-	if err == sql.ErrNoRows {
-		c.JSON(404, gin.H{"error": "Career listing not found"})
-		return
-	}
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to fetch career"})
-		return
+		return nil, err
 	}
 
 	career.CreatedAt = createdAt.Format(time.RFC3339)
 	career.UpdatedAt = updatedAt.Format(time.RFC3339)
-
-	c.JSON(200, career)
+	return &career, nil
 }
 
 // CreateCareer creates a new career listing
 func CreateCareer(c *gin.Context) {
 	var career Career
-	if err := c.ShouldBindJSON(&career); err != nil {
-		c.JSON(400, gin.H{"error": "Invalid request body"})
+	if !bindJSON(c, &career, "Invalid request body") {
 		return
 	}
 
@@ -91,7 +131,7 @@ func CreateCareer(c *gin.Context) {
 	)
 
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to create career: " + err.Error()})
+		apierr.Fail(c, "Failed to create career", err)
 		return
 	}
 
@@ -107,16 +147,37 @@ func CreateCareer(c *gin.Context) {
 	c.JSON(201, career)
 }
 
-// UpdateCareer updates an existing career listing
+// UpdateCareer replaces a career listing
 func UpdateCareer(c *gin.Context) {
-	id := c.Param("id")
-
 	var career Career
-	if err := c.ShouldBindJSON(&career); err != nil {
-		c.JSON(400, gin.H{"error": "Invalid request body"})
+	if !replaceJSON(c, &career, "Invalid request body", careerFields...) {
+		return
+	}
+
+	saveCareer(c, c.Param("id"), &career)
+}
+
+// PatchCareer changes the members of a career listing sent in a JSON merge patch
+func PatchCareer(c *gin.Context) {
+	career, err := loadCareer(c.Param("id"))
+	if err == sql.ErrNoRows {
+		apierr.NotFound(c, "Career listing not found")
+		return
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch career", err)
 		return
 	}
 
+	if !patchJSON(c, career, "Invalid request body") {
+		return
+	}
+
+	saveCareer(c, c.Param("id"), career)
+}
+
+// saveCareer writes career over the career listing with the ID and answers with the result
+func saveCareer(c *gin.Context, id string, career *Career) {
 	career.ID = id
 
 	_, err := database.DB.Exec(
@@ -125,7 +186,7 @@ func UpdateCareer(c *gin.Context) {
 	)
 
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to update career: " + err.Error()})
+		apierr.Fail(c, "Failed to update career", err)
 		return
 	}
 
@@ -136,11 +197,11 @@ func UpdateCareer(c *gin.Context) {
 	).Scan(&createdAt, &updatedAt)
 
 	if err == sql.ErrNoRows {
-		c.JSON(404, gin.H{"error": "Career listing not found"})
+		apierr.NotFound(c, "Career listing not found")
 		return
 	}
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to fetch updated career"})
+		apierr.Fail(c, "Failed to fetch updated career", err)
 		return
 	}
 
@@ -156,13 +217,13 @@ func DeleteCareer(c *gin.Context) {
 
 	result, err := database.DB.Exec("DELETE FROM careers WHERE id = $1", id)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to delete career: " + err.Error()})
+		apierr.Fail(c, "Failed to delete career", err)
 		return
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		c.JSON(404, gin.H{"error": "Career listing not found"})
+		apierr.NotFound(c, "Career listing not found")
 		return
 	}
 
@@ -2,8 +2,9 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
+	"playtz-api/apierr"
 	"playtz-api/database"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -29,35 +30,66 @@ type Cart struct {
 	Total    float64    `json:"total"`
 }
 
-// GetCart returns the current cart
-func GetCart(c *gin.Context) {
-	cartID := c.Query("cart_id")
-	userID := c.Query("user_id")
+// errCartNotFound is returned for carts that do not belong to the caller
+var errCartNotFound = errors.New("cart not found")
 
-	if cartID == "" {
-		// Return empty cart structure
-		cart := Cart{
-			ID:       uuid.New().String(),
-			UserID:   userID,
-			Items:    []CartItem{},
-			Subtotal: 0,
-			Total:    0,
+// callerCart resolves the cart a request acts on. An explicit cart ID must belong to the caller;
+// without one the caller's most recent cart is used. When the cart does not exist it is created
+// for the caller if create is set, otherwise "" is returned. Carts without an owner belong to nobody.
+func callerCart(userID, cartID string, create bool) (string, error) {
+	if userID == "" {
+		return "", errCartNotFound
+	}
+	if cartID != "" {
+		var owner sql.NullString
+		err := database.DB.QueryRow("SELECT user_id FROM cart WHERE id = $1", cartID).Scan(&owner)
+		if err == nil && !(owner.Valid && owner.String == userID) {
+			return "", errCartNotFound
 		}
-		c.JSON(200, cart)
+		if err != sql.ErrNoRows {
+			return cartID, err
+		}
+	} else {
+		err := database.DB.QueryRow(
+			"SELECT id FROM cart WHERE user_id = $1 ORDER BY updated_at DESC, created_at DESC LIMIT 1",
+			userID,
+		).Scan(&cartID)
+		if err != sql.ErrNoRows {
+			return cartID, err
+		}
+		cartID = uuid.New().String()
+	}
+
+	if !create {
+		return "", nil
+	}
+	_, err := database.DB.Exec("INSERT INTO cart (id, user_id) VALUES ($1, $2)", cartID, userID)
+	if err != nil {
+		return "", err
+	}
+	return cartID, nil
+}
+
+// GetCart returns the caller's cart, or the cart given by ?cart_id if it belongs to the caller
+func GetCart(c *gin.Context) {
+	userID := c.GetString("user_id")
+	cartID, err := callerCart(userID, c.Query("cart_id"), false)
+	if err == errCartNotFound {
+		apierr.NotFound(c, "Cart not found")
+		return
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch cart", err)
 		return
 	}
 
-	// Fetch cart from database
-	var cart Cart
-	var createdAt, updatedAt time.Time
-	err := database.DB.QueryRow(
-		"SELECT id, user_id, created_at, updated_at FROM cart WHERE id = $1",
-		cartID,
-	).Scan(&cart.ID, &cart.UserID, &createdAt, &updatedAt)
-
-	if err == sql.ErrNoRows {
-		// Cart not found, return empty cart
-		cart = Cart{
+	if cartID == "" {
+		// No cart yet - return empty cart structure; the ID is used when the first item is added
+		cartID = c.Query("cart_id")
+		if cartID == "" {
+			cartID = uuid.New().String()
+		}
+		cart := Cart{
 			ID:       cartID,
 			UserID:   userID,
 			Items:    []CartItem{},
@@ -67,10 +99,9 @@ func GetCart(c *gin.Context) {
 		c.JSON(200, cart)
 		return
 	}
-	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to fetch cart"})
-		return
-	}
+
+	// Fetch cart from database
+	cart := Cart{ID: cartID, UserID: userID, Items: []CartItem{}}
 
 	// Fetch cart items
 	rows, err := database.DB.Query(`
@@ -100,62 +131,46 @@ func GetCart(c *gin.Context) {
 	c.JSON(200, cart)
 }
 
-// AddToCart adds an item to the cart
-func AddToCart(c *gin.Context) {
-	var req struct {
-		CartID        string `json:"cart_id"`
-		UserID        string `json:"user_id,omitempty"`
-		MerchandiseID string `json:"merchandise_id"`
-		Quantity      int    `json:"quantity"`
-	}
+// AddToCartRequest adds merchandise to a cart
+type AddToCartRequest struct {
+	CartID        string `json:"cart_id"`
+	MerchandiseID string `json:"merchandise_id" binding:"required"`
+	Quantity      int    `json:"quantity" binding:"gte=0"` // 0 or omitted adds one
+}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": "Invalid request body"})
-		return
-	}
+// UpdateCartItemRequest changes the quantity of a cart item
+type UpdateCartItemRequest struct {
+	CartID   string `json:"cart_id"`
+	ItemID   string `json:"item_id" binding:"required"`
+	Quantity int    `json:"quantity" binding:"required,min=1"`
+}
 
-	if req.MerchandiseID == "" {
-		c.JSON(400, gin.H{"error": "Merchandise ID is required"})
+// AddToCart adds an item to the cart
+func AddToCart(c *gin.Context) {
+	var req AddToCartRequest
+	if !bindJSON(c, &req, "Invalid request body") {
 		return
 	}
 
-	if req.Quantity <= 0 {
+	if req.Quantity == 0 {
 		req.Quantity = 1
 	}
 
-	// Get or create cart
-	var cartID string
-	if req.CartID == "" {
-		cartID = uuid.New().String()
-		_, err := database.DB.Exec(
-			"INSERT INTO cart (id, user_id) VALUES ($1, $2)",
-			cartID, req.UserID,
-		)
-		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to create cart: " + err.Error()})
-			return
-		}
-	} else {
-		cartID = req.CartID
-		// Verify cart exists
-		var exists bool
-		database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM cart WHERE id = $1)", cartID).Scan(&exists)
-		if !exists {
-			_, err := database.DB.Exec(
-				"INSERT INTO cart (id, user_id) VALUES ($1, $2)",
-				cartID, req.UserID,
-			)
-			if err != nil {
-				c.JSON(500, gin.H{"error": "Failed to create cart: " + err.Error()})
-				return
-			}
-		}
+	// Get or create the caller's cart
+	cartID, err := callerCart(c.GetString("user_id"), req.CartID, true)
+	if err == errCartNotFound {
+		apierr.NotFound(c, "Cart not found")
+		return
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to create cart", err)
+		return
 	}
 
 	// Check if item already exists in cart
 	var existingID string
 	var existingQty int
-	err := database.DB.QueryRow(
+	err = database.DB.QueryRow(
 		"SELECT id, quantity FROM cart_items WHERE cart_id = $1 AND merchandise_id = $2",
 		cartID, req.MerchandiseID,
 	).Scan(&existingID, &existingQty)
@@ -168,7 +183,7 @@ func AddToCart(c *gin.Context) {
 			newQty, existingID,
 		)
 		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to update cart item: " + err.Error()})
+			apierr.Fail(c, "Failed to update cart item", err)
 			return
 		}
 	} else {
@@ -179,11 +194,14 @@ func AddToCart(c *gin.Context) {
 			itemID, cartID, req.MerchandiseID, req.Quantity,
 		)
 		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to add item to cart: " + err.Error()})
+			apierr.Fail(c, "Failed to add item to cart", err)
 			return
 		}
 	}
 
+	// The most recently used cart is the caller's default cart
+	database.DB.Exec("UPDATE cart SET updated_at = CURRENT_TIMESTAMP WHERE id = $1", cartID)
+
 	c.JSON(201, gin.H{
 		"message":       "Item added to cart",
 		"cart_id":       cartID,
@@ -194,35 +212,34 @@ func AddToCart(c *gin.Context) {
 
 // UpdateCartItem updates a cart item
 func UpdateCartItem(c *gin.Context) {
-	var req struct {
-		CartID   string `json:"cart_id"`
-		ItemID   string `json:"item_id"`
-		Quantity int    `json:"quantity"`
+	var req UpdateCartItemRequest
+	if !bindJSON(c, &req, "Invalid request body") {
+		return
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": "Invalid request body"})
+	cartID, err := callerCart(c.GetString("user_id"), req.CartID, false)
+	if err == errCartNotFound || (err == nil && cartID == "") {
+		apierr.NotFound(c, "Cart item not found")
 		return
 	}
-
-	if req.Quantity <= 0 {
-		c.JSON(400, gin.H{"error": "Quantity must be greater than 0"})
+	if err != nil {
+		apierr.Fail(c, "Failed to update cart item", err)
 		return
 	}
 
 	result, err := database.DB.Exec(
 		"UPDATE cart_items SET quantity = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2 AND cart_id = $3",
-		req.Quantity, req.ItemID, req.CartID,
+		req.Quantity, req.ItemID, cartID,
 	)
 
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to update cart item: " + err.Error()})
+		apierr.Fail(c, "Failed to update cart item", err)
 		return
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		c.JSON(404, gin.H{"error": "Cart item not found"})
+		apierr.NotFound(c, "Cart item not found")
 		return
 	}
 
@@ -232,29 +249,32 @@ func UpdateCartItem(c *gin.Context) {
 	})
 }
 
-// RemoveFromCart removes an item from the cart
+// RemoveFromCart removes an item from the caller's cart
 func RemoveFromCart(c *gin.Context) {
-	cartID := c.Query("cart_id")
 	itemID := c.Query("item_id")
 	productID := c.Query("product_id")
 
-	if cartID == "" {
-		c.JSON(400, gin.H{"error": "Cart ID is required"})
+	cartID, err := callerCart(c.GetString("user_id"), c.Query("cart_id"), false)
+	if err == errCartNotFound {
+		apierr.NotFound(c, "Cart not found")
+		return
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to remove item", err)
 		return
 	}
 
-	var err error
 	if itemID != "" {
 		_, err = database.DB.Exec("DELETE FROM cart_items WHERE id = $1 AND cart_id = $2", itemID, cartID)
 	} else if productID != "" {
 		_, err = database.DB.Exec("DELETE FROM cart_items WHERE cart_id = $1 AND merchandise_id = $2", cartID, productID)
 	} else {
-		c.JSON(400, gin.H{"error": "Item ID or Product ID is required"})
+		apierr.BadRequest(c, "Item ID or Product ID is required")
 		return
 	}
 
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to remove item: " + err.Error()})
+		apierr.Fail(c, "Failed to remove item", err)
 		return
 	}
 
@@ -263,17 +283,21 @@ func RemoveFromCart(c *gin.Context) {
 	})
 }
 
-// ClearCart clears all items from the cart
+// ClearCart clears all items from the caller's cart
 func ClearCart(c *gin.Context) {
-	cartID := c.Query("cart_id")
-	if cartID == "" {
-		c.JSON(400, gin.H{"error": "Cart ID is required"})
+	cartID, err := callerCart(c.GetString("user_id"), c.Query("cart_id"), false)
+	if err == errCartNotFound {
+		apierr.NotFound(c, "Cart not found")
+		return
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to clear cart", err)
 		return
 	}
 
-	_, err := database.DB.Exec("DELETE FROM cart_items WHERE cart_id = $1", cartID)
+	_, err = database.DB.Exec("DELETE FROM cart_items WHERE cart_id = $1", cartID)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to clear cart: " + err.Error()})
+		apierr.Fail(c, "Failed to clear cart", err)
 		return
 	}
 
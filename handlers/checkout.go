@@ -3,7 +3,10 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"playtz-api/apierr"
 	"playtz-api/database"
+	"playtz-api/listing"
+	"playtz-api/middleware"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -38,34 +41,44 @@ type OrderItem struct {
 
 // ShippingAddress represents shipping information
 type ShippingAddress struct {
-	FullName   string `json:"full_name"`
-	Email      string `json:"email"`
+	FullName   string `json:"full_name" binding:"required,notblank"`
+	Email      string `json:"email" binding:"required,email"`
 	Phone      string `json:"phone"`
-	Address    string `json:"address"`
-	City       string `json:"city"`
+	Address    string `json:"address" binding:"required,notblank"`
+	City       string `json:"city" binding:"required,notblank"`
 	State      string `json:"state"`
 	PostalCode string `json:"postal_code"`
-	Country    string `json:"country"`
+	Country    string `json:"country" binding:"required,notblank"`
 }
 
 // CheckoutRequest represents the checkout request
+// The order belongs to the caller; cart_id is optional and must be the caller's cart
 type CheckoutRequest struct {
 	CartID          string          `json:"cart_id"`
-	UserID          string          `json:"user_id,omitempty"`
 	ShippingAddress ShippingAddress `json:"shipping_address"`
 	PaymentMethod   string          `json:"payment_method"`
 }
 
+// UpdateOrderStatusRequest moves an order through fulfilment
+type UpdateOrderStatusRequest struct {
+	Status string `json:"status" binding:"required,oneof=pending processing shipped delivered cancelled"`
+}
+
 // CreateOrder creates a new order from checkout
 func CreateOrder(c *gin.Context) {
 	var checkout CheckoutRequest
-	if err := c.ShouldBindJSON(&checkout); err != nil {
-		c.JSON(400, gin.H{"error": "Invalid request body"})
+	if !bindJSON(c, &checkout, "Invalid request body") {
 		return
 	}
 
-	if checkout.CartID == "" {
-		c.JSON(400, gin.H{"error": "Cart ID is required"})
+	userID := c.GetString("user_id")
+	cartID, err := callerCart(userID, checkout.CartID, false)
+	if err == errCartNotFound {
+		apierr.NotFound(c, "Cart not found")
+		return
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch cart", err)
 		return
 	}
 
@@ -75,10 +88,10 @@ func CreateOrder(c *gin.Context) {
 		FROM cart_items ci
 		LEFT JOIN merchandise m ON ci.merchandise_id = m.id
 		WHERE ci.cart_id = $1
-	`, checkout.CartID)
+	`, cartID)
 
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to fetch cart items: " + err.Error()})
+		apierr.Fail(c, "Failed to fetch cart items", err)
 		return
 	}
 	defer rows.Close()
@@ -100,7 +113,7 @@ func CreateOrder(c *gin.Context) {
 	}
 
 	if len(items) == 0 {
-		c.JSON(400, gin.H{"error": "Cart is empty"})
+		apierr.BadRequest(c, "Cart is empty")
 		return
 	}
 
@@ -115,11 +128,11 @@ func CreateOrder(c *gin.Context) {
 
 	_, err = database.DB.Exec(
 		"INSERT INTO orders (id, user_id, total, status, shipping_address) VALUES ($1, $2, $3, $4, $5)",
-		orderID, checkout.UserID, total, "pending", string(shippingAddrJSON),
+		orderID, userID, total, "pending", string(shippingAddrJSON),
 	)
 
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to create order: " + err.Error()})
+		apierr.Fail(c, "Failed to create order", err)
 		return
 	}
 
@@ -137,7 +150,7 @@ func CreateOrder(c *gin.Context) {
 	}
 
 	// Clear cart
-	database.DB.Exec("DELETE FROM cart_items WHERE cart_id = $1", checkout.CartID)
+	database.DB.Exec("DELETE FROM cart_items WHERE cart_id = $1", cartID)
 
 	// Fetch created order
 	var createdAt, updatedAt time.Time
@@ -148,7 +161,7 @@ func CreateOrder(c *gin.Context) {
 
 	order := Order{
 		ID:              orderID,
-		UserID:          checkout.UserID,
+		UserID:          userID,
 		Items:           items,
 		Subtotal:        subtotal,
 		Shipping:        shipping,
@@ -164,24 +177,30 @@ func CreateOrder(c *gin.Context) {
 	c.JSON(201, order)
 }
 
-// GetOrder returns a specific order
+// GetOrder returns a specific order. Callers without orders.read only see their own orders.
 func GetOrder(c *gin.Context) {
 	id := c.Param("id")
 
 	var order Order
+	var orderUserID sql.NullString
 	var shippingAddrJSON string
 	var createdAt, updatedAt time.Time
 	err := database.DB.QueryRow(
 		"SELECT id, user_id, total, status, shipping_address, created_at, updated_at FROM orders WHERE id = $1",
 		id,
-	).Scan(&order.ID, &order.UserID, &order.Total, &order.Status, &shippingAddrJSON, &createdAt, &updatedAt)
+	).Scan(&order.ID, &orderUserID, &order.Total, &order.Status, &shippingAddrJSON, &createdAt, &updatedAt)
+	order.UserID = orderUserID.String
 
+	// Other users' orders look the same as missing ones
+	if err == nil && !middleware.HasPermission(c, "orders.read") && (order.UserID == "" || order.UserID != c.GetString("user_id")) {
+		err = sql.ErrNoRows
+	}
 	if err == sql.ErrNoRows {
-		c.JSON(404, gin.H{"error": "Order not found"})
+		apierr.NotFound(c, "Order not found")
 		return
 	}
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to fetch order"})
+		apierr.Fail(c, "Failed to fetch order", err)
 		return
 	}
 
@@ -218,26 +237,47 @@ func GetOrder(c *gin.Context) {
 	c.JSON(200, order)
 }
 
-// GetOrders returns all orders (optionally filtered by user)
+// ordersListing is what GET /orders can be filtered and sorted by.
+// ?user_id is handled by GetOrders since only holders of orders.read may use it.
+var ordersListing = &listing.Spec{
+	From: "orders",
+	Filters: map[string]listing.Filter{
+		"status":  {Column: "status"},
+		"total":   {Column: "total", Type: listing.Number, Range: true},
+		"created": {Column: "created_at", Type: listing.Time, Range: true},
+	},
+	Sorts: map[string]string{
+		"created_at": "created_at",
+		"total":      "total",
+		"status":     "COALESCE(status, '')",
+	},
+	DefaultSort: "-created_at",
+}
+
+// GetOrders returns a page of all orders (optionally filtered by ?user_id) to holders of
+// orders.read, and of the caller's own orders to everyone else
 func GetOrders(c *gin.Context) {
 	userID := c.Query("user_id")
+	if !middleware.HasPermission(c, "orders.read") {
+		userID = c.GetString("user_id")
+		if userID == "" {
+			apierr.MissingPermission(c, "orders.read")
+			return
+		}
+	}
 
-	var rows *sql.Rows
-	var err error
-
+	q, err := listing.Parse(c, ordersListing)
+	if err != nil {
+		apierr.BadRequest(c, err.Error())
+		return
+	}
 	if userID != "" {
-		rows, err = database.DB.Query(
-			"SELECT id, user_id, total, status, shipping_address, created_at, updated_at FROM orders WHERE user_id = $1 ORDER BY created_at DESC",
-			userID,
-		)
-	} else {
-		rows, err = database.DB.Query(
-			"SELECT id, user_id, total, status, shipping_address, created_at, updated_at FROM orders ORDER BY created_at DESC",
-		)
+		q.Where("user_id = " + q.Arg(userID))
 	}
 
+	rows, err := q.Rows("SELECT id, user_id, total, status, shipping_address, created_at, updated_at FROM orders")
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to fetch orders"})
+		apierr.Fail(c, "Failed to fetch orders", err)
 		return
 	}
 	defer rows.Close()
@@ -245,12 +285,14 @@ func GetOrders(c *gin.Context) {
 	var orders []Order
 	for rows.Next() {
 		var order Order
+		var orderUserID sql.NullString
 		var shippingAddrJSON string
 		var createdAt, updatedAt time.Time
-		err := rows.Scan(&order.ID, &order.UserID, &order.Total, &order.Status, &shippingAddrJSON, &createdAt, &updatedAt)
+		err := rows.Scan(&order.ID, &orderUserID, &order.Total, &order.Status, &shippingAddrJSON, &createdAt, &updatedAt)
 		if err != nil {
 			continue
 		}
+		order.UserID = orderUserID.String
 
 		json.Unmarshal([]byte(shippingAddrJSON), &order.ShippingAddress)
 		order.Subtotal = order.Total / 1.1
@@ -261,26 +303,21 @@ func GetOrders(c *gin.Context) {
 		orders = append(orders, order)
 	}
 
-	c.JSON(200, orders)
+	page, err := listing.Paginate(q, orders, func(o Order) string { return o.ID })
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch orders", err)
+		return
+	}
+
+	c.JSON(200, page)
 }
 
 // UpdateOrderStatus updates the status of an order
 func UpdateOrderStatus(c *gin.Context) {
 	id := c.Param("id")
 
-	var update struct {
-		Status string `json:"status"`
-	}
-	if err := c.ShouldBindJSON(&update); err != nil {
-		c.JSON(400, gin.H{"error": "Invalid request body"})
-		return
-	}
-
-	validStatuses := map[string]bool{
-		"pending": true, "processing": true, "shipped": true, "delivered": true, "cancelled": true,
-	}
-	if !validStatuses[update.Status] {
-		c.JSON(400, gin.H{"error": "Invalid status"})
+	var update UpdateOrderStatusRequest
+	if !bindJSON(c, &update, "Invalid status") {
 		return
 	}
 
@@ -290,13 +327,13 @@ func UpdateOrderStatus(c *gin.Context) {
 	)
 
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to update order: " + err.Error()})
+		apierr.Fail(c, "Failed to update order", err)
 		return
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		c.JSON(404, gin.H{"error": "Order not found"})
+		apierr.NotFound(c, "Order not found")
 		return
 	}
 
@@ -2,7 +2,10 @@ package handlers
 
 import (
 	"database/sql"
+	"playtz-api/apierr"
 	"playtz-api/database"
+	"playtz-api/listing"
+	"playtz-api/slugs"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,22 +15,47 @@ import (
 // Event represents an event
 type Event struct {
 	ID          string `json:"id"`
-	Title       string `json:"title"`
+	Slug        string `json:"slug"` // Generated from the title when empty
+	Title       string `json:"title" binding:"required,notblank,max=255"`
 	Description string `json:"description,omitempty"`
-	Date        string `json:"date"`
-	Time        string `json:"time,omitempty"`
-	Location    string `json:"location,omitempty"`
-	Image       string `json:"image,omitempty"`
+	Date        string `json:"date" binding:"required,date"`             // 2006-01-02 (RFC3339 timestamps are accepted)
+	Time        string `json:"time,omitempty" binding:"omitempty,clock"` // 15:04
+	Location    string `json:"location,omitempty" binding:"max=255"`
+	Image       string `json:"image,omitempty" binding:"omitempty,url"`
 	Active      bool   `json:"active"`
 	CreatedAt   string `json:"created_at,omitempty"`
 	UpdatedAt   string `json:"updated_at,omitempty"`
 }
 
-// GetEvents returns all events
+// eventsListing is what GET /events can be filtered and sorted by
+var eventsListing = &listing.Spec{
+	From: "events",
+	Filters: map[string]listing.Filter{
+		"active":   {Column: "active", Type: listing.Bool},
+		"location": {Column: "location"},
+		"slug":     {Column: "slug"},
+		"date":     {Column: "date", Type: listing.Time, Range: true},
+		"created":  {Column: "created_at", Type: listing.Time, Range: true},
+	},
+	Sorts: map[string]string{
+		"date":       "COALESCE(date, DATE '0001-01-01')",
+		"created_at": "created_at",
+		"title":      "title",
+	},
+	DefaultSort: "-date,-created_at",
+}
+
+// GetEvents returns a page of events
 func GetEvents(c *gin.Context) {
-	rows, err := database.DB.Query("SELECT id, title, description, date, time, location, image, active, created_at, updated_at FROM events ORDER BY date DESC, created_at DESC")
+	q, err := listing.Parse(c, eventsListing)
+	if err != nil {
+		apierr.BadRequest(c, err.Error())
+		return
+	}
+
+	rows, err := q.Rows("SELECT id, COALESCE(slug, ''), title, description, date, time, location, image, active, created_at, updated_at FROM events")
 	if err != nil {
-		c.JSON(500, []Event{})
+		apierr.Fail(c, "Failed to fetch events", err)
 		return
 	}
 	defer rows.Close()
@@ -37,7 +65,7 @@ func GetEvents(c *gin.Context) {
 		var event Event
 		var createdAt, updatedAt time.Time
 		var date time.Time
-		err := rows.Scan(&event.ID, &event.Title, &event.Description, &date, &event.Time, &event.Location, &event.Image, &event.Active, &createdAt, &updatedAt)
+		err := rows.Scan(&event.ID, &event.Slug, &event.Title, &event.Description, &date, &event.Time, &event.Location, &event.Image, &event.Active, &createdAt, &updatedAt)
 		if err != nil {
 			continue
 		}
@@ -47,55 +75,81 @@ func GetEvents(c *gin.Context) {
 		events = append(events, event)
 	}
 
-	c.JSON(200, events)
+	page, err := listing.Paginate(q, events, func(e Event) string { return e.ID })
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch events", err)
+		return
+	}
+
+	c.JSON(200, page)
 }
 
+// eventFields are the members a PUT of an event must send
+var eventFields = []string{"title", "description", "date", "time", "location", "image", "active"}
+
 // GetEventByID returns a specific event
 func GetEventByID(c *gin.Context) {
-	id := c.Param("id")
+	event, err := loadEvent(c.Param("id"))
+	if err == sql.ErrNoRows {
+		apierr.NotFound(c, "Event not found")
+		return
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch event", err)
+		return
+	}
+
+	c.JSON(200, event)
+}
 
+// loadEvent fetches an event by ID
+func loadEvent(id string) (*Event, error) {
 	var event Event
 	var createdAt, updatedAt time.Time
 	var date time.Time
 	err := database.DB.QueryRow(
-		"SELECT id, title, description, date, time, location, image, active, created_at, updated_at FROM events WHERE id = $1",
+		"SELECT id, COALESCE(slug, ''), title, description, date, time, location, image, active, created_at, updated_at FROM events WHERE id = $1",
 		id,
-	).Scan(&event.ID, &event.Title, &event.Description, &date, &event.Time, &event.Location, &event.Image, &event.Active, &createdAt, &updatedAt)
-
-	if err == sql.ErrNoRows {
-		c.JSON(404, gin.H{"error": "Event not found"})
-		return
-	}
+	).Scan(&event.ID, &event.Slug, &event.Title, &event.Description, &date, &event.Time, &event.Location, &event.Image, &event.Active, &createdAt, &updatedAt)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to fetch event"})
-		return
+		return nil, err
 	}
 
 	event.Date = date.Format("2006-01-02")
 	event.CreatedAt = createdAt.Format(time.RFC3339)
 	event.UpdatedAt = updatedAt.Format(time.RFC3339)
+	return &event, nil
+}
 
-	c.JSON(200, event)
+// GetEventBySlug returns an event by its slug, redirecting former slugs
+func GetEventBySlug(c *gin.Context) {
+	serveBySlug(c, slugs.Events, "true", "Event not found", GetEventByID)
 }
 
 // CreateEvent creates a new event
 func CreateEvent(c *gin.Context) {
 	var event Event
-	if err := c.ShouldBindJSON(&event); err != nil {
-		c.JSON(400, gin.H{"error": "Invalid request body"})
+	if !bindJSON(c, &event, "Invalid request body") {
 		return
 	}
 
 	event.ID = uuid.New().String()
 	event.Active = true
 
-	_, err := database.DB.Exec(
-		"INSERT INTO events (id, title, description, date, time, location, image, active) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
-		event.ID, event.Title, event.Description, event.Date, event.Time, event.Location, event.Image, event.Active,
+	slug, err := slugs.Assign(slugs.Events, event.ID, event.Slug, event.Title)
+	if err != nil {
+		respondSlugError(c, err)
+		return
+	}
+	event.Slug = slug
+
+	_, err = database.DB.Exec(
+		"INSERT INTO events (id, slug, title, description, date, time, location, image, active) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)",
+		event.ID, event.Slug, event.Title, event.Description, event.Date, event.Time, event.Location, event.Image, event.Active,
 	)
 
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to create event: " + err.Error()})
+		apierr.Fail(c, "Failed to create event", err)
 		return
 	}
 
@@ -113,25 +167,70 @@ func CreateEvent(c *gin.Context) {
 	c.JSON(201, event)
 }
 
-// UpdateEvent updates an existing event
+// UpdateEvent replaces an event
 func UpdateEvent(c *gin.Context) {
-	id := c.Param("id")
-
 	var event Event
-	if err := c.ShouldBindJSON(&event); err != nil {
-		c.JSON(400, gin.H{"error": "Invalid request body"})
+	if !replaceJSON(c, &event, "Invalid request body", eventFields...) {
+		return
+	}
+
+	saveEvent(c, c.Param("id"), &event)
+}
+
+// PatchEvent changes the members of an event sent in a JSON merge patch
+func PatchEvent(c *gin.Context) {
+	event, err := loadEvent(c.Param("id"))
+	if err == sql.ErrNoRows {
+		apierr.NotFound(c, "Event not found")
+		return
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch event", err)
+		return
+	}
+
+	if !patchJSON(c, event, "Invalid request body") {
 		return
 	}
 
+	saveEvent(c, c.Param("id"), event)
+}
+
+// saveEvent writes event over the event with the ID and answers with the result
+func saveEvent(c *gin.Context, id string, event *Event) {
 	event.ID = id
 
-	_, err := database.DB.Exec(
+	// The slug and the other fields change together or not at all
+	tx, err := database.DB.Begin()
+	if err != nil {
+		apierr.Fail(c, "Failed to update event", err)
+		return
+	}
+	defer tx.Rollback()
+
+	// An empty slug keeps the current one; a new one leaves the old as a redirect
+	slug, err := slugs.Change(tx, slugs.Events, id, event.Slug)
+	if err == sql.ErrNoRows {
+		apierr.NotFound(c, "Event not found")
+		return
+	}
+	if err != nil {
+		respondSlugError(c, err)
+		return
+	}
+	event.Slug = slug
+
+	_, err = tx.Exec(
 		"UPDATE events SET title = $1, description = $2, date = $3, time = $4, location = $5, image = $6, active = $7, updated_at = CURRENT_TIMESTAMP WHERE id = $8",
 		event.Title, event.Description, event.Date, event.Time, event.Location, event.Image, event.Active, id,
 	)
 
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to update event: " + err.Error()})
+		apierr.Fail(c, "Failed to update event", err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		apierr.Fail(c, "Failed to update event", err)
 		return
 	}
 
@@ -143,11 +242,11 @@ func UpdateEvent(c *gin.Context) {
 	).Scan(&date, &createdAt, &updatedAt)
 
 	if err == sql.ErrNoRows {
-		c.JSON(404, gin.H{"error": "Event not found"})
+		apierr.NotFound(c, "Event not found")
 		return
 	}
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to fetch updated event"})
+		apierr.Fail(c, "Failed to fetch updated event", err)
 		return
 	}
 
@@ -164,15 +263,16 @@ func DeleteEvent(c *gin.Context) {
 
 	result, err := database.DB.Exec("DELETE FROM events WHERE id = $1", id)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to delete event: " + err.Error()})
+		apierr.Fail(c, "Failed to delete event", err)
 		return
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		c.JSON(404, gin.H{"error": "Event not found"})
+		apierr.NotFound(c, "Event not found")
 		return
 	}
+	slugs.Forget(slugs.Events, id)
 
 	c.JSON(200, gin.H{"message": "Event deleted successfully"})
 }
@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"playtz-api/apierr"
+	"playtz-api/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetJWKS publishes the public keys that verify access tokens, so other
+// services can validate tokens without calling this API
+func GetJWKS(c *gin.Context) {
+	keys, err := auth.JWKS()
+	if err != nil {
+		apierr.Fail(c, "Failed to load signing keys", err)
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(200, gin.H{"keys": keys})
+}
@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"database/sql"
+	"playtz-api/apierr"
 	"playtz-api/database"
+	"playtz-api/listing"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,21 +14,45 @@ import (
 // Merchandise represents a merchandise item
 type Merchandise struct {
 	ID          string  `json:"id"`
-	Name        string  `json:"name"`
+	Name        string  `json:"name" binding:"required,notblank,max=255"`
 	Description string  `json:"description,omitempty"`
-	Price       float64 `json:"price"`
-	Image       string  `json:"image,omitempty"`
-	Stock       int     `json:"stock"`
+	Price       float64 `json:"price" binding:"gte=0,lte=99999999.99"` // DECIMAL(10, 2)
+	Image       string  `json:"image,omitempty" binding:"omitempty,url"`
+	Stock       int     `json:"stock" binding:"gte=0"`
 	Active      bool    `json:"active"`
 	CreatedAt   string  `json:"created_at,omitempty"`
 	UpdatedAt   string  `json:"updated_at,omitempty"`
 }
 
-// GetMerch returns all merchandise items
+// merchListing is what GET /merch can be filtered and sorted by
+var merchListing = &listing.Spec{
+	From: "merchandise",
+	Filters: map[string]listing.Filter{
+		"active":   {Column: "active", Type: listing.Bool},
+		"in_stock": {Column: "(COALESCE(stock, 0) > 0)", Type: listing.Bool},
+		"price":    {Column: "price", Type: listing.Number, Range: true},
+		"created":  {Column: "created_at", Type: listing.Time, Range: true},
+	},
+	Sorts: map[string]string{
+		"created_at": "created_at",
+		"name":       "name",
+		"price":      "price",
+		"stock":      "COALESCE(stock, 0)",
+	},
+	DefaultSort: "-created_at",
+}
+
+// GetMerch returns a page of merchandise items
 func GetMerch(c *gin.Context) {
-	rows, err := database.DB.Query("SELECT id, name, description, price, image, stock, active, created_at, updated_at FROM merchandise ORDER BY created_at DESC")
+	q, err := listing.Parse(c, merchListing)
+	if err != nil {
+		apierr.BadRequest(c, err.Error())
+		return
+	}
+
+	rows, err := q.Rows("SELECT id, name, description, price, image, stock, active, created_at, updated_at FROM merchandise")
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to fetch merchandise"})
+		apierr.Fail(c, "Failed to fetch merchandise", err)
 		return
 	}
 	defer rows.Close()
@@ -46,13 +72,35 @@ func GetMerch(c *gin.Context) {
 		items = append(items, item)
 	}
 
-	c.JSON(200, items)
+	page, err := listing.Paginate(q, items, func(m Merchandise) string { return m.ID })
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch merchandise", err)
+		return
+	}
+
+	c.JSON(200, page)
 }
 
+// merchFields are the members a PUT of a merchandise item must send
+var merchFields = []string{"name", "description", "price", "image", "stock", "active"}
+
 // GetMerchByID returns a specific merchandise item
 func GetMerchByID(c *gin.Context) {
-	id := c.Param("id")
+	item, err := loadMerch(c.Param("id"))
+	if err == sql.ErrNoRows {
+		apierr.NotFound(c, "Merchandise item not found")
+		return
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch merchandise", err)
+		return
+	}
 
+	c.JSON(200, item)
+}
+
+// loadMerch fetches a merchandise item by ID
+func loadMerch(id string) (*Merchandise, error) {
 	var item Merchandise
 	var createdAt, updatedAt time.Time
 	var price float64
@@ -60,28 +108,20 @@ func GetMerchByID(c *gin.Context) {
 		"SELECT id, name, description, price, image, stock, active, created_at, updated_at FROM merchandise WHERE id = $1",
 		id,
 	).Scan(&item.ID, &item.Name, &item.Description, &price, &item.Image, &item.Stock, &item.Active, &createdAt, &updatedAt)
-
-	if err == sql.ErrNoRows {
-		c.JSON(404, gin.H{"error": "Merchandise item not found"})
-		return
-	}
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to fetch merchandise"})
-		return
+		return nil, err
 	}
 
 	item.Price = price
 	item.CreatedAt = createdAt.Format(time.RFC3339)
 	item.UpdatedAt = updatedAt.Format(time.RFC3339)
-
-	c.JSON(200, item)
+	return &item, nil
 }
 
 // CreateMerch creates a new merchandise item
 func CreateMerch(c *gin.Context) {
 	var item Merchandise
-	if err := c.ShouldBindJSON(&item); err != nil {
-		c.JSON(400, gin.H{"error": "Invalid request body"})
+	if !bindJSON(c, &item, "Invalid request body") {
 		return
 	}
 
@@ -94,7 +134,7 @@ func CreateMerch(c *gin.Context) {
 	)
 
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to create merchandise: " + err.Error()})
+		apierr.Fail(c, "Failed to create merchandise", err)
 		return
 	}
 
@@ -110,16 +150,37 @@ func CreateMerch(c *gin.Context) {
 	c.JSON(201, item)
 }
 
-// UpdateMerch updates an existing merchandise item
+// UpdateMerch replaces a merchandise item
 func UpdateMerch(c *gin.Context) {
-	id := c.Param("id")
-
 	var item Merchandise
-	if err := c.ShouldBindJSON(&item); err != nil {
-		c.JSON(400, gin.H{"error": "Invalid request body"})
+	if !replaceJSON(c, &item, "Invalid request body", merchFields...) {
+		return
+	}
+
+	saveMerch(c, c.Param("id"), &item)
+}
+
+// PatchMerch changes the members of a merchandise item sent in a JSON merge patch
+func PatchMerch(c *gin.Context) {
+	item, err := loadMerch(c.Param("id"))
+	if err == sql.ErrNoRows {
+		apierr.NotFound(c, "Merchandise item not found")
+		return
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch merchandise", err)
 		return
 	}
 
+	if !patchJSON(c, item, "Invalid request body") {
+		return
+	}
+
+	saveMerch(c, c.Param("id"), item)
+}
+
+// saveMerch writes item over the merchandise item with the ID and answers with the result
+func saveMerch(c *gin.Context, id string, item *Merchandise) {
 	item.ID = id
 
 	_, err := database.DB.Exec(
@@ -128,7 +189,7 @@ func UpdateMerch(c *gin.Context) {
 	)
 
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to update merchandise: " + err.Error()})
+		apierr.Fail(c, "Failed to update merchandise", err)
 		return
 	}
 
@@ -139,11 +200,11 @@ func UpdateMerch(c *gin.Context) {
 	).Scan(&createdAt, &updatedAt)
 
 	if err == sql.ErrNoRows {
-		c.JSON(404, gin.H{"error": "Merchandise item not found"})
+		apierr.NotFound(c, "Merchandise item not found")
 		return
 	}
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to fetch updated item"})
+		apierr.Fail(c, "Failed to fetch updated item", err)
 		return
 	}
 
@@ -159,13 +220,13 @@ func DeleteMerch(c *gin.Context) {
 
 	result, err := database.DB.Exec("DELETE FROM merchandise WHERE id = $1", id)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to delete merchandise: " + err.Error()})
+		apierr.Fail(c, "Failed to delete merchandise", err)
 		return
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		c.JSON(404, gin.H{"error": "Merchandise item not found"})
+		apierr.NotFound(c, "Merchandise item not found")
 		return
 	}
 
@@ -2,7 +2,10 @@ package handlers
 
 import (
 	"database/sql"
+	"playtz-api/apierr"
 	"playtz-api/database"
+	"playtz-api/listing"
+	"playtz-api/slugs"
 	"strconv"
 	"time"
 
@@ -13,17 +16,18 @@ import (
 // Mix represents a music mix
 type Mix struct {
 	ID          string  `json:"id"`
-	RoomID      string  `json:"room_id"`
-	Title       string  `json:"title"`
-	Artist      string  `json:"artist"`
+	Slug        string  `json:"slug"` // Generated from the title when empty
+	RoomID      string  `json:"room_id" binding:"required"`
+	Title       string  `json:"title" binding:"required,notblank,max=255"`
+	Artist      string  `json:"artist" binding:"max=255"`
 	Description string  `json:"description"`
-	Duration    string  `json:"duration"`
-	Tracks      int     `json:"tracks"`
-	Color       string  `json:"color"`
-	TextColor   string  `json:"text_color"`
-	BorderColor string  `json:"border_color"`
-	Image       string  `json:"image,omitempty"`
-	AudioURL    string  `json:"audio_url,omitempty"`
+	Duration    string  `json:"duration" binding:"max=50"`
+	Tracks      int     `json:"tracks"` // Counted from the track list, not settable
+	Color       string  `json:"color" binding:"max=100"`
+	TextColor   string  `json:"text_color" binding:"max=50"`
+	BorderColor string  `json:"border_color" binding:"max=50"`
+	Image       string  `json:"image,omitempty" binding:"omitempty,url"`
+	AudioURL    string  `json:"audio_url,omitempty" binding:"omitempty,url"`
 	TrackList   []Track `json:"track_list,omitempty"`
 	Active      bool    `json:"active"`
 	CreatedAt   string  `json:"created_at,omitempty"`
@@ -33,33 +37,42 @@ type Mix struct {
 // Track represents a track in a mix
 type Track struct {
 	Number   int    `json:"number"`
-	Title    string `json:"title"`
-	Artist   string `json:"artist"`
-	Duration string `json:"duration"`
-	Link     string `json:"link,omitempty"` // Streaming URL (audio or video)
-	Type     string `json:"type,omitempty"` // "audio" or "video"
+	Title    string `json:"title" binding:"max=255"`
+	Artist   string `json:"artist" binding:"max=255"`
+	Duration string `json:"duration" binding:"max=50"`
+	Link     string `json:"link,omitempty" binding:"required,url"`                // Streaming URL (audio or video)
+	Type     string `json:"type,omitempty" binding:"omitempty,oneof=audio video"` // Guessed from the link when empty
 }
 
-// GetMixes returns all mixes, optionally filtered by room
-func GetMixes(c *gin.Context) {
-	roomID := c.Query("room_id")
-
-	var rows *sql.Rows
-	var err error
+// mixesListing is what GET /mixes can be filtered and sorted by
+var mixesListing = &listing.Spec{
+	From: "mixes",
+	Filters: map[string]listing.Filter{
+		"room_id": {Column: "room_id"},
+		"active":  {Column: "active", Type: listing.Bool},
+		"artist":  {Column: "artist"},
+		"slug":    {Column: "slug"},
+		"created": {Column: "created_at", Type: listing.Time, Range: true},
+	},
+	Sorts: map[string]string{
+		"created_at": "created_at",
+		"title":      "title",
+		"artist":     "COALESCE(artist, '')",
+	},
+	DefaultSort: "-created_at",
+}
 
-	if roomID != "" {
-		rows, err = database.DB.Query(
-			"SELECT id, room_id, title, artist, description, duration, tracks, color, text_color, border_color, image, audio_url, active, created_at, updated_at FROM mixes WHERE room_id = $1 ORDER BY created_at DESC",
-			roomID,
-		)
-	} else {
-		rows, err = database.DB.Query(
-			"SELECT id, room_id, title, artist, description, duration, tracks, color, text_color, border_color, image, audio_url, active, created_at, updated_at FROM mixes ORDER BY created_at DESC",
-		)
+// GetMixes returns a page of mixes, optionally filtered by room
+func GetMixes(c *gin.Context) {
+	q, err := listing.Parse(c, mixesListing)
+	if err != nil {
+		apierr.BadRequest(c, err.Error())
+		return
 	}
 
+	rows, err := q.Rows("SELECT id, COALESCE(slug, ''), room_id, title, artist, description, duration, tracks, color, text_color, border_color, image, audio_url, active, created_at, updated_at FROM mixes")
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to fetch mixes"})
+		apierr.Fail(c, "Failed to fetch mixes", err)
 		return
 	}
 	defer rows.Close()
@@ -68,7 +81,7 @@ func GetMixes(c *gin.Context) {
 	for rows.Next() {
 		var mix Mix
 		var createdAt, updatedAt time.Time
-		err := rows.Scan(&mix.ID, &mix.RoomID, &mix.Title, &mix.Artist, &mix.Description, &mix.Duration, &mix.Tracks, &mix.Color, &mix.TextColor, &mix.BorderColor, &mix.Image, &mix.AudioURL, &mix.Active, &createdAt, &updatedAt)
+		err := rows.Scan(&mix.ID, &mix.Slug, &mix.RoomID, &mix.Title, &mix.Artist, &mix.Description, &mix.Duration, &mix.Tracks, &mix.Color, &mix.TextColor, &mix.BorderColor, &mix.Image, &mix.AudioURL, &mix.Active, &createdAt, &updatedAt)
 		if err != nil {
 			continue
 		}
@@ -83,32 +96,32 @@ func GetMixes(c *gin.Context) {
 		mixes = append(mixes, mix)
 	}
 
-	c.JSON(200, mixes)
+	page, err := listing.Paginate(q, mixes, func(m Mix) string { return m.ID })
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch mixes", err)
+		return
+	}
+
+	c.JSON(200, page)
 }
 
+// mixFields are the members a PUT of a mix must send
+var mixFields = []string{"room_id", "title", "artist", "description", "duration", "color", "text_color", "border_color", "image", "audio_url", "active"}
+
 // GetMixByID returns a specific mix
 func GetMixByID(c *gin.Context) {
 	id := c.Param("id")
 
-	var mix Mix
-	var createdAt, updatedAt time.Time
-	err := database.DB.QueryRow(
-		"SELECT id, room_id, title, artist, description, duration, tracks, color, text_color, border_color, image, audio_url, active, created_at, updated_at FROM mixes WHERE id = $1",
-		id,
-	).Scan(&mix.ID, &mix.RoomID, &mix.Title, &mix.Artist, &mix.Description, &mix.Duration, &mix.Tracks, &mix.Color, &mix.TextColor, &mix.BorderColor, &mix.Image, &mix.AudioURL, &mix.Active, &createdAt, &updatedAt)
-
+	mix, err := loadMix(id)
 	if err == sql.ErrNoRows {
-		c.JSON(404, gin.H{"error": "Mix not found"})
+		apierr.NotFound(c, "Mix not found")
 		return
 	}
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to fetch mix"})
+		apierr.Fail(c, "Failed to fetch mix", err)
 		return
 	}
 
-	mix.CreatedAt = createdAt.Format(time.RFC3339)
-	mix.UpdatedAt = updatedAt.Format(time.RFC3339)
-
 	// Get tracks for this mix
 	rows, err := database.DB.Query(
 		"SELECT number, title, artist, duration, link, type FROM tracks WHERE mix_id = $1 ORDER BY number",
@@ -127,11 +140,32 @@ func GetMixByID(c *gin.Context) {
 	c.JSON(200, mix)
 }
 
+// loadMix fetches a mix by ID, without its track list
+func loadMix(id string) (*Mix, error) {
+	var mix Mix
+	var createdAt, updatedAt time.Time
+	err := database.DB.QueryRow(
+		"SELECT id, COALESCE(slug, ''), room_id, title, artist, description, duration, tracks, color, text_color, border_color, image, audio_url, active, created_at, updated_at FROM mixes WHERE id = $1",
+		id,
+	).Scan(&mix.ID, &mix.Slug, &mix.RoomID, &mix.Title, &mix.Artist, &mix.Description, &mix.Duration, &mix.Tracks, &mix.Color, &mix.TextColor, &mix.BorderColor, &mix.Image, &mix.AudioURL, &mix.Active, &createdAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	mix.CreatedAt = createdAt.Format(time.RFC3339)
+	mix.UpdatedAt = updatedAt.Format(time.RFC3339)
+	return &mix, nil
+}
+
+// GetMixBySlug returns a mix by its slug, redirecting former slugs
+func GetMixBySlug(c *gin.Context) {
+	serveBySlug(c, slugs.Mixes, "true", "Mix not found", GetMixByID)
+}
+
 // CreateMix creates a new mix
 func CreateMix(c *gin.Context) {
 	var mix Mix
-	if err := c.ShouldBindJSON(&mix); err != nil {
-		c.JSON(400, gin.H{"error": "Invalid request body"})
+	if !bindJSON(c, &mix, "Invalid request body") {
 		return
 	}
 
@@ -139,13 +173,20 @@ func CreateMix(c *gin.Context) {
 	mix.Active = true
 	mix.Tracks = 0
 
-	_, err := database.DB.Exec(
-		"INSERT INTO mixes (id, room_id, title, artist, description, duration, tracks, color, text_color, border_color, image, audio_url, active) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)",
-		mix.ID, mix.RoomID, mix.Title, mix.Artist, mix.Description, mix.Duration, mix.Tracks, mix.Color, mix.TextColor, mix.BorderColor, mix.Image, mix.AudioURL, mix.Active,
+	slug, err := slugs.Assign(slugs.Mixes, mix.ID, mix.Slug, mix.Title)
+	if err != nil {
+		respondSlugError(c, err)
+		return
+	}
+	mix.Slug = slug
+
+	_, err = database.DB.Exec(
+		"INSERT INTO mixes (id, slug, room_id, title, artist, description, duration, tracks, color, text_color, border_color, image, audio_url, active) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)",
+		mix.ID, mix.Slug, mix.RoomID, mix.Title, mix.Artist, mix.Description, mix.Duration, mix.Tracks, mix.Color, mix.TextColor, mix.BorderColor, mix.Image, mix.AudioURL, mix.Active,
 	)
 
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to create mix: " + err.Error()})
+		apierr.Fail(c, "Failed to create mix", err)
 		return
 	}
 
@@ -161,25 +202,70 @@ func CreateMix(c *gin.Context) {
 	c.JSON(201, mix)
 }
 
-// UpdateMix updates an existing mix
+// UpdateMix replaces a mix. Its track list is managed through the tracks routes.
 func UpdateMix(c *gin.Context) {
-	id := c.Param("id")
-
 	var mix Mix
-	if err := c.ShouldBindJSON(&mix); err != nil {
-		c.JSON(400, gin.H{"error": "Invalid request body"})
+	if !replaceJSON(c, &mix, "Invalid request body", mixFields...) {
 		return
 	}
 
+	saveMix(c, c.Param("id"), &mix)
+}
+
+// PatchMix changes the members of a mix sent in a JSON merge patch
+func PatchMix(c *gin.Context) {
+	mix, err := loadMix(c.Param("id"))
+	if err == sql.ErrNoRows {
+		apierr.NotFound(c, "Mix not found")
+		return
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch mix", err)
+		return
+	}
+
+	if !patchJSON(c, mix, "Invalid request body") {
+		return
+	}
+
+	saveMix(c, c.Param("id"), mix)
+}
+
+// saveMix writes mix over the mix with the ID and answers with the result
+func saveMix(c *gin.Context, id string, mix *Mix) {
 	mix.ID = id
 
-	_, err := database.DB.Exec(
+	// The slug and the other fields change together or not at all
+	tx, err := database.DB.Begin()
+	if err != nil {
+		apierr.Fail(c, "Failed to update mix", err)
+		return
+	}
+	defer tx.Rollback()
+
+	// An empty slug keeps the current one; a new one leaves the old as a redirect
+	slug, err := slugs.Change(tx, slugs.Mixes, id, mix.Slug)
+	if err == sql.ErrNoRows {
+		apierr.NotFound(c, "Mix not found")
+		return
+	}
+	if err != nil {
+		respondSlugError(c, err)
+		return
+	}
+	mix.Slug = slug
+
+	_, err = tx.Exec(
 		"UPDATE mixes SET room_id = $1, title = $2, artist = $3, description = $4, duration = $5, color = $6, text_color = $7, border_color = $8, image = $9, audio_url = $10, active = $11, updated_at = CURRENT_TIMESTAMP WHERE id = $12",
 		mix.RoomID, mix.Title, mix.Artist, mix.Description, mix.Duration, mix.Color, mix.TextColor, mix.BorderColor, mix.Image, mix.AudioURL, mix.Active, id,
 	)
 
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to update mix: " + err.Error()})
+		apierr.Fail(c, "Failed to update mix", err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		apierr.Fail(c, "Failed to update mix", err)
 		return
 	}
 
@@ -195,11 +281,11 @@ func UpdateMix(c *gin.Context) {
 	).Scan(&createdAt, &updatedAt)
 
 	if err == sql.ErrNoRows {
-		c.JSON(404, gin.H{"error": "Mix not found"})
+		apierr.NotFound(c, "Mix not found")
 		return
 	}
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to fetch updated mix"})
+		apierr.Fail(c, "Failed to fetch updated mix", err)
 		return
 	}
 
@@ -216,15 +302,16 @@ func DeleteMix(c *gin.Context) {
 
 	result, err := database.DB.Exec("DELETE FROM mixes WHERE id = $1", id)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to delete mix: " + err.Error()})
+		apierr.Fail(c, "Failed to delete mix", err)
 		return
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		c.JSON(404, gin.H{"error": "Mix not found"})
+		apierr.NotFound(c, "Mix not found")
 		return
 	}
+	slugs.Forget(slugs.Mixes, id)
 
 	c.JSON(200, gin.H{"message": "Mix deleted successfully"})
 }
@@ -234,8 +321,7 @@ func AddTrackToMix(c *gin.Context) {
 	mixID := c.Param("id")
 
 	var track Track
-	if err := c.ShouldBindJSON(&track); err != nil {
-		c.JSON(400, gin.H{"error": "Invalid request body"})
+	if !bindJSON(c, &track, "Invalid request body") {
 		return
 	}
 
@@ -264,14 +350,14 @@ func AddTrackToMix(c *gin.Context) {
 	)
 
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to add track: " + err.Error()})
+		apierr.Fail(c, "Failed to add track", err)
 		return
 	}
 
-	// Update mix track count
+	// Update mix track count (and updated_at, which cache validators rely on)
 	var trackCount int
 	database.DB.QueryRow("SELECT COUNT(*) FROM tracks WHERE mix_id = $1", mixID).Scan(&trackCount)
-	database.DB.Exec("UPDATE mixes SET tracks = $1 WHERE id = $2", trackCount, mixID)
+	database.DB.Exec("UPDATE mixes SET tracks = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2", trackCount, mixID)
 
 	c.JSON(200, gin.H{
 		"message": "Track added to mix",
@@ -279,20 +365,17 @@ func AddTrackToMix(c *gin.Context) {
 	})
 }
 
+// AddTracksRequest adds tracks to a mix from their links
+type AddTracksRequest struct {
+	Links []string `json:"links" binding:"required,min=1,dive,omitempty,url"` // Track URLs; empty entries are skipped
+}
+
 // AddTracksToMix adds multiple tracks to a mix via links
 func AddTracksToMix(c *gin.Context) {
 	mixID := c.Param("id")
 
-	var request struct {
-		Links []string `json:"links"` // Array of track URLs/links
-	}
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(400, gin.H{"error": "Invalid request body"})
-		return
-	}
-
-	if len(request.Links) == 0 {
-		c.JSON(400, gin.H{"error": "No track links provided"})
+	var request AddTracksRequest
+	if !bindJSON(c, &request, "No track links provided") {
 		return
 	}
 
@@ -328,10 +411,10 @@ func AddTracksToMix(c *gin.Context) {
 		}
 	}
 
-	// Update mix track count
+	// Update mix track count (and updated_at, which cache validators rely on)
 	var trackCount int
 	database.DB.QueryRow("SELECT COUNT(*) FROM tracks WHERE mix_id = $1", mixID).Scan(&trackCount)
-	database.DB.Exec("UPDATE mixes SET tracks = $1 WHERE id = $2", trackCount, mixID)
+	database.DB.Exec("UPDATE mixes SET tracks = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2", trackCount, mixID)
 
 	c.JSON(200, gin.H{
 		"message": "Tracks added to mix",
@@ -346,32 +429,32 @@ func RemoveTrackFromMix(c *gin.Context) {
 
 	trackNumber := c.Query("track_number")
 	if trackNumber == "" {
-		c.JSON(400, gin.H{"error": "Track number required"})
+		apierr.BadRequest(c, "Track number required")
 		return
 	}
 
 	num, err := strconv.Atoi(trackNumber)
 	if err != nil {
-		c.JSON(400, gin.H{"error": "Invalid track number"})
+		apierr.BadRequest(c, "Invalid track number")
 		return
 	}
 
 	result, err := database.DB.Exec("DELETE FROM tracks WHERE mix_id = $1 AND number = $2", mixID, num)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to remove track: " + err.Error()})
+		apierr.Fail(c, "Failed to remove track", err)
 		return
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		c.JSON(404, gin.H{"error": "Track not found"})
+		apierr.NotFound(c, "Track not found")
 		return
 	}
 
-	// Update mix track count
+	// Update mix track count (and updated_at, which cache validators rely on)
 	var trackCount int
 	database.DB.QueryRow("SELECT COUNT(*) FROM tracks WHERE mix_id = $1", mixID).Scan(&trackCount)
-	database.DB.Exec("UPDATE mixes SET tracks = $1 WHERE id = $2", trackCount, mixID)
+	database.DB.Exec("UPDATE mixes SET tracks = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2", trackCount, mixID)
 
 	c.JSON(200, gin.H{
 		"message":      "Track removed from mix",
@@ -2,7 +2,10 @@ package handlers
 
 import (
 	"database/sql"
+	"playtz-api/apierr"
 	"playtz-api/database"
+	"playtz-api/listing"
+	"playtz-api/slugs"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,21 +15,45 @@ import (
 // NewsArticle represents a news article
 type NewsArticle struct {
 	ID        string `json:"id"`
-	Title     string `json:"title"`
+	Slug      string `json:"slug"` // Generated from the title when empty
+	Title     string `json:"title" binding:"required,notblank,max=255"`
 	Content   string `json:"content"`
 	Excerpt   string `json:"excerpt,omitempty"`
-	Author    string `json:"author,omitempty"`
-	Image     string `json:"image,omitempty"`
+	Author    string `json:"author,omitempty" binding:"max=255"`
+	Image     string `json:"image,omitempty" binding:"omitempty,url"`
 	Published bool   `json:"published"`
 	CreatedAt string `json:"created_at,omitempty"`
 	UpdatedAt string `json:"updated_at,omitempty"`
 }
 
-// GetNews returns all news articles
+// newsListing is what GET /news can be filtered and sorted by
+var newsListing = &listing.Spec{
+	From: "news",
+	Filters: map[string]listing.Filter{
+		"published": {Column: "published", Type: listing.Bool},
+		"author":    {Column: "author"},
+		"slug":      {Column: "slug"},
+		"created":   {Column: "created_at", Type: listing.Time, Range: true},
+	},
+	Sorts: map[string]string{
+		"created_at": "created_at",
+		"updated_at": "updated_at",
+		"title":      "title",
+	},
+	DefaultSort: "-created_at",
+}
+
+// GetNews returns a page of news articles
 func GetNews(c *gin.Context) {
-	rows, err := database.DB.Query("SELECT id, title, content, author, image, published, created_at, updated_at FROM news ORDER BY created_at DESC")
+	q, err := listing.Parse(c, newsListing)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to fetch news"})
+		apierr.BadRequest(c, err.Error())
+		return
+	}
+
+	rows, err := q.Rows("SELECT id, COALESCE(slug, ''), title, content, author, image, published, created_at, updated_at FROM news")
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch news", err)
 		return
 	}
 	defer rows.Close()
@@ -35,7 +62,7 @@ func GetNews(c *gin.Context) {
 	for rows.Next() {
 		var article NewsArticle
 		var createdAt, updatedAt time.Time
-		err := rows.Scan(&article.ID, &article.Title, &article.Content, &article.Author, &article.Image, &article.Published, &createdAt, &updatedAt)
+		err := rows.Scan(&article.ID, &article.Slug, &article.Title, &article.Content, &article.Author, &article.Image, &article.Published, &createdAt, &updatedAt)
 		if err != nil {
 			continue
 		}
@@ -49,31 +76,30 @@ func GetNews(c *gin.Context) {
 		articles = append(articles, article)
 	}
 
-	c.JSON(200, articles)
+	page, err := listing.Paginate(q, articles, func(a NewsArticle) string { return a.ID })
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch news", err)
+		return
+	}
+
+	c.JSON(200, page)
 }
 
+// newsFields are the members a PUT of a news article must send
+var newsFields = []string{"title", "content", "author", "image", "published"}
+
 // GetNewsByID returns a specific news article
 func GetNewsByID(c *gin.Context) {
-	id := c.Param("id")
-
-	var article NewsArticle
-	var createdAt, updatedAt time.Time
-	err := database.DB.QueryRow(
-		"SELECT id, title, content, author, image, published, created_at, updated_at FROM news WHERE id = $1",
-		id,
-	).Scan(&article.ID, &article.Title, &article.Content, &article.Author, &article.Image, &article.Published, &createdAt, &updatedAt)
-
+	article, err := loadNews(c.Param("id"))
 	if err == sql.ErrNoRows {
-		c.JSON(404, gin.H{"error": "News article not found"})
+		apierr.NotFound(c, "News article not found")
 		return
 	}
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to fetch news article"})
+		apierr.Fail(c, "Failed to fetch news article", err)
 		return
 	}
 
-	article.CreatedAt = createdAt.Format(time.RFC3339)
-	article.UpdatedAt = updatedAt.Format(time.RFC3339)
 	if len(article.Content) > 200 {
 		article.Excerpt = article.Content[:200] + "..."
 	} else {
@@ -83,11 +109,32 @@ func GetNewsByID(c *gin.Context) {
 	c.JSON(200, article)
 }
 
+// loadNews fetches a news article by ID
+func loadNews(id string) (*NewsArticle, error) {
+	var article NewsArticle
+	var createdAt, updatedAt time.Time
+	err := database.DB.QueryRow(
+		"SELECT id, COALESCE(slug, ''), title, content, author, image, published, created_at, updated_at FROM news WHERE id = $1",
+		id,
+	).Scan(&article.ID, &article.Slug, &article.Title, &article.Content, &article.Author, &article.Image, &article.Published, &createdAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	article.CreatedAt = createdAt.Format(time.RFC3339)
+	article.UpdatedAt = updatedAt.Format(time.RFC3339)
+	return &article, nil
+}
+
+// GetNewsBySlug returns a news article by its slug, redirecting former slugs
+func GetNewsBySlug(c *gin.Context) {
+	serveBySlug(c, slugs.News, "true", "News article not found", GetNewsByID)
+}
+
 // CreateNews creates a new news article
 func CreateNews(c *gin.Context) {
 	var article NewsArticle
-	if err := c.ShouldBindJSON(&article); err != nil {
-		c.JSON(400, gin.H{"error": "Invalid request body"})
+	if !bindJSON(c, &article, "Invalid request body") {
 		return
 	}
 
@@ -95,13 +142,20 @@ func CreateNews(c *gin.Context) {
 	article.ID = uuid.New().String()
 	article.Published = false
 
-	_, err := database.DB.Exec(
-		"INSERT INTO news (id, title, content, author, image, published) VALUES ($1, $2, $3, $4, $5, $6)",
-		article.ID, article.Title, article.Content, article.Author, article.Image, article.Published,
+	slug, err := slugs.Assign(slugs.News, article.ID, article.Slug, article.Title)
+	if err != nil {
+		respondSlugError(c, err)
+		return
+	}
+	article.Slug = slug
+
+	_, err = database.DB.Exec(
+		"INSERT INTO news (id, slug, title, content, author, image, published) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+		article.ID, article.Slug, article.Title, article.Content, article.Author, article.Image, article.Published,
 	)
 
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to create news: " + err.Error()})
+		apierr.Fail(c, "Failed to create news", err)
 		return
 	}
 
@@ -118,25 +172,70 @@ func CreateNews(c *gin.Context) {
 	c.JSON(201, article)
 }
 
-// UpdateNews updates an existing news article
+// UpdateNews replaces a news article
 func UpdateNews(c *gin.Context) {
-	id := c.Param("id")
-
 	var article NewsArticle
-	if err := c.ShouldBindJSON(&article); err != nil {
-		c.JSON(400, gin.H{"error": "Invalid request body"})
+	if !replaceJSON(c, &article, "Invalid request body", newsFields...) {
 		return
 	}
 
+	saveNews(c, c.Param("id"), &article)
+}
+
+// PatchNews changes the members of a news article sent in a JSON merge patch
+func PatchNews(c *gin.Context) {
+	article, err := loadNews(c.Param("id"))
+	if err == sql.ErrNoRows {
+		apierr.NotFound(c, "News article not found")
+		return
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch news article", err)
+		return
+	}
+
+	if !patchJSON(c, article, "Invalid request body") {
+		return
+	}
+
+	saveNews(c, c.Param("id"), article)
+}
+
+// saveNews writes article over the news article with the ID and answers with the result
+func saveNews(c *gin.Context, id string, article *NewsArticle) {
 	article.ID = id
 
-	_, err := database.DB.Exec(
+	// The slug and the other fields change together or not at all
+	tx, err := database.DB.Begin()
+	if err != nil {
+		apierr.Fail(c, "Failed to update news", err)
+		return
+	}
+	defer tx.Rollback()
+
+	// An empty slug keeps the current one; a new one leaves the old as a redirect
+	slug, err := slugs.Change(tx, slugs.News, id, article.Slug)
+	if err == sql.ErrNoRows {
+		apierr.NotFound(c, "News article not found")
+		return
+	}
+	if err != nil {
+		respondSlugError(c, err)
+		return
+	}
+	article.Slug = slug
+
+	_, err = tx.Exec(
 		"UPDATE news SET title = $1, content = $2, author = $3, image = $4, published = $5, updated_at = CURRENT_TIMESTAMP WHERE id = $6",
 		article.Title, article.Content, article.Author, article.Image, article.Published, id,
 	)
 
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to update news: " + err.Error()})
+		apierr.Fail(c, "Failed to update news", err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		apierr.Fail(c, "Failed to update news", err)
 		return
 	}
 
@@ -148,11 +247,11 @@ func UpdateNews(c *gin.Context) {
 	).Scan(&createdAt, &updatedAt)
 
 	if err == sql.ErrNoRows {
-		c.JSON(404, gin.H{"error": "News article not found"})
+		apierr.NotFound(c, "News article not found")
 		return
 	}
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to fetch updated article"})
+		apierr.Fail(c, "Failed to fetch updated article", err)
 		return
 	}
 
@@ -168,15 +267,16 @@ func DeleteNews(c *gin.Context) {
 
 	result, err := database.DB.Exec("DELETE FROM news WHERE id = $1", id)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to delete news: " + err.Error()})
+		apierr.Fail(c, "Failed to delete news", err)
 		return
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		c.JSON(404, gin.H{"error": "News article not found"})
+		apierr.NotFound(c, "News article not found")
 		return
 	}
+	slugs.Forget(slugs.News, id)
 
 	c.JSON(200, gin.H{"message": "News article deleted successfully"})
 }
@@ -0,0 +1,357 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"playtz-api/apierr"
+	"playtz-api/auth"
+	"playtz-api/database"
+	"playtz-api/middleware"
+	"playtz-api/models"
+	"playtz-api/oidc"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	errSSONotAllowed = errors.New("no role for this identity")
+	errSSOConflict   = errors.New("email is linked to another SSO account")
+)
+
+// usernameInvalidChars are stripped from email local parts to build usernames
+var usernameInvalidChars = regexp.MustCompile(`[^a-z0-9._-]`)
+
+// OIDCLogin starts a single sign-on login and redirects to the identity provider.
+// Optional query parameters: redirect_to (frontend URL to return to) and login_hint.
+func OIDCLogin(c *gin.Context) {
+	provider := oidc.Get()
+	if provider == nil {
+		apierr.NotFound(c, "Single sign-on is not configured")
+		return
+	}
+
+	redirectTo, ok := ssoRedirectTarget(c.Query("redirect_to"))
+	if !ok {
+		apierr.BadRequest(c, "redirect_to must be on an allowed origin")
+		return
+	}
+
+	state, login, codeChallenge, err := oidc.StartLogin(redirectTo)
+	if err != nil {
+		apierr.Fail(c, "Failed to start single sign-on", err)
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(state, login.Nonce, codeChallenge, c.Query("login_hint"))
+	if err != nil {
+		log.Printf("OIDC login failed: %v", err)
+		apierr.Respond(c, apierr.New(502, apierr.CodeUpstreamUnavailable, "Identity provider is unavailable"))
+		return
+	}
+
+	// The callback only accepts the state in the browser that started the login
+	c.Writer.Header().Add("Set-Cookie", fmt.Sprintf(
+		"%s=%s; Path=/api/v1/auth/oidc; Max-Age=%d; HttpOnly; Secure; SameSite=Lax",
+		oidc.StateCookieName, oidc.StateHash(state), int(oidc.StateTTL.Seconds()),
+	))
+	c.Redirect(302, authURL)
+}
+
+// OIDCCallback completes a single sign-on login: it redeems the code, verifies the ID token,
+// finds, links or provisions the user and redirects back to the frontend with the auth cookies set.
+// Failures redirect with ?sso_error=<code>.
+func OIDCCallback(c *gin.Context) {
+	provider := oidc.Get()
+	if provider == nil {
+		apierr.NotFound(c, "Single sign-on is not configured")
+		return
+	}
+
+	// Reject a callback that did not start in this browser, so nobody can log a victim into
+	// the attacker's account by sending them the attacker's callback URL
+	stateCookie, _ := c.Cookie(oidc.StateCookieName)
+	c.Writer.Header().Add("Set-Cookie", oidc.StateCookieName+"=; Path=/api/v1/auth/oidc; Max-Age=0; HttpOnly; Secure; SameSite=Lax")
+	if !oidc.StateMatches(c.Query("state"), stateCookie) {
+		apierr.BadRequest(c, "Invalid or expired login - please start again")
+		return
+	}
+
+	login, err := oidc.ConsumeState(c.Query("state"))
+	if err == oidc.ErrStateInvalid {
+		apierr.BadRequest(c, "Invalid or expired login - please start again")
+		return
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to verify login state", err)
+		return
+	}
+
+	// The provider reports errors such as access_denied instead of a code
+	if providerError := c.Query("error"); providerError != "" {
+		ssoRedirectError(c, login.RedirectTo, "provider_error")
+		return
+	}
+
+	idToken, err := provider.Exchange(c.Query("code"), login.CodeVerifier)
+	if err != nil {
+		log.Printf("OIDC code exchange failed: %v", err)
+		ssoRedirectError(c, login.RedirectTo, "exchange_failed")
+		return
+	}
+
+	identity, err := provider.VerifyIDToken(idToken, login.Nonce)
+	if err != nil {
+		log.Printf("OIDC id_token rejected: %v", err)
+		ssoRedirectError(c, login.RedirectTo, "invalid_id_token")
+		return
+	}
+	if !identity.EmailVerified || identity.Email == "" {
+		ssoRedirectError(c, login.RedirectTo, "email_not_verified")
+		return
+	}
+
+	user, err := ssoUser(c, identity)
+	if err == errSSONotAllowed {
+		ssoRedirectError(c, login.RedirectTo, "not_authorized")
+		return
+	}
+	if err == errSSOConflict {
+		ssoRedirectError(c, login.RedirectTo, "account_conflict")
+		return
+	}
+	if err != nil {
+		log.Printf("OIDC user lookup failed: %v", err)
+		ssoRedirectError(c, login.RedirectTo, "server_error")
+		return
+	}
+	if !user.Active {
+		ssoRedirectError(c, login.RedirectTo, "account_inactive")
+		return
+	}
+
+	// Local 2FA still applies; the frontend finishes it through /auth/2fa/login
+	var totpEnabled bool
+	database.DB.QueryRow("SELECT COALESCE(totp_enabled, false) FROM users WHERE id = $1", user.ID).Scan(&totpEnabled)
+	if totpEnabled {
+		challengeToken, err := auth.GenerateChallengeToken(user.ID)
+		if err != nil {
+			ssoRedirectError(c, login.RedirectTo, "server_error")
+			return
+		}
+		fragment := url.Values{"two_factor_required": {"true"}, "challenge_token": {challengeToken}}
+		c.Redirect(302, login.RedirectTo+"#"+fragment.Encode())
+		return
+	}
+
+	if _, err := issueLoginTokens(c, user); err != nil {
+		ssoRedirectError(c, login.RedirectTo, "server_error")
+		return
+	}
+
+	c.Redirect(302, login.RedirectTo)
+}
+
+// ssoUser finds the user for an SSO identity. Users are matched by issuer and subject,
+// then linked by verified email, then provisioned with the role from the role mappings.
+func ssoUser(c *gin.Context, identity *oidc.Identity) (*User, error) {
+	var userID string
+	err := database.DB.QueryRow(
+		"SELECT id FROM users WHERE oidc_issuer = $1 AND oidc_subject = $2",
+		identity.Issuer, identity.Subject,
+	).Scan(&userID)
+	if err == nil {
+		if err := syncSSORole(userID, identity); err != nil {
+			return nil, err
+		}
+		return loadUser(userID)
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	// Link an existing staff account with the same email. Only identities the role mappings admit,
+	// with an email the provider itself verified, may take over an account: otherwise anyone able
+	// to sign in at the provider (another tenant, say) could claim a staff email.
+	var linkedSubject sql.NullString
+	var accountType string
+	err = database.DB.QueryRow(
+		"SELECT id, oidc_subject, account_type FROM users WHERE LOWER(email) = $1",
+		identity.Email,
+	).Scan(&userID, &linkedSubject, &accountType)
+	if err == nil {
+		if (linkedSubject.Valid && linkedSubject.String != "") || accountType != models.AccountTypeStaff {
+			return nil, errSSOConflict
+		}
+		if identity.EmailAssumed {
+			return nil, errSSOConflict
+		}
+		if oidc.RoleFor(identity) == "" {
+			return nil, errSSONotAllowed
+		}
+		if _, err := database.DB.Exec(
+			"UPDATE users SET oidc_issuer = $1, oidc_subject = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $3",
+			identity.Issuer, identity.Subject, userID,
+		); err != nil {
+			return nil, err
+		}
+		recordAccountEvent(c, "users.sso.link", userID)
+		if err := syncSSORole(userID, identity); err != nil {
+			return nil, err
+		}
+		return loadUser(userID)
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	if provision, err := strconv.ParseBool(os.Getenv("OIDC_AUTO_PROVISION")); err == nil && !provision {
+		return nil, errSSONotAllowed
+	}
+	return provisionSSOUser(c, identity)
+}
+
+// provisionSSOUser creates a user for an SSO identity
+func provisionSSOUser(c *gin.Context, identity *oidc.Identity) (*User, error) {
+	roleID, err := ssoRoleID(identity)
+	if err != nil {
+		return nil, err
+	}
+	if roleID == "" {
+		return nil, errSSONotAllowed
+	}
+
+	username, err := uniqueUsername(identity.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	// SSO users sign in at the provider; the local password is random and unknown
+	password, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	userID := uuid.New().String()
+	_, err = database.DB.Exec(`
+		INSERT INTO users (id, email, username, password_hash, first_name, last_name, role_id, active,
+		                   password_change_required, oidc_issuer, oidc_subject)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, true, false, $8, $9)
+	`, userID, identity.Email, username, string(hashedPassword), identity.GivenName, identity.FamilyName,
+		roleID, identity.Issuer, identity.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Provisioned SSO user %s (%s)", username, identity.Email)
+	recordAccountEvent(c, "users.sso.provision", userID)
+	return loadUser(userID)
+}
+
+// syncSSORole updates the user's role from the role mappings when OIDC_SYNC_ROLES=true
+func syncSSORole(userID string, identity *oidc.Identity) error {
+	if sync, _ := strconv.ParseBool(os.Getenv("OIDC_SYNC_ROLES")); !sync {
+		return nil
+	}
+
+	roleID, err := ssoRoleID(identity)
+	if err != nil || roleID == "" {
+		return err
+	}
+
+	result, err := database.DB.Exec(
+		"UPDATE users SET role_id = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2 AND role_id IS DISTINCT FROM $1",
+		roleID, userID,
+	)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected > 0 {
+		auth.GetPermissionCache().InvalidateUser(userID)
+	}
+	return nil
+}
+
+// ssoRoleID returns the ID of the active role mapped to an identity ("" if none)
+func ssoRoleID(identity *oidc.Identity) (string, error) {
+	roleName := oidc.RoleFor(identity)
+	if roleName == "" {
+		return "", nil
+	}
+
+	var roleID string
+	err := database.DB.QueryRow("SELECT id FROM roles WHERE name = $1 AND active = true", roleName).Scan(&roleID)
+	if err == sql.ErrNoRows {
+		log.Printf("OIDC role mapping refers to unknown role %q", roleName)
+		return "", nil
+	}
+	return roleID, err
+}
+
+// uniqueUsername derives an unused username from an email address
+func uniqueUsername(email string) (string, error) {
+	base := email
+	if at := strings.Index(email, "@"); at >= 0 {
+		base = email[:at]
+	}
+	base = usernameInvalidChars.ReplaceAllString(strings.ToLower(base), "")
+	if base == "" {
+		base = "user"
+	}
+
+	username := base
+	for i := 2; ; i++ {
+		var exists bool
+		if err := database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE username = $1)", username).Scan(&exists); err != nil {
+			return "", err
+		}
+		if !exists {
+			return username, nil
+		}
+		username = base + strconv.Itoa(i)
+	}
+}
+
+// ssoRedirectTarget validates redirect_to against the allowed frontend origins.
+// An empty value falls back to OIDC_POST_LOGIN_REDIRECT.
+func ssoRedirectTarget(redirectTo string) (string, bool) {
+	if redirectTo == "" {
+		redirectTo = os.Getenv("OIDC_POST_LOGIN_REDIRECT")
+		if redirectTo == "" {
+			redirectTo = "https://playtzadmin.vercel.app/"
+		}
+		return redirectTo, true
+	}
+
+	target, err := url.Parse(redirectTo)
+	if err != nil || target.Scheme == "" || target.Host == "" {
+		return "", false
+	}
+	return redirectTo, middleware.IsAllowedOrigin(target.Scheme + "://" + target.Host)
+}
+
+// ssoRedirectError sends the browser back to the frontend with an error code
+func ssoRedirectError(c *gin.Context, redirectTo, code string) {
+	target, err := url.Parse(redirectTo)
+	if err != nil {
+		apierr.Respond(c, apierr.New(400, apierr.CodeInvalidRequest, "Single sign-on failed").With("sso_error", code))
+		return
+	}
+
+	query := target.Query()
+	query.Set("sso_error", code)
+	target.RawQuery = query.Encode()
+	c.Redirect(302, target.String())
+}
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"playtz-api/apierr"
+	"playtz-api/listing"
+	"playtz-api/models"
+	"playtz-api/openapi"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	openAPIDocument []byte
+	openAPIOnce     sync.Once
+)
+
+// OpenAPISpec serves the OpenAPI document of every route registered on r. It is built on
+// the first request, when all routes exist; undocumented routes are logged.
+func OpenAPISpec(r *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		openAPIOnce.Do(func() {
+			doc, undocumented := BuildOpenAPI(r.Routes())
+			if len(undocumented) > 0 {
+				log.Printf("WARNING: Routes missing from the OpenAPI operations in handlers/openapi.go: %s", strings.Join(undocumented, ", "))
+			}
+
+			var err error
+			openAPIDocument, err = json.Marshal(doc)
+			if err != nil {
+				log.Printf("Failed to encode OpenAPI document: %v", err)
+			}
+		})
+
+		if openAPIDocument == nil {
+			apierr.Internal(c, "Failed to build API specification")
+			return
+		}
+		c.Data(200, "application/json; charset=utf-8", openAPIDocument)
+	}
+}
+
+// BuildOpenAPI returns the OpenAPI document for routes and the routes missing from apiOperations
+func BuildOpenAPI(routes gin.RoutesInfo) (*openapi.Document, []string) {
+	return openapi.Build(openapi.Info{
+		Title:       "Playtz API",
+		Version:     "1.0.0",
+		Description: "Station content, shop and account API. See API_DOCUMENTATION.md for guides.",
+	}, routes, apiOperations)
+}
+
+// Descriptions of full replacements (PUT) and partial updates (PATCH), see handlers/patch.go
+const (
+	replaceDescription = "Full replacement: every field the resource stores must be sent, an empty value clearing it. Use PATCH to change only some fields."
+	patchDescription   = "JSON merge patch (RFC 7396): only the fields sent change, null resets one to its empty value. The result must pass the same rules as a create."
+)
+
+// Shapes of responses built with gin.H, for the OpenAPI document
+type (
+	trackAddedResponse struct {
+		Message string `json:"message"`
+		Track   Track  `json:"track"`
+	}
+	tracksAddedResponse struct {
+		Message string  `json:"message"`
+		Tracks  []Track `json:"tracks"`
+		Count   int     `json:"count"`
+	}
+	publicRoomResponse struct {
+		Room  PublicRoom  `json:"room"`
+		Mixes []PublicMix `json:"mixes"`
+	}
+	searchResponse struct {
+		Query      string             `json:"query"`
+		Data       []SearchResult     `json:"data"`
+		Pagination listing.Pagination `json:"pagination"`
+	}
+	auditLogResponse struct {
+		Data       []AuditLogEntry `json:"data"`
+		Pagination struct {
+			Page       int `json:"page"`
+			Limit      int `json:"limit"`
+			Total      int `json:"total"`
+			TotalPages int `json:"total_pages"`
+		} `json:"pagination"`
+	}
+	currentUserResponse struct {
+		Authenticated bool  `json:"authenticated"`
+		User          *User `json:"user"`
+	}
+	csrfTokenResponse struct {
+		CSRFToken string `json:"csrf_token"`
+		Header    string `json:"header"`
+	}
+	revokedSessionsResponse struct {
+		Message string `json:"message"`
+		Revoked int    `json:"revoked"`
+	}
+	multipleUploadResponse struct {
+		Images []UploadResponse `json:"images"`
+		Count  int              `json:"count"`
+	}
+)
+
+var (
+	cartQuery   = []openapi.Param{{Name: "cart_id", Description: "One of the caller's carts; defaults to their most recent cart"}}
+	uploadQuery = []openapi.Param{{Name: "folder", Description: "Cloudinary folder (default playtz)"}}
+)
+
+// apiOperations documents every route in main.go, keyed by method and Gin path.
+// scripts/test_openapi.sh fails when a route is missing here.
+var apiOperations = map[string]openapi.Operation{
+	// Pages and infrastructure
+	"GET /health":                {Tag: "System", Summary: "Health check"},
+	"GET /.well-known/jwks.json": {Tag: "System", Summary: "Public keys for verifying access tokens"},
+	"GET /debug/cloudinary":      {Tag: "System", Summary: "Show whether Cloudinary is configured"},
+	"GET /openapi.json":          {Tag: "System", Summary: "This OpenAPI document"},
+	"GET /docs":                  {Tag: "System", Summary: "Interactive API documentation", HTML: true},
+	"GET /admin/login":           {Tag: "Pages", Summary: "Admin login page", HTML: true},
+	"GET /admin/dashboard":       {Tag: "Pages", Summary: "Admin dashboard page", HTML: true, Auth: openapi.Staff, Permission: "admin.dashboard"},
+
+	// Authentication
+	"POST /api/v1/auth/login":               {Tag: "Auth", Summary: "Log in with username or email and password", Request: LoginRequest{}, Response: LoginResponse{}},
+	"POST /api/v1/auth/logout":              {Tag: "Auth", Summary: "Log out and revoke the session", Response: openapi.Message{}},
+	"POST /api/v1/auth/refresh":             {Tag: "Auth", Summary: "Exchange a refresh token for new tokens", Description: "The body is optional; browsers send the refresh_token cookie.", Request: RefreshRequest{}, Response: LoginResponse{}},
+	"GET /api/v1/auth/csrf":                 {Tag: "Auth", Summary: "Get the CSRF token for cookie-authenticated writes", Response: csrfTokenResponse{}},
+	"POST /api/v1/auth/forgot-password":     {Tag: "Auth", Summary: "Email a password reset link", Request: ForgotPasswordRequest{}, Response: openapi.Message{}},
+	"POST /api/v1/auth/reset-password":      {Tag: "Auth", Summary: "Set a new password with a reset token", Request: ResetPasswordRequest{}, Response: openapi.Message{}},
+	"POST /api/v1/auth/register":            {Tag: "Auth", Summary: "Create a listener account", Request: RegisterRequest{}, Response: openapi.Message{}, Status: 202},
+	"POST /api/v1/auth/verify-email":        {Tag: "Auth", Summary: "Verify a listener's email address", Request: VerifyEmailRequest{}, Response: openapi.Message{}},
+	"POST /api/v1/auth/resend-verification": {Tag: "Auth", Summary: "Resend the verification email", Request: ForgotPasswordRequest{}, Response: openapi.Message{}},
+	"GET /api/v1/auth/me":                   {Tag: "Auth", Summary: "Current user, or authenticated false", Auth: openapi.Optional, Response: currentUserResponse{}},
+	"POST /api/v1/auth/change-password":     {Tag: "Auth", Summary: "Change the current user's password", Auth: openapi.Account, Request: ChangePasswordRequest{}, Response: openapi.Message{}},
+	"GET /api/v1/auth/sessions":             {Tag: "Auth", Summary: "List the current user's sessions", Auth: openapi.Account, Response: []SessionResponse{}},
+	"DELETE /api/v1/auth/sessions":          {Tag: "Auth", Summary: "Sign out every other session", Auth: openapi.Account, Response: revokedSessionsResponse{}},
+	"DELETE /api/v1/auth/sessions/:id":      {Tag: "Auth", Summary: "Sign out one session", Auth: openapi.Account, Response: openapi.Message{}},
+	"POST /api/v1/auth/2fa/login":           {Tag: "Auth", Summary: "Complete a login with a TOTP or recovery code", Request: TwoFactorLoginRequest{}, Response: LoginResponse{}},
+	"POST /api/v1/auth/2fa/enroll":          {Tag: "Auth", Summary: "Start two-factor enrollment", Auth: openapi.Account, Response: TwoFactorEnrollResponse{}},
+	"POST /api/v1/auth/2fa/verify":          {Tag: "Auth", Summary: "Confirm two-factor enrollment", Auth: openapi.Account, Request: TwoFactorCodeRequest{}},
+	"POST /api/v1/auth/2fa/disable":         {Tag: "Auth", Summary: "Turn off two-factor authentication", Auth: openapi.Account, Request: TwoFactorDisableRequest{}, Response: openapi.Message{}},
+	"GET /api/v1/auth/oidc/login": {Tag: "Auth", Summary: "Start single sign-on", Status: 302, Query: []openapi.Param{
+		{Name: "redirect_to", Description: "Allowed frontend URL to return to"},
+		{Name: "login_hint", Description: "Email to pre-fill at the identity provider"},
+	}},
+	"GET /api/v1/auth/oidc/callback": {Tag: "Auth", Summary: "Single sign-on callback from the identity provider", Status: 302, Query: []openapi.Param{
+		{Name: "code"}, {Name: "state", Required: true}, {Name: "error"},
+	}},
+
+	// Public content
+	"GET /api/v1/public/news":              {Tag: "Public", Summary: "Published news articles", Response: []PublicNewsArticle{}},
+	"GET /api/v1/public/news/:id":          {Tag: "Public", Summary: "Published news article", Response: PublicNewsArticle{}},
+	"GET /api/v1/public/news/slug/:slug":   {Tag: "Public", Summary: "Published news article by slug", Response: PublicNewsArticle{}},
+	"GET /api/v1/public/events":            {Tag: "Public", Summary: "Active events", Response: []PublicEvent{}},
+	"GET /api/v1/public/events/:id":        {Tag: "Public", Summary: "Active event", Response: PublicEvent{}},
+	"GET /api/v1/public/events/slug/:slug": {Tag: "Public", Summary: "Active event by slug", Response: PublicEvent{}},
+	"GET /api/v1/public/rooms":             {Tag: "Public", Summary: "Active rooms", Response: []PublicRoom{}},
+	"GET /api/v1/public/rooms/:id":         {Tag: "Public", Summary: "Active room with its mixes", Response: publicRoomResponse{}},
+	"GET /api/v1/public/rooms/slug/:slug":  {Tag: "Public", Summary: "Active room with its mixes by slug", Response: publicRoomResponse{}},
+	"GET /api/v1/public/mixes":             {Tag: "Public", Summary: "Active mixes with track lists", Query: []openapi.Param{{Name: "room_id"}}, Response: []PublicMix{}},
+	"GET /api/v1/public/mixes/:id":         {Tag: "Public", Summary: "Active mix with track list", Response: PublicMix{}},
+	"GET /api/v1/public/mixes/slug/:slug":  {Tag: "Public", Summary: "Active mix with track list by slug", Response: PublicMix{}},
+	"GET /api/v1/public/merch":             {Tag: "Public", Summary: "Merchandise in stock", Response: []PublicMerchandise{}},
+	"GET /api/v1/public/merch/:id":         {Tag: "Public", Summary: "Merchandise item in stock", Response: PublicMerchandise{}},
+	"GET /api/v1/public/careers":           {Tag: "Public", Summary: "Open positions", Response: []PublicCareer{}},
+	"GET /api/v1/public/careers/:id":       {Tag: "Public", Summary: "Open position", Response: PublicCareer{}},
+	"GET /api/v1/search": {Tag: "Public", Summary: "Full-text search across visible content", Response: searchResponse{}, Query: []openapi.Param{
+		{Name: "q", Description: "Search terms, at least 2 characters", Required: true},
+		{Name: "type", Description: "Comma separated: news, events, mixes, tracks, merch, careers"},
+		{Name: "page"}, {Name: "limit", Description: "Default 20, max 50"},
+	}},
+
+	// Account, cart and orders
+	"GET /api/v1/account/profile":   {Tag: "Account", Summary: "Current account's profile", Auth: openapi.Account, Response: Profile{}},
+	"PUT /api/v1/account/profile":   {Tag: "Account", Summary: "Replace the current account's name", Description: replaceDescription, Auth: openapi.Account, Request: UpdateProfileRequest{}, Response: Profile{}},
+	"PATCH /api/v1/account/profile": {Tag: "Account", Summary: "Change part of the current account's name", Description: patchDescription, Auth: openapi.Account, Request: UpdateProfileRequest{}, MergePatch: true, Response: Profile{}},
+	"GET /api/v1/cart":              {Tag: "Cart", Summary: "Current cart", Auth: openapi.User, Query: cartQuery, Response: Cart{}},
+	"POST /api/v1/cart/add":         {Tag: "Cart", Summary: "Add merchandise to the cart", Auth: openapi.User, Status: 201, Request: AddToCartRequest{}},
+	"PUT /api/v1/cart/update":       {Tag: "Cart", Summary: "Change an item's quantity", Auth: openapi.User, Request: UpdateCartItemRequest{}},
+	"DELETE /api/v1/cart/remove": {Tag: "Cart", Summary: "Remove an item from the cart", Auth: openapi.User, Query: append([]openapi.Param{
+		{Name: "item_id"}, {Name: "product_id", Description: "Alternative to item_id"},
+	}, cartQuery...), Response: openapi.Message{}},
+	"DELETE /api/v1/cart/clear":     {Tag: "Cart", Summary: "Empty the cart", Auth: openapi.User, Query: cartQuery, Response: openapi.Message{}},
+	"POST /api/v1/checkout":         {Tag: "Orders", Summary: "Place an order from the cart", Auth: openapi.User, Request: CheckoutRequest{}, Response: Order{}, Status: 201},
+	"GET /api/v1/orders":            {Tag: "Orders", Summary: "Orders: all with orders.read, otherwise the caller's", Auth: openapi.Account, Listing: ordersListing, Response: listing.Page[Order]{}},
+	"GET /api/v1/orders/:id":        {Tag: "Orders", Summary: "Order", Auth: openapi.Account, Response: Order{}},
+	"PUT /api/v1/orders/:id/status": {Tag: "Orders", Summary: "Change an order's status", Auth: openapi.Staff, Permission: "orders.write", Request: UpdateOrderStatusRequest{}},
+
+	// Staff: dashboard and content
+	"GET /api/v1/admin/dashboard": {Tag: "Admin", Summary: "Dashboard statistics and recent activity", Auth: openapi.Staff, Permission: "admin.dashboard", Response: AdminDashboardData{}},
+
+	"GET /api/v1/news":                   {Tag: "News", Summary: "List news articles", Auth: openapi.Staff, Permission: "news.read", Listing: newsListing, Response: listing.Page[NewsArticle]{}},
+	"GET /api/v1/news/:id":               {Tag: "News", Summary: "Get a news article", Auth: openapi.Staff, Permission: "news.read", Response: NewsArticle{}},
+	"GET /api/v1/news/slug/:slug":        {Tag: "News", Summary: "Get a news article by slug", Auth: openapi.Staff, Permission: "news.read", Response: NewsArticle{}},
+	"POST /api/v1/news":                  {Tag: "News", Summary: "Create a news article", Auth: openapi.Staff, Permission: "news.write", Request: NewsArticle{}, Response: NewsArticle{}, Status: 201},
+	"PUT /api/v1/news/:id":               {Tag: "News", Summary: "Replace a news article", Description: replaceDescription, Auth: openapi.Staff, Permission: "news.write", Request: NewsArticle{}, Response: NewsArticle{}},
+	"PATCH /api/v1/news/:id":             {Tag: "News", Summary: "Change some fields of a news article", Description: patchDescription, Auth: openapi.Staff, Permission: "news.write", Request: NewsArticle{}, MergePatch: true, Response: NewsArticle{}},
+	"DELETE /api/v1/news/:id":            {Tag: "News", Summary: "Delete a news article", Auth: openapi.Staff, Permission: "news.delete", Response: openapi.Message{}},
+	"GET /api/v1/events":                 {Tag: "Events", Summary: "List events", Auth: openapi.Staff, Permission: "events.read", Listing: eventsListing, Response: listing.Page[Event]{}},
+	"GET /api/v1/events/:id":             {Tag: "Events", Summary: "Get an event", Auth: openapi.Staff, Permission: "events.read", Response: Event{}},
+	"GET /api/v1/events/slug/:slug":      {Tag: "Events", Summary: "Get an event by slug", Auth: openapi.Staff, Permission: "events.read", Response: Event{}},
+	"POST /api/v1/events":                {Tag: "Events", Summary: "Create an event", Auth: openapi.Staff, Permission: "events.write", Request: Event{}, Response: Event{}, Status: 201},
+	"PUT /api/v1/events/:id":             {Tag: "Events", Summary: "Replace an event", Description: replaceDescription, Auth: openapi.Staff, Permission: "events.write", Request: Event{}, Response: Event{}},
+	"PATCH /api/v1/events/:id":           {Tag: "Events", Summary: "Change some fields of an event", Description: patchDescription, Auth: openapi.Staff, Permission: "events.write", Request: Event{}, MergePatch: true, Response: Event{}},
+	"DELETE /api/v1/events/:id":          {Tag: "Events", Summary: "Delete an event", Auth: openapi.Staff, Permission: "events.delete", Response: openapi.Message{}},
+	"GET /api/v1/merch":                  {Tag: "Merchandise", Summary: "List merchandise", Auth: openapi.Staff, Permission: "merchandise.read", Listing: merchListing, Response: listing.Page[Merchandise]{}},
+	"GET /api/v1/merch/:id":              {Tag: "Merchandise", Summary: "Get a merchandise item", Auth: openapi.Staff, Permission: "merchandise.read", Response: Merchandise{}},
+	"POST /api/v1/merch":                 {Tag: "Merchandise", Summary: "Create a merchandise item", Auth: openapi.Staff, Permission: "merchandise.write", Request: Merchandise{}, Response: Merchandise{}, Status: 201},
+	"PUT /api/v1/merch/:id":              {Tag: "Merchandise", Summary: "Replace a merchandise item", Description: replaceDescription, Auth: openapi.Staff, Permission: "merchandise.write", Request: Merchandise{}, Response: Merchandise{}},
+	"PATCH /api/v1/merch/:id":            {Tag: "Merchandise", Summary: "Change some fields of a merchandise item", Description: patchDescription, Auth: openapi.Staff, Permission: "merchandise.write", Request: Merchandise{}, MergePatch: true, Response: Merchandise{}},
+	"DELETE /api/v1/merch/:id":           {Tag: "Merchandise", Summary: "Delete a merchandise item", Auth: openapi.Staff, Permission: "merchandise.delete", Response: openapi.Message{}},
+	"GET /api/v1/careers":                {Tag: "Careers", Summary: "List career listings", Auth: openapi.Staff, Permission: "careers.read", Listing: careersListing, Response: listing.Page[Career]{}},
+	"GET /api/v1/careers/:id":            {Tag: "Careers", Summary: "Get a career listing", Auth: openapi.Staff, Permission: "careers.read", Response: Career{}},
+	"POST /api/v1/careers":               {Tag: "Careers", Summary: "Create a career listing", Auth: openapi.Staff, Permission: "careers.write", Request: Career{}, Response: Career{}, Status: 201},
+	"PUT /api/v1/careers/:id":            {Tag: "Careers", Summary: "Replace a career listing", Description: replaceDescription, Auth: openapi.Staff, Permission: "careers.write", Request: Career{}, Response: Career{}},
+	"PATCH /api/v1/careers/:id":          {Tag: "Careers", Summary: "Change some fields of a career listing", Description: patchDescription, Auth: openapi.Staff, Permission: "careers.write", Request: Career{}, MergePatch: true, Response: Career{}},
+	"DELETE /api/v1/careers/:id":         {Tag: "Careers", Summary: "Delete a career listing", Auth: openapi.Staff, Permission: "careers.delete", Response: openapi.Message{}},
+	"GET /api/v1/rooms":                  {Tag: "Rooms", Summary: "List rooms", Auth: openapi.Staff, Permission: "rooms.read", Response: []models.Room{}},
+	"GET /api/v1/rooms/:id":              {Tag: "Rooms", Summary: "Get a room", Auth: openapi.Staff, Permission: "rooms.read", Response: models.Room{}},
+	"GET /api/v1/rooms/slug/:slug":       {Tag: "Rooms", Summary: "Get a room by slug", Auth: openapi.Staff, Permission: "rooms.read", Response: models.Room{}},
+	"POST /api/v1/rooms":                 {Tag: "Rooms", Summary: "Create a room", Auth: openapi.Staff, Permission: "rooms.write", Request: models.Room{}, Response: models.Room{}, Status: 201},
+	"PUT /api/v1/rooms/:id":              {Tag: "Rooms", Summary: "Replace a room", Description: replaceDescription, Auth: openapi.Staff, Permission: "rooms.write", Request: models.Room{}, Response: models.Room{}},
+	"PATCH /api/v1/rooms/:id":            {Tag: "Rooms", Summary: "Change some fields of a room", Description: patchDescription, Auth: openapi.Staff, Permission: "rooms.write", Request: models.Room{}, MergePatch: true, Response: models.Room{}},
+	"DELETE /api/v1/rooms/:id":           {Tag: "Rooms", Summary: "Delete a room", Auth: openapi.Staff, Permission: "rooms.delete", Response: openapi.Message{}},
+	"GET /api/v1/mixes":                  {Tag: "Mixes", Summary: "List mixes", Auth: openapi.Staff, Permission: "mixes.read", Listing: mixesListing, Response: listing.Page[Mix]{}},
+	"GET /api/v1/mixes/:id":              {Tag: "Mixes", Summary: "Get a mix with its track list", Auth: openapi.Staff, Permission: "mixes.read", Response: Mix{}},
+	"GET /api/v1/mixes/slug/:slug":       {Tag: "Mixes", Summary: "Get a mix with its track list by slug", Auth: openapi.Staff, Permission: "mixes.read", Response: Mix{}},
+	"POST /api/v1/mixes":                 {Tag: "Mixes", Summary: "Create a mix", Auth: openapi.Staff, Permission: "mixes.write", Request: Mix{}, Response: Mix{}, Status: 201},
+	"PUT /api/v1/mixes/:id":              {Tag: "Mixes", Summary: "Replace a mix", Description: replaceDescription, Auth: openapi.Staff, Permission: "mixes.write", Request: Mix{}, Response: Mix{}},
+	"PATCH /api/v1/mixes/:id":            {Tag: "Mixes", Summary: "Change some fields of a mix", Description: patchDescription, Auth: openapi.Staff, Permission: "mixes.write", Request: Mix{}, MergePatch: true, Response: Mix{}},
+	"DELETE /api/v1/mixes/:id":           {Tag: "Mixes", Summary: "Delete a mix", Auth: openapi.Staff, Permission: "mixes.delete", Response: openapi.Message{}},
+	"POST /api/v1/mixes/:id/tracks":      {Tag: "Mixes", Summary: "Add a track", Auth: openapi.Staff, Permission: "mixes.write", Request: Track{}, Response: trackAddedResponse{}},
+	"POST /api/v1/mixes/:id/tracks/bulk": {Tag: "Mixes", Summary: "Add tracks from links", Auth: openapi.Staff, Permission: "mixes.write", Response: tracksAddedResponse{}, Request: AddTracksRequest{}},
+	"DELETE /api/v1/mixes/:id/tracks":    {Tag: "Mixes", Summary: "Remove a track and renumber the rest", Auth: openapi.Staff, Permission: "mixes.write", Query: []openapi.Param{{Name: "track_number", Required: true}}},
+
+	// Staff: uploads
+	"POST /api/v1/upload":          {Tag: "Uploads", Summary: "Upload an image (converted to WebP)", Auth: openapi.Staff, Permission: "uploads.write", Multipart: "image", Query: uploadQuery, Response: UploadResponse{}},
+	"POST /api/v1/upload/multiple": {Tag: "Uploads", Summary: "Upload several images", Auth: openapi.Staff, Permission: "uploads.write", Multipart: "images", MultipleFiles: true, Query: uploadQuery, Response: multipleUploadResponse{}},
+
+	// Staff: users, roles, API keys and audit
+	"GET /api/v1/users":                 {Tag: "Users", Summary: "List users", Auth: openapi.Staff, Permission: "users.read", Listing: usersListing, Response: listing.Page[User]{}},
+	"POST /api/v1/users":                {Tag: "Users", Summary: "Create a staff user", Auth: openapi.Staff, Permission: "users.write", Request: CreateUserRequest{}, Response: CreateUserResponse{}, Status: 201},
+	"GET /api/v1/users/:id":             {Tag: "Users", Summary: "Get a user", Auth: openapi.Staff, Permission: "users.read", Response: User{}},
+	"PUT /api/v1/users/:id":             {Tag: "Users", Summary: "Replace a user", Description: replaceDescription, Auth: openapi.Staff, Permission: "users.write", Request: UpdateUserRequest{}, Response: User{}},
+	"PATCH /api/v1/users/:id":           {Tag: "Users", Summary: "Change some fields of a user", Description: patchDescription, Auth: openapi.Staff, Permission: "users.write", Request: UpdateUserRequest{}, MergePatch: true, Response: User{}},
+	"DELETE /api/v1/users/:id":          {Tag: "Users", Summary: "Delete a user", Auth: openapi.Staff, Permission: "users.delete", Response: openapi.Message{}},
+	"PUT /api/v1/users/:id/role":        {Tag: "Users", Summary: "Change a user's role", Auth: openapi.Staff, Permission: "users.write", Request: UpdateUserRoleRequest{}},
+	"POST /api/v1/users/:id/unlock":     {Tag: "Users", Summary: "Clear a user's login lockouts", Auth: openapi.Staff, Permission: "users.write", Response: openapi.Message{}},
+	"DELETE /api/v1/users/:id/sessions": {Tag: "Users", Summary: "Sign a user out everywhere", Auth: openapi.Staff, Permission: "users.write", Response: openapi.Message{}},
+	"GET /api/v1/roles":                 {Tag: "Roles", Summary: "List roles", Auth: openapi.Staff, Permission: "roles.read", Response: []Role{}},
+	"POST /api/v1/roles":                {Tag: "Roles", Summary: "Create a role", Auth: openapi.Staff, Permission: "roles.write", Request: Role{}, Response: Role{}, Status: 201},
+	"GET /api/v1/roles/:id":             {Tag: "Roles", Summary: "Get a role", Auth: openapi.Staff, Permission: "roles.read", Response: Role{}},
+	"PUT /api/v1/roles/:id":             {Tag: "Roles", Summary: "Replace a role", Description: replaceDescription, Auth: openapi.Staff, Permission: "roles.write", Request: Role{}, Response: Role{}},
+	"PATCH /api/v1/roles/:id":           {Tag: "Roles", Summary: "Change some fields of a role", Description: patchDescription, Auth: openapi.Staff, Permission: "roles.write", Request: Role{}, MergePatch: true, Response: Role{}},
+	"DELETE /api/v1/roles/:id":          {Tag: "Roles", Summary: "Delete a role", Auth: openapi.Staff, Permission: "roles.delete", Response: openapi.Message{}},
+	"GET /api/v1/api-keys":              {Tag: "API Keys", Summary: "List API keys", Auth: openapi.Staff, Permission: "apikeys.read", Response: []APIKey{}},
+	"POST /api/v1/api-keys":             {Tag: "API Keys", Summary: "Create an API key (the key is only shown once)", Auth: openapi.Staff, Permission: "apikeys.write", Request: CreateAPIKeyRequest{}, Response: APIKey{}, Status: 201},
+	"DELETE /api/v1/api-keys/:id":       {Tag: "API Keys", Summary: "Revoke an API key", Auth: openapi.Staff, Permission: "apikeys.delete", Response: openapi.Message{}},
+	"GET /api/v1/audit": {Tag: "Audit", Summary: "Audit log, newest first", Auth: openapi.Staff, Permission: "audit.read", Response: auditLogResponse{}, Query: []openapi.Param{
+		{Name: "actor_id"}, {Name: "actor_type"}, {Name: "action"}, {Name: "resource_type"}, {Name: "resource_id"}, {Name: "status_code"},
+		{Name: "from", Description: "RFC3339"}, {Name: "to", Description: "RFC3339"},
+		{Name: "page"}, {Name: "limit", Description: "Default 50, max 200"},
+	}},
+}
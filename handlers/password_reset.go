@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"playtz-api/apierr"
+	"playtz-api/auth"
+	"playtz-api/database"
+	"playtz-api/mailer"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ForgotPasswordRequest represents a password reset request
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest completes a password reset
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// ForgotPassword emails a single-use reset link to the account with the given email.
+// The response is the same whether or not the account exists.
+func ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if !bindJSON(c, &req, "A valid email address is required") {
+		return
+	}
+
+	var userID, email, firstName string
+	err := database.DB.QueryRow(
+		"SELECT id, email, COALESCE(first_name, '') FROM users WHERE LOWER(email) = LOWER($1) AND active = true",
+		req.Email,
+	).Scan(&userID, &email, &firstName)
+
+	if err == nil {
+		token, err := auth.CreatePasswordResetToken(userID)
+		if err != nil {
+			apierr.Fail(c, "Failed to create reset token", err)
+			return
+		}
+
+		// Send in the background so response time does not reveal whether the account exists
+		go func() {
+			if err := mailer.Get().Send(passwordResetEmail(email, firstName, token)); err != nil {
+				log.Printf("Failed to send password reset email: %v", err)
+			}
+		}()
+	}
+
+	c.JSON(200, gin.H{"message": "If an account with that email exists, a reset link has been sent"})
+}
+
+// ResetPassword sets a new password using an emailed reset token
+func ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if !bindJSON(c, &req, "Token and new password are required") {
+		return
+	}
+
+	// Validate before consuming so a rejected password does not burn the token
+	userID, err := auth.LookupPasswordResetToken(req.Token)
+	if err == auth.ErrResetTokenInvalid {
+		apierr.BadRequest(c, "Invalid or expired reset token")
+		return
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to verify reset token", err)
+		return
+	}
+	if !checkPasswordPolicy(c, userID, req.NewPassword) {
+		return
+	}
+
+	if _, err := auth.ConsumePasswordResetToken(req.Token); err == auth.ErrResetTokenInvalid {
+		apierr.BadRequest(c, "Invalid or expired reset token")
+		return
+	} else if err != nil {
+		apierr.Fail(c, "Failed to verify reset token", err)
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		apierr.Fail(c, "Failed to hash new password", err)
+		return
+	}
+
+	if err := auth.RecordPasswordHistory(userID); err != nil {
+		apierr.Fail(c, "Failed to update password", err)
+		return
+	}
+
+	_, err = database.DB.Exec(
+		"UPDATE users SET password_hash = $1, password_change_required = false, updated_at = CURRENT_TIMESTAMP WHERE id = $2",
+		string(hashedPassword), userID,
+	)
+	if err != nil {
+		apierr.Fail(c, "Failed to update password", err)
+		return
+	}
+
+	// Whoever had access before the reset loses it
+	if err := auth.RevokeAllUserTokens(userID); err != nil {
+		apierr.Fail(c, "Password reset but failed to revoke existing sessions", err)
+		return
+	}
+
+	recordAccountEvent(c, "users.password.reset", userID)
+	c.JSON(200, gin.H{"message": "Password has been reset. Please log in with your new password."})
+}
+
+// passwordResetEmail builds the reset email for a user
+func passwordResetEmail(email, firstName, token string) mailer.Message {
+	// Link target is the admin frontend page that posts to /auth/reset-password
+	resetURL := os.Getenv("PASSWORD_RESET_URL")
+	if resetURL == "" {
+		resetURL = "https://playtzadmin.vercel.app/reset-password"
+	}
+	link := resetURL + "?token=" + url.QueryEscape(token)
+
+	greeting := "Hello,"
+	if firstName != "" {
+		greeting = "Hello " + firstName + ","
+	}
+
+	return mailer.Message{
+		To:      email,
+		Subject: "Reset your Playtz 102.9 password",
+		Body: fmt.Sprintf(
+			"%s\n\nWe received a request to reset your password. Use the link below within %d minutes:\n\n%s\n\nIf you did not request this, you can ignore this email.\n",
+			greeting, int(auth.PasswordResetTTL.Minutes()), link,
+		),
+	}
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"encoding/json"
+	"playtz-api/apierr"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PUT replaces a resource and PATCH changes part of it:
+//
+//	PUT    every member the resource stores must be sent; an empty value clears one
+//	PATCH  a JSON merge patch (RFC 7396): only the members sent change, null resets one
+//
+// Both check the resulting resource against the same binding rules as a create.
+
+// replaceJSON binds a PUT body like bindJSON and also requires every member in fields, so
+// leaving one out cannot silently reset it. Missing members are reported with the other
+// violations as "required".
+func replaceJSON(c *gin.Context, req interface{}, message string, fields ...string) bool {
+	body, err := readBody(c)
+	var members map[string]json.RawMessage
+	if err == nil {
+		err = json.Unmarshal(body, &members)
+	}
+	if err != nil {
+		apierr.BadRequest(c, message)
+		return false
+	}
+
+	e := apierr.New(400, apierr.CodeValidationFailed, message)
+	missing := map[string]bool{}
+	for _, field := range fields {
+		if _, ok := members[field]; !ok {
+			missing[field] = true
+			e.WithDetails(apierr.FieldError{Field: field, Code: "required", Message: "is required; use PATCH to change only some fields"})
+		}
+	}
+	if err := decodeJSON(body, req); err != nil {
+		bindErr := apierr.FromBind(message, err)
+		if bindErr.Code != apierr.CodeValidationFailed {
+			apierr.Respond(c, bindErr)
+			return false
+		}
+		for _, detail := range bindErr.Details {
+			if !missing[detail.Field] {
+				e.WithDetails(detail)
+			}
+		}
+	}
+	if len(e.Details) > 0 {
+		apierr.Respond(c, e)
+		return false
+	}
+	return true
+}
+
+// patchJSON applies the request body, a JSON merge patch, to req, which holds the resource
+// as it is now, and checks the result like bindJSON. A member set to null ends up empty:
+// "", 0, false or no items.
+func patchJSON(c *gin.Context, req interface{}, message string) bool {
+	body, err := readBody(c)
+	var patch interface{}
+	if err == nil {
+		err = json.Unmarshal(body, &patch)
+	}
+	if err != nil {
+		apierr.BadRequest(c, message)
+		return false
+	}
+	if _, ok := patch.(map[string]interface{}); !ok {
+		apierr.BadRequest(c, "A merge patch must be a JSON object")
+		return false
+	}
+
+	current, err := json.Marshal(req)
+	if err != nil {
+		apierr.Fail(c, message, err)
+		return false
+	}
+	var target interface{}
+	if err := json.Unmarshal(current, &target); err != nil {
+		apierr.Fail(c, message, err)
+		return false
+	}
+	merged, err := json.Marshal(mergePatch(target, patch))
+	if err != nil {
+		apierr.Fail(c, message, err)
+		return false
+	}
+
+	// Decode into an empty value, so members the patch removed do not keep their old value
+	value := reflect.ValueOf(req).Elem()
+	value.Set(reflect.Zero(value.Type()))
+	if err := decodeJSON(merged, req); err != nil {
+		apierr.Bind(c, err, message)
+		return false
+	}
+	return true
+}
+
+// mergePatch applies patch to target as RFC 7396 describes: objects are merged member by
+// member, null removes a member and any other value replaces the target's.
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+		} else {
+			targetObject[name] = mergePatch(targetObject[name], value)
+		}
+	}
+	return targetObject
+}
@@ -0,0 +1,451 @@
+package handlers
+
+import (
+	"database/sql"
+	"playtz-api/apierr"
+	"playtz-api/database"
+	"playtz-api/slugs"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// Public endpoints serve the station website and apps without authentication.
+// They only return published or active content and leave out internal fields
+// (visibility flags, stock levels, edit timestamps).
+
+// PublicNewsArticle is a published news article
+type PublicNewsArticle struct {
+	ID          string `json:"id"`
+	Slug        string `json:"slug"`
+	Title       string `json:"title"`
+	Content     string `json:"content"`
+	Excerpt     string `json:"excerpt"`
+	Author      string `json:"author,omitempty"`
+	Image       string `json:"image,omitempty"`
+	PublishedAt string `json:"published_at"`
+}
+
+// PublicEvent is an active event
+type PublicEvent struct {
+	ID          string `json:"id"`
+	Slug        string `json:"slug"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Date        string `json:"date,omitempty"`
+	Time        string `json:"time,omitempty"`
+	Location    string `json:"location,omitempty"`
+	Image       string `json:"image,omitempty"`
+}
+
+// PublicRoom is an active room
+type PublicRoom struct {
+	ID          string `json:"id"`
+	Slug        string `json:"slug"`
+	Name        string `json:"name"`
+	Genre       string `json:"genre,omitempty"`
+	Description string `json:"description,omitempty"`
+	Gradient    string `json:"gradient,omitempty"`
+	TextColor   string `json:"text_color,omitempty"`
+	Image       string `json:"image,omitempty"`
+}
+
+// PublicMix is an active mix in an active room, with its track list
+type PublicMix struct {
+	ID          string  `json:"id"`
+	Slug        string  `json:"slug"`
+	RoomID      string  `json:"room_id"`
+	Title       string  `json:"title"`
+	Artist      string  `json:"artist,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Duration    string  `json:"duration,omitempty"`
+	Color       string  `json:"color,omitempty"`
+	TextColor   string  `json:"text_color,omitempty"`
+	BorderColor string  `json:"border_color,omitempty"`
+	Image       string  `json:"image,omitempty"`
+	AudioURL    string  `json:"audio_url,omitempty"`
+	Tracks      int     `json:"tracks"`
+	TrackList   []Track `json:"track_list"`
+}
+
+// PublicMerchandise is an active merchandise item that is in stock
+type PublicMerchandise struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	Price       float64 `json:"price"`
+	Image       string  `json:"image,omitempty"`
+}
+
+// PublicCareer is an open position
+type PublicCareer struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Department  string `json:"department,omitempty"`
+	Location    string `json:"location,omitempty"`
+	Type        string `json:"type,omitempty"`
+}
+
+const publicNewsColumns = "id, COALESCE(slug, ''), title, COALESCE(content, ''), COALESCE(author, ''), COALESCE(image, ''), created_at"
+
+// GetPublicNews returns published news articles, newest first
+func GetPublicNews(c *gin.Context) {
+	rows, err := database.DB.Query("SELECT " + publicNewsColumns + " FROM news WHERE published = true ORDER BY created_at DESC")
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch news", err)
+		return
+	}
+	defer rows.Close()
+
+	articles := []PublicNewsArticle{}
+	for rows.Next() {
+		article, err := scanPublicNews(rows)
+		if err != nil {
+			continue
+		}
+		articles = append(articles, article)
+	}
+
+	c.JSON(200, articles)
+}
+
+// GetPublicNewsByID returns a published news article
+func GetPublicNewsByID(c *gin.Context) {
+	article, err := scanPublicNews(database.DB.QueryRow(
+		"SELECT "+publicNewsColumns+" FROM news WHERE id = $1 AND "+publicNewsVisible,
+		c.Param("id"),
+	))
+	if err == sql.ErrNoRows {
+		apierr.NotFound(c, "News article not found")
+		return
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch news article", err)
+		return
+	}
+
+	c.JSON(200, article)
+}
+
+// GetPublicNewsBySlug returns a published news article by its slug, redirecting former slugs
+func GetPublicNewsBySlug(c *gin.Context) {
+	serveBySlug(c, slugs.News, publicNewsVisible, "News article not found", GetPublicNewsByID)
+}
+
+func scanPublicNews(row interface{ Scan(...interface{}) error }) (PublicNewsArticle, error) {
+	var article PublicNewsArticle
+	var createdAt time.Time
+	if err := row.Scan(&article.ID, &article.Slug, &article.Title, &article.Content, &article.Author, &article.Image, &createdAt); err != nil {
+		return article, err
+	}
+	article.PublishedAt = createdAt.Format(time.RFC3339)
+	article.Excerpt = article.Content
+	if len(article.Content) > 200 {
+		article.Excerpt = article.Content[:200] + "..."
+	}
+	return article, nil
+}
+
+const publicEventColumns = "id, COALESCE(slug, ''), title, COALESCE(description, ''), date, COALESCE(time::text, ''), COALESCE(location, ''), COALESCE(image, '')"
+
+// GetPublicEvents returns active events, soonest first
+func GetPublicEvents(c *gin.Context) {
+	rows, err := database.DB.Query("SELECT " + publicEventColumns + " FROM events WHERE active = true ORDER BY date, time")
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch events", err)
+		return
+	}
+	defer rows.Close()
+
+	events := []PublicEvent{}
+	for rows.Next() {
+		event, err := scanPublicEvent(rows)
+		if err != nil {
+			continue
+		}
+		events = append(events, event)
+	}
+
+	c.JSON(200, events)
+}
+
+// GetPublicEventByID returns an active event
+func GetPublicEventByID(c *gin.Context) {
+	event, err := scanPublicEvent(database.DB.QueryRow(
+		"SELECT "+publicEventColumns+" FROM events WHERE id = $1 AND "+publicEventVisible,
+		c.Param("id"),
+	))
+	if err == sql.ErrNoRows {
+		apierr.NotFound(c, "Event not found")
+		return
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch event", err)
+		return
+	}
+
+	c.JSON(200, event)
+}
+
+// GetPublicEventBySlug returns an active event by its slug, redirecting former slugs
+func GetPublicEventBySlug(c *gin.Context) {
+	serveBySlug(c, slugs.Events, publicEventVisible, "Event not found", GetPublicEventByID)
+}
+
+func scanPublicEvent(row interface{ Scan(...interface{}) error }) (PublicEvent, error) {
+	var event PublicEvent
+	var date sql.NullTime
+	if err := row.Scan(&event.ID, &event.Slug, &event.Title, &event.Description, &date, &event.Time, &event.Location, &event.Image); err != nil {
+		return event, err
+	}
+	if date.Valid {
+		event.Date = date.Time.Format("2006-01-02")
+	}
+	return event, nil
+}
+
+const publicRoomColumns = "id, COALESCE(slug, ''), name, COALESCE(genre, ''), COALESCE(description, ''), COALESCE(gradient, ''), COALESCE(text_color, ''), COALESCE(image, '')"
+
+// GetPublicRooms returns active rooms
+func GetPublicRooms(c *gin.Context) {
+	rows, err := database.DB.Query("SELECT " + publicRoomColumns + " FROM rooms WHERE active = true ORDER BY name")
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch rooms", err)
+		return
+	}
+	defer rows.Close()
+
+	rooms := []PublicRoom{}
+	for rows.Next() {
+		var room PublicRoom
+		if err := rows.Scan(&room.ID, &room.Slug, &room.Name, &room.Genre, &room.Description, &room.Gradient, &room.TextColor, &room.Image); err != nil {
+			continue
+		}
+		rooms = append(rooms, room)
+	}
+
+	c.JSON(200, rooms)
+}
+
+// GetPublicRoomByID returns an active room with its active mixes
+func GetPublicRoomByID(c *gin.Context) {
+	var room PublicRoom
+	err := database.DB.QueryRow(
+		"SELECT "+publicRoomColumns+" FROM rooms WHERE id = $1 AND "+publicRoomVisible,
+		c.Param("id"),
+	).Scan(&room.ID, &room.Slug, &room.Name, &room.Genre, &room.Description, &room.Gradient, &room.TextColor, &room.Image)
+	if err == sql.ErrNoRows {
+		apierr.NotFound(c, "Room not found")
+		return
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch room", err)
+		return
+	}
+
+	mixes, err := publicMixes("m.room_id = $1", room.ID)
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch mixes", err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"room":  room,
+		"mixes": mixes,
+	})
+}
+
+// GetPublicRoomBySlug returns an active room with its active mixes by its slug, redirecting former slugs
+func GetPublicRoomBySlug(c *gin.Context) {
+	serveBySlug(c, slugs.Rooms, publicRoomVisible, "Room not found", GetPublicRoomByID)
+}
+
+// GetPublicMixes returns active mixes of active rooms, optionally filtered by room_id
+func GetPublicMixes(c *gin.Context) {
+	var mixes []PublicMix
+	var err error
+	if roomID := c.Query("room_id"); roomID != "" {
+		mixes, err = publicMixes("m.room_id = $1", roomID)
+	} else {
+		mixes, err = publicMixes("true")
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch mixes", err)
+		return
+	}
+
+	c.JSON(200, mixes)
+}
+
+// GetPublicMixByID returns an active mix of an active room
+func GetPublicMixByID(c *gin.Context) {
+	mixes, err := publicMixes("m.id = $1", c.Param("id"))
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch mix", err)
+		return
+	}
+	if len(mixes) == 0 {
+		apierr.NotFound(c, "Mix not found")
+		return
+	}
+
+	c.JSON(200, mixes[0])
+}
+
+// GetPublicMixBySlug returns an active mix of an active room by its slug, redirecting former slugs
+func GetPublicMixBySlug(c *gin.Context) {
+	serveBySlug(c, slugs.Mixes, publicMixVisible, "Mix not found", GetPublicMixByID)
+}
+
+// publicMixes loads the visible mixes matching condition, each with its track list
+func publicMixes(condition string, args ...interface{}) ([]PublicMix, error) {
+	rows, err := database.DB.Query(`
+		SELECT m.id, COALESCE(m.slug, ''), m.room_id, m.title, COALESCE(m.artist, ''), COALESCE(m.description, ''), COALESCE(m.duration, ''),
+		       COALESCE(m.color, ''), COALESCE(m.text_color, ''), COALESCE(m.border_color, ''),
+		       COALESCE(m.image, ''), COALESCE(m.audio_url, '')
+		FROM mixes m
+		JOIN rooms r ON r.id = m.room_id
+		WHERE m.active = true AND r.active = true AND `+condition+`
+		ORDER BY m.created_at DESC
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mixes := []PublicMix{}
+	mixIDs := []string{}
+	for rows.Next() {
+		var mix PublicMix
+		err := rows.Scan(&mix.ID, &mix.Slug, &mix.RoomID, &mix.Title, &mix.Artist, &mix.Description, &mix.Duration,
+			&mix.Color, &mix.TextColor, &mix.BorderColor, &mix.Image, &mix.AudioURL)
+		if err != nil {
+			continue
+		}
+		mix.TrackList = []Track{}
+		mixes = append(mixes, mix)
+		mixIDs = append(mixIDs, mix.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(mixes) == 0 {
+		return mixes, nil
+	}
+
+	// One query for every track list
+	trackRows, err := database.DB.Query(`
+		SELECT mix_id, number, COALESCE(title, ''), COALESCE(artist, ''), COALESCE(duration, ''), link, COALESCE(type, 'audio')
+		FROM tracks
+		WHERE mix_id = ANY($1)
+		ORDER BY number
+	`, pq.Array(mixIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer trackRows.Close()
+
+	tracks := map[string][]Track{}
+	for trackRows.Next() {
+		var mixID string
+		var track Track
+		if err := trackRows.Scan(&mixID, &track.Number, &track.Title, &track.Artist, &track.Duration, &track.Link, &track.Type); err != nil {
+			continue
+		}
+		tracks[mixID] = append(tracks[mixID], track)
+	}
+
+	for i := range mixes {
+		if list, ok := tracks[mixes[i].ID]; ok {
+			mixes[i].TrackList = list
+		}
+		mixes[i].Tracks = len(mixes[i].TrackList)
+	}
+	return mixes, nil
+}
+
+const publicMerchColumns = "id, name, COALESCE(description, ''), price, COALESCE(image, '')"
+
+// GetPublicMerch returns active merchandise that is in stock
+func GetPublicMerch(c *gin.Context) {
+	rows, err := database.DB.Query("SELECT " + publicMerchColumns + " FROM merchandise WHERE active = true AND stock > 0 ORDER BY created_at DESC")
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch merchandise", err)
+		return
+	}
+	defer rows.Close()
+
+	items := []PublicMerchandise{}
+	for rows.Next() {
+		var item PublicMerchandise
+		if err := rows.Scan(&item.ID, &item.Name, &item.Description, &item.Price, &item.Image); err != nil {
+			continue
+		}
+		items = append(items, item)
+	}
+
+	c.JSON(200, items)
+}
+
+// GetPublicMerchByID returns an active merchandise item that is in stock
+func GetPublicMerchByID(c *gin.Context) {
+	var item PublicMerchandise
+	err := database.DB.QueryRow(
+		"SELECT "+publicMerchColumns+" FROM merchandise WHERE id = $1 AND active = true AND stock > 0",
+		c.Param("id"),
+	).Scan(&item.ID, &item.Name, &item.Description, &item.Price, &item.Image)
+	if err == sql.ErrNoRows {
+		apierr.NotFound(c, "Merchandise not found")
+		return
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch merchandise", err)
+		return
+	}
+
+	c.JSON(200, item)
+}
+
+const publicCareerColumns = "id, title, COALESCE(description, ''), COALESCE(department, ''), COALESCE(location, ''), COALESCE(type, '')"
+
+// GetPublicCareers returns open positions
+func GetPublicCareers(c *gin.Context) {
+	rows, err := database.DB.Query("SELECT " + publicCareerColumns + " FROM careers WHERE active = true ORDER BY created_at DESC")
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch careers", err)
+		return
+	}
+	defer rows.Close()
+
+	careers := []PublicCareer{}
+	for rows.Next() {
+		var career PublicCareer
+		if err := rows.Scan(&career.ID, &career.Title, &career.Description, &career.Department, &career.Location, &career.Type); err != nil {
+			continue
+		}
+		careers = append(careers, career)
+	}
+
+	c.JSON(200, careers)
+}
+
+// GetPublicCareerByID returns an open position
+func GetPublicCareerByID(c *gin.Context) {
+	var career PublicCareer
+	err := database.DB.QueryRow(
+		"SELECT "+publicCareerColumns+" FROM careers WHERE id = $1 AND active = true",
+		c.Param("id"),
+	).Scan(&career.ID, &career.Title, &career.Description, &career.Department, &career.Location, &career.Type)
+	if err == sql.ErrNoRows {
+		apierr.NotFound(c, "Career not found")
+		return
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch career", err)
+		return
+	}
+
+	c.JSON(200, career)
+}
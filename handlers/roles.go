@@ -2,7 +2,12 @@ package handlers
 
 import (
 	"database/sql"
+	"playtz-api/apierr"
+	"playtz-api/auth"
 	"playtz-api/database"
+	"playtz-api/middleware"
+	"playtz-api/models"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -13,19 +18,21 @@ import (
 // Role represents a user role
 type Role struct {
 	ID          string   `json:"id"`
-	Name        string   `json:"name"`
+	Name        string   `json:"name" binding:"required,notblank,max=100"`
 	Description string   `json:"description"`
-	Permissions []string `json:"permissions"`
+	Permissions []string `json:"permissions" binding:"dive,notblank"`
 	Active      bool     `json:"active"`
-	CreatedAt   string   `json:"created_at,omitempty"`
-	UpdatedAt   string   `json:"updated_at,omitempty"`
+	// RequireTwoFactor makes TOTP mandatory for every user holding the role
+	RequireTwoFactor bool   `json:"require_2fa"`
+	CreatedAt        string `json:"created_at,omitempty"`
+	UpdatedAt        string `json:"updated_at,omitempty"`
 }
 
 // GetRoles returns all roles
 func GetRoles(c *gin.Context) {
-	rows, err := database.DB.Query("SELECT id, name, description, permissions, active, created_at, updated_at FROM roles ORDER BY name")
+	rows, err := database.DB.Query("SELECT id, name, description, permissions, active, COALESCE(require_2fa, false), created_at, updated_at FROM roles ORDER BY name")
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to fetch roles"})
+		apierr.Fail(c, "Failed to fetch roles", err)
 		return
 	}
 	defer rows.Close()
@@ -35,7 +42,7 @@ func GetRoles(c *gin.Context) {
 		var role Role
 		var createdAt, updatedAt time.Time
 		var permissions pq.StringArray
-		err := rows.Scan(&role.ID, &role.Name, &role.Description, &permissions, &role.Active, &createdAt, &updatedAt)
+		err := rows.Scan(&role.ID, &role.Name, &role.Description, &permissions, &role.Active, &role.RequireTwoFactor, &createdAt, &updatedAt)
 		if err != nil {
 			continue
 		}
@@ -48,39 +55,55 @@ func GetRoles(c *gin.Context) {
 	c.JSON(200, roles)
 }
 
+// roleFields are the members a PUT of a role must send
+var roleFields = []string{"name", "description", "permissions", "active", "require_2fa"}
+
 // GetRoleByID returns a specific role
 func GetRoleByID(c *gin.Context) {
-	id := c.Param("id")
+	role, err := loadRole(c.Param("id"))
+	if err == sql.ErrNoRows {
+		apierr.NotFound(c, "Role not found")
+		return
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch role", err)
+		return
+	}
 
+	c.JSON(200, role)
+}
+
+// loadRole fetches a role by ID
+func loadRole(id string) (*Role, error) {
 	var role Role
 	var createdAt, updatedAt time.Time
 	var permissions pq.StringArray
 	err := database.DB.QueryRow(
-		"SELECT id, name, description, permissions, active, created_at, updated_at FROM roles WHERE id = $1",
+		"SELECT id, name, description, permissions, active, COALESCE(require_2fa, false), created_at, updated_at FROM roles WHERE id = $1",
 		id,
-	).Scan(&role.ID, &role.Name, &role.Description, &permissions, &role.Active, &createdAt, &updatedAt)
-
-	if err == sql.ErrNoRows {
-		c.JSON(404, gin.H{"error": "Role not found"})
-		return
-	}
+	).Scan(&role.ID, &role.Name, &role.Description, &permissions, &role.Active, &role.RequireTwoFactor, &createdAt, &updatedAt)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to fetch role"})
-		return
+		return nil, err
 	}
 
 	role.Permissions = []string(permissions)
 	role.CreatedAt = createdAt.Format(time.RFC3339)
 	role.UpdatedAt = updatedAt.Format(time.RFC3339)
-
-	c.JSON(200, role)
+	return &role, nil
 }
 
 // CreateRole creates a new role
 func CreateRole(c *gin.Context) {
 	var role Role
-	if err := c.ShouldBindJSON(&role); err != nil {
-		c.JSON(400, gin.H{"error": "Invalid request body"})
+	if !bindJSON(c, &role, "Invalid request body") {
+		return
+	}
+
+	if unknown := unknownPermissions(role.Permissions); len(unknown) > 0 {
+		apierr.BadRequest(c, "Unknown permissions: "+strings.Join(unknown, ", "))
+		return
+	}
+	if !requireGrantable(c, role.Permissions) {
 		return
 	}
 
@@ -88,12 +111,12 @@ func CreateRole(c *gin.Context) {
 	role.Active = true
 
 	_, err := database.DB.Exec(
-		"INSERT INTO roles (id, name, description, permissions, active) VALUES ($1, $2, $3, $4, $5)",
-		role.ID, role.Name, role.Description, pq.Array(role.Permissions), role.Active,
+		"INSERT INTO roles (id, name, description, permissions, active, require_2fa) VALUES ($1, $2, $3, $4, $5, $6)",
+		role.ID, role.Name, role.Description, pq.Array(role.Permissions), role.Active, role.RequireTwoFactor,
 	)
 
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to create role: " + err.Error()})
+		apierr.Fail(c, "Failed to create role", err)
 		return
 	}
 
@@ -111,28 +134,61 @@ func CreateRole(c *gin.Context) {
 	c.JSON(201, role)
 }
 
-// UpdateRole updates an existing role
+// UpdateRole replaces a role
 func UpdateRole(c *gin.Context) {
-	id := c.Param("id")
-
 	var role Role
-	if err := c.ShouldBindJSON(&role); err != nil {
-		c.JSON(400, gin.H{"error": "Invalid request body"})
+	if !replaceJSON(c, &role, "Invalid request body", roleFields...) {
+		return
+	}
+
+	saveRole(c, c.Param("id"), &role)
+}
+
+// PatchRole changes the members of a role sent in a JSON merge patch. Permissions are
+// replaced as a whole, as merge patches replace arrays.
+func PatchRole(c *gin.Context) {
+	role, err := loadRole(c.Param("id"))
+	if err == sql.ErrNoRows {
+		apierr.NotFound(c, "Role not found")
+		return
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch role", err)
+		return
+	}
+
+	if !patchJSON(c, role, "Invalid request body") {
+		return
+	}
+
+	saveRole(c, c.Param("id"), role)
+}
+
+// saveRole writes role over the role with the ID and answers with the result
+func saveRole(c *gin.Context, id string, role *Role) {
+	if unknown := unknownPermissions(role.Permissions); len(unknown) > 0 {
+		apierr.BadRequest(c, "Unknown permissions: "+strings.Join(unknown, ", "))
+		return
+	}
+	if !requireGrantable(c, role.Permissions) {
 		return
 	}
 
 	role.ID = id
 
 	_, err := database.DB.Exec(
-		"UPDATE roles SET name = $1, description = $2, permissions = $3, active = $4, updated_at = CURRENT_TIMESTAMP WHERE id = $5",
-		role.Name, role.Description, pq.Array(role.Permissions), role.Active, id,
+		"UPDATE roles SET name = $1, description = $2, permissions = $3, active = $4, require_2fa = $5, updated_at = CURRENT_TIMESTAMP WHERE id = $6",
+		role.Name, role.Description, pq.Array(role.Permissions), role.Active, role.RequireTwoFactor, id,
 	)
 
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to update role: " + err.Error()})
+		apierr.Fail(c, "Failed to update role", err)
 		return
 	}
 
+	// Every user holding this role may now have different permissions
+	auth.GetPermissionCache().InvalidateAll()
+
 	var createdAt, updatedAt time.Time
 	var permissions pq.StringArray
 	err = database.DB.QueryRow(
@@ -141,11 +197,11 @@ func UpdateRole(c *gin.Context) {
 	).Scan(&permissions, &createdAt, &updatedAt)
 
 	if err == sql.ErrNoRows {
-		c.JSON(404, gin.H{"error": "Role not found"})
+		apierr.NotFound(c, "Role not found")
 		return
 	}
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to fetch updated role"})
+		apierr.Fail(c, "Failed to fetch updated role", err)
 		return
 	}
 
@@ -164,21 +220,71 @@ func DeleteRole(c *gin.Context) {
 	var userCount int
 	err := database.DB.QueryRow("SELECT COUNT(*) FROM users WHERE role_id = $1", id).Scan(&userCount)
 	if err == nil && userCount > 0 {
-		c.JSON(400, gin.H{"error": "Cannot delete role: it is assigned to users"})
+		apierr.BadRequest(c, "Cannot delete role: it is assigned to users")
 		return
 	}
 
 	result, err := database.DB.Exec("DELETE FROM roles WHERE id = $1", id)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to delete role: " + err.Error()})
+		apierr.Fail(c, "Failed to delete role", err)
 		return
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		c.JSON(404, gin.H{"error": "Role not found"})
+		apierr.NotFound(c, "Role not found")
 		return
 	}
 
+	auth.GetPermissionCache().InvalidateAll()
+
 	c.JSON(200, gin.H{"message": "Role deleted successfully"})
 }
+
+// unknownPermissions returns the permissions that are not in the catalogue
+func unknownPermissions(permissions []string) []string {
+	var unknown []string
+	for _, permission := range permissions {
+		if !models.IsValidPermission(permission) {
+			unknown = append(unknown, permission)
+		}
+	}
+	return unknown
+}
+
+// requireGrantable answers 403 and returns false unless the caller holds every one of
+// permissions. Like an API key, a role or a user's role can never give more than the
+// caller who sets it up has, so users.write or roles.write cannot be turned into admin.
+func requireGrantable(c *gin.Context, permissions []string) bool {
+	held, err := middleware.CallerPermissions(c)
+	if err != nil {
+		apierr.Fail(c, "Failed to load permissions", err)
+		return false
+	}
+	var notHeld []string
+	for _, permission := range permissions {
+		if !held[permission] {
+			notHeld = append(notHeld, permission)
+		}
+	}
+	if len(notHeld) > 0 {
+		apierr.Forbidden(c, "Cannot grant permissions you do not have: "+strings.Join(notHeld, ", "))
+		return false
+	}
+	return true
+}
+
+// requireAssignableRole answers 403 and returns false unless the caller holds every
+// permission of the role
+func requireAssignableRole(c *gin.Context, roleID string) bool {
+	var permissions pq.StringArray
+	err := database.DB.QueryRow("SELECT permissions FROM roles WHERE id = $1", roleID).Scan(&permissions)
+	if err == sql.ErrNoRows {
+		return true // Reported by the foreign key when the user is written
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch role", err)
+		return false
+	}
+	return requireGrantable(c, permissions)
+}
@@ -2,8 +2,10 @@ package handlers
 
 import (
 	"database/sql"
+	"playtz-api/apierr"
 	"playtz-api/database"
 	"playtz-api/models"
+	"playtz-api/slugs"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,9 +14,9 @@ import (
 
 // GetRooms returns all rooms
 func GetRooms(c *gin.Context) {
-	rows, err := database.DB.Query("SELECT id, name, genre, description, gradient, text_color, image, active, created_at, updated_at FROM rooms ORDER BY name")
+	rows, err := database.DB.Query("SELECT id, COALESCE(slug, ''), name, genre, description, gradient, text_color, image, active, created_at, updated_at FROM rooms ORDER BY name")
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to fetch rooms"})
+		apierr.Fail(c, "Failed to fetch rooms", err)
 		return
 	}
 	defer rows.Close()
@@ -23,7 +25,7 @@ func GetRooms(c *gin.Context) {
 	for rows.Next() {
 		var room models.Room
 		var createdAt, updatedAt time.Time
-		err := rows.Scan(&room.ID, &room.Name, &room.Genre, &room.Description, &room.Gradient, &room.TextColor, &room.Image, &room.Active, &createdAt, &updatedAt)
+		err := rows.Scan(&room.ID, &room.Slug, &room.Name, &room.Genre, &room.Description, &room.Gradient, &room.TextColor, &room.Image, &room.Active, &createdAt, &updatedAt)
 		if err != nil {
 			continue
 		}
@@ -35,50 +37,70 @@ func GetRooms(c *gin.Context) {
 	c.JSON(200, rooms)
 }
 
+// roomFields are the members a PUT of a room must send
+var roomFields = []string{"name", "genre", "description", "gradient", "text_color", "image", "active"}
+
 // GetRoomByID returns a specific room
 func GetRoomByID(c *gin.Context) {
-	id := c.Param("id")
+	room, err := loadRoom(c.Param("id"))
+	if err == sql.ErrNoRows {
+		apierr.NotFound(c, "Room not found")
+		return
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch room", err)
+		return
+	}
+
+	c.JSON(200, room)
+}
 
+// loadRoom fetches a room by ID
+func loadRoom(id string) (*models.Room, error) {
 	var room models.Room
 	var createdAt, updatedAt time.Time
 	err := database.DB.QueryRow(
-		"SELECT id, name, genre, description, gradient, text_color, image, active, created_at, updated_at FROM rooms WHERE id = $1",
+		"SELECT id, COALESCE(slug, ''), name, genre, description, gradient, text_color, image, active, created_at, updated_at FROM rooms WHERE id = $1",
 		id,
-	).Scan(&room.ID, &room.Name, &room.Genre, &room.Description, &room.Gradient, &room.TextColor, &room.Image, &room.Active, &createdAt, &updatedAt)
-
-	if err == sql.ErrNoRows {
-		c.JSON(404, gin.H{"error": "Room not found"})
-		return
-	}
+	).Scan(&room.ID, &room.Slug, &room.Name, &room.Genre, &room.Description, &room.Gradient, &room.TextColor, &room.Image, &room.Active, &createdAt, &updatedAt)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to fetch room"})
-		return
+		return nil, err
 	}
 
 	room.CreatedAt = createdAt.Format(time.RFC3339)
 	room.UpdatedAt = updatedAt.Format(time.RFC3339)
+	return &room, nil
+}
 
-	c.JSON(200, room)
+// GetRoomBySlug returns a room by its slug, redirecting former slugs
+func GetRoomBySlug(c *gin.Context) {
+	serveBySlug(c, slugs.Rooms, "true", "Room not found", GetRoomByID)
 }
 
 // CreateRoom creates a new room
 func CreateRoom(c *gin.Context) {
 	var room models.Room
-	if err := c.ShouldBindJSON(&room); err != nil {
-		c.JSON(400, gin.H{"error": "Invalid request body"})
+	if !bindJSON(c, &room, "Invalid request body") {
 		return
 	}
 
 	room.ID = uuid.New().String()
 	room.Active = true
 
-	_, err := database.DB.Exec(
-		"INSERT INTO rooms (id, name, genre, description, gradient, text_color, image, active) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
-		room.ID, room.Name, room.Genre, room.Description, room.Gradient, room.TextColor, room.Image, room.Active,
+	slug, err := slugs.Assign(slugs.Rooms, room.ID, room.Slug, room.Name)
+	if err != nil {
+		respondSlugError(c, err)
+		return
+	}
+	room.Slug = slug
+
+	_, err = database.DB.Exec(
+		"INSERT INTO rooms (id, slug, name, genre, description, gradient, text_color, image, active) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)",
+		room.ID, room.Slug, room.Name, room.Genre, room.Description, room.Gradient, room.TextColor, room.Image, room.Active,
 	)
 
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to create room: " + err.Error()})
+		apierr.Fail(c, "Failed to create room", err)
 		return
 	}
 
@@ -94,25 +116,70 @@ func CreateRoom(c *gin.Context) {
 	c.JSON(201, room)
 }
 
-// UpdateRoom updates an existing room
+// UpdateRoom replaces a room
 func UpdateRoom(c *gin.Context) {
-	id := c.Param("id")
-
 	var room models.Room
-	if err := c.ShouldBindJSON(&room); err != nil {
-		c.JSON(400, gin.H{"error": "Invalid request body"})
+	if !replaceJSON(c, &room, "Invalid request body", roomFields...) {
 		return
 	}
 
+	saveRoom(c, c.Param("id"), &room)
+}
+
+// PatchRoom changes the members of a room sent in a JSON merge patch
+func PatchRoom(c *gin.Context) {
+	room, err := loadRoom(c.Param("id"))
+	if err == sql.ErrNoRows {
+		apierr.NotFound(c, "Room not found")
+		return
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch room", err)
+		return
+	}
+
+	if !patchJSON(c, room, "Invalid request body") {
+		return
+	}
+
+	saveRoom(c, c.Param("id"), room)
+}
+
+// saveRoom writes room over the room with the ID and answers with the result
+func saveRoom(c *gin.Context, id string, room *models.Room) {
 	room.ID = id
 
-	_, err := database.DB.Exec(
+	// The slug and the other fields change together or not at all
+	tx, err := database.DB.Begin()
+	if err != nil {
+		apierr.Fail(c, "Failed to update room", err)
+		return
+	}
+	defer tx.Rollback()
+
+	// An empty slug keeps the current one; a new one leaves the old as a redirect
+	slug, err := slugs.Change(tx, slugs.Rooms, id, room.Slug)
+	if err == sql.ErrNoRows {
+		apierr.NotFound(c, "Room not found")
+		return
+	}
+	if err != nil {
+		respondSlugError(c, err)
+		return
+	}
+	room.Slug = slug
+
+	_, err = tx.Exec(
 		"UPDATE rooms SET name = $1, genre = $2, description = $3, gradient = $4, text_color = $5, image = $6, active = $7, updated_at = CURRENT_TIMESTAMP WHERE id = $8",
 		room.Name, room.Genre, room.Description, room.Gradient, room.TextColor, room.Image, room.Active, id,
 	)
 
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to update room: " + err.Error()})
+		apierr.Fail(c, "Failed to update room", err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		apierr.Fail(c, "Failed to update room", err)
 		return
 	}
 
@@ -123,11 +190,11 @@ func UpdateRoom(c *gin.Context) {
 	).Scan(&createdAt, &updatedAt)
 
 	if err == sql.ErrNoRows {
-		c.JSON(404, gin.H{"error": "Room not found"})
+		apierr.NotFound(c, "Room not found")
 		return
 	}
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to fetch updated room"})
+		apierr.Fail(c, "Failed to fetch updated room", err)
 		return
 	}
 
@@ -143,15 +210,16 @@ func DeleteRoom(c *gin.Context) {
 
 	result, err := database.DB.Exec("DELETE FROM rooms WHERE id = $1", id)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to delete room: " + err.Error()})
+		apierr.Fail(c, "Failed to delete room", err)
 		return
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		c.JSON(404, gin.H{"error": "Room not found"})
+		apierr.NotFound(c, "Room not found")
 		return
 	}
+	slugs.Forget(slugs.Rooms, id)
 
 	c.JSON(200, gin.H{"message": "Room deleted successfully"})
 }
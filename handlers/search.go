@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"html"
+	"playtz-api/apierr"
+	"playtz-api/database"
+	"playtz-api/listing"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// searchTypes are the resource types indexed in search_documents
+var searchTypes = []string{"news", "events", "mixes", "tracks", "merch", "careers"}
+
+// Snippet highlight markers: ts_headline wraps matches in these, then the snippet is
+// HTML-escaped and the markers become <mark> tags, so content cannot inject markup
+const (
+	snippetStart = "\x01"
+	snippetStop  = "\x02"
+)
+
+// SearchResult is one ranked match
+type SearchResult struct {
+	Type     string  `json:"type"`
+	ID       string  `json:"id"`
+	ParentID string  `json:"parent_id,omitempty"` // Room of a mix, mix of a track
+	Title    string  `json:"title"`
+	Subtitle string  `json:"subtitle,omitempty"`
+	Snippet  string  `json:"snippet"` // HTML with matches wrapped in <mark>
+	Rank     float64 `json:"rank"`
+}
+
+// Search finds published and active content matching ?q across news, events, mixes, tracks,
+// merchandise and careers. ?type limits the resource types (comma separated).
+// Pagination: page (default 1), limit (default 20, max 50).
+func Search(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if len([]rune(query)) < 2 {
+		apierr.BadRequest(c, "q must be at least 2 characters")
+		return
+	}
+
+	types := searchTypes
+	if value := c.Query("type"); value != "" {
+		types = strings.Split(value, ",")
+		for _, t := range types {
+			if !contains(searchTypes, t) {
+				apierr.BadRequest(c, "type must be one of: "+strings.Join(searchTypes, ", "))
+				return
+			}
+		}
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit < 1 || limit > 50 {
+		limit = 20
+	}
+
+	// Titles also match by trigram similarity when pg_trgm is installed
+	rank, match := "ts_rank_cd(d.document, q)", "d.document @@ q"
+	if database.TrigramSearch {
+		rank, match = rank+" + word_similarity($1, d.title)", "("+match+" OR $1 <% d.title)"
+	}
+
+	// Rank and page first so ts_headline only runs on the returned rows
+	rows, err := database.DB.Query(`
+		WITH matches AS (
+			SELECT d.resource_type, d.resource_id, d.parent_id, d.title, d.subtitle, d.body,
+			       `+rank+` AS rank,
+			       COUNT(*) OVER () AS total
+			FROM search_documents d, websearch_to_tsquery('english', $1) q
+			WHERE d.visible AND d.resource_type = ANY($2) AND `+match+`
+			ORDER BY rank DESC, d.title, d.resource_id
+			LIMIT $3 OFFSET $4
+		)
+		SELECT m.resource_type, m.resource_id, COALESCE(m.parent_id, ''), m.title, COALESCE(m.subtitle, ''),
+		       ts_headline('english', COALESCE(NULLIF(m.body, ''), m.title), websearch_to_tsquery('english', $1), $5),
+		       m.rank, m.total
+		FROM matches m
+		ORDER BY m.rank DESC, m.title, m.resource_id
+	`, query, pq.Array(types), limit, (page-1)*limit,
+		`StartSel="`+snippetStart+`", StopSel="`+snippetStop+`", MaxWords=35, MinWords=15, MaxFragments=2`)
+	if err != nil {
+		apierr.Fail(c, "Failed to search", err)
+		return
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+	total := 0
+	for rows.Next() {
+		var result SearchResult
+		err := rows.Scan(&result.Type, &result.ID, &result.ParentID, &result.Title, &result.Subtitle,
+			&result.Snippet, &result.Rank, &total)
+		if err != nil {
+			continue
+		}
+		result.Snippet = strings.NewReplacer(snippetStart, "<mark>", snippetStop, "</mark>").
+			Replace(html.EscapeString(result.Snippet))
+		results = append(results, result)
+	}
+
+	c.JSON(200, gin.H{
+		"query": query,
+		"data":  results,
+		"pagination": listing.Pagination{
+			Limit:      limit,
+			Total:      total,
+			Page:       page,
+			TotalPages: (total + limit - 1) / limit,
+			HasMore:    page*limit < total,
+		},
+	})
+}
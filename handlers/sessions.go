@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"playtz-api/apierr"
+	"playtz-api/auth"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionResponse is one active login of the current user
+type SessionResponse struct {
+	ID         string `json:"id"`
+	UserAgent  string `json:"user_agent"`
+	IP         string `json:"ip"`
+	CreatedAt  string `json:"created_at"`
+	LastSeenAt string `json:"last_seen_at"`
+	Current    bool   `json:"current"`
+}
+
+// GetSessions lists the current user's active sessions, most recently used first
+func GetSessions(c *gin.Context) {
+	sessions, err := auth.ListSessions(c.GetString("user_id"))
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch sessions", err)
+		return
+	}
+
+	currentID := c.GetString("session_id")
+	response := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, SessionResponse{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			CreatedAt:  session.CreatedAt.Format(time.RFC3339),
+			LastSeenAt: session.LastSeenAt.Format(time.RFC3339),
+			Current:    session.ID == currentID,
+		})
+	}
+
+	c.JSON(200, response)
+}
+
+// RevokeSession signs the current user out of one of their sessions
+func RevokeSession(c *gin.Context) {
+	err := auth.RevokeSession(c.GetString("user_id"), c.Param("id"))
+	if err == auth.ErrSessionNotFound {
+		apierr.NotFound(c, "Session not found")
+		return
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to revoke session", err)
+		return
+	}
+
+	if c.Param("id") == c.GetString("session_id") {
+		clearAuthCookies(c)
+	}
+
+	c.JSON(200, gin.H{"message": "Session revoked"})
+}
+
+// RevokeOtherSessions signs the current user out of every session except this one
+func RevokeOtherSessions(c *gin.Context) {
+	revoked, err := auth.RevokeOtherSessions(c.GetString("user_id"), c.GetString("session_id"))
+	if err != nil {
+		apierr.Fail(c, "Failed to revoke sessions", err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message": "Other sessions revoked",
+		"revoked": revoked,
+	})
+}
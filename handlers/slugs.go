@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"database/sql"
+	"path"
+	"playtz-api/apierr"
+	"playtz-api/slugs"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Visibility of slugged resources on public routes, as conditions on the resource's own columns
+const (
+	publicNewsVisible  = "published = true"
+	publicEventVisible = "active = true"
+	publicRoomVisible  = "active = true"
+	publicMixVisible   = "active = true AND room_id IN (SELECT id FROM rooms WHERE active = true)"
+)
+
+// serveBySlug serves GET .../slug/:slug with the resource's by-ID handler. Former slugs and
+// non-canonical spellings are redirected (301) to the current slug.
+func serveBySlug(c *gin.Context, resource slugs.Resource, visible, notFound string, byID gin.HandlerFunc) {
+	requested := c.Param("slug")
+	id, current, err := slugs.Resolve(resource, requested, visible)
+	if err == sql.ErrNoRows {
+		apierr.NotFound(c, notFound)
+		return
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to look up slug", err)
+		return
+	}
+
+	if current != requested {
+		location := path.Join(path.Dir(c.Request.URL.Path), current)
+		if c.Request.URL.RawQuery != "" {
+			location += "?" + c.Request.URL.RawQuery
+		}
+		c.Redirect(301, location)
+		return
+	}
+
+	// The by-ID handlers read :id
+	c.Params = append(c.Params, gin.Param{Key: "id", Value: id})
+	byID(c)
+}
+
+// respondSlugError answers a failed slugs.Assign or slugs.Change
+func respondSlugError(c *gin.Context, err error) {
+	switch err {
+	case slugs.ErrInvalid:
+		apierr.Respond(c, apierr.New(400, apierr.CodeValidationFailed, "Invalid slug: "+err.Error()).
+			WithDetails(apierr.FieldError{Field: "slug", Code: "invalid", Message: err.Error()}))
+	case slugs.ErrTaken:
+		apierr.Respond(c, apierr.New(409, apierr.CodeConflict, "Slug is already in use").
+			WithDetails(apierr.FieldError{Field: "slug", Code: "taken", Message: "is already in use"}))
+	default:
+		apierr.Fail(c, "Failed to assign slug", err)
+	}
+}
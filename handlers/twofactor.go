@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"playtz-api/apierr"
+	"playtz-api/auth"
+	"playtz-api/database"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// TwoFactorEnrollResponse is returned when a user starts 2FA enrollment
+type TwoFactorEnrollResponse struct {
+	Secret        string   `json:"secret"`
+	OTPAuthURI    string   `json:"otpauth_uri"`
+	RecoveryCodes []string `json:"recovery_codes"` // Shown once - only hashes are stored
+	Message       string   `json:"message"`
+}
+
+// TwoFactorCodeRequest carries a TOTP code
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// TwoFactorLoginRequest completes a login that returned two_factor_required
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code"`          // TOTP code
+	RecoveryCode   string `json:"recovery_code"` // Alternative to code
+}
+
+// TwoFactorDisableRequest represents a request to turn 2FA off
+type TwoFactorDisableRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// EnrollTwoFactor generates a new TOTP secret and recovery codes for the current user.
+// 2FA is only enabled once a code is confirmed through VerifyTwoFactor.
+func EnrollTwoFactor(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var email string
+	var enabled bool
+	err := database.DB.QueryRow(
+		"SELECT email, COALESCE(totp_enabled, false) FROM users WHERE id = $1",
+		userID,
+	).Scan(&email, &enabled)
+	if err == sql.ErrNoRows {
+		apierr.NotFound(c, "User not found")
+		return
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch user", err)
+		return
+	}
+	if enabled {
+		apierr.Conflict(c, "Two-factor authentication is already enabled")
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		apierr.Fail(c, "Failed to generate secret", err)
+		return
+	}
+	codes, err := auth.GenerateRecoveryCodes(auth.RecoveryCodeCount)
+	if err != nil {
+		apierr.Fail(c, "Failed to generate recovery codes", err)
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		apierr.Fail(c, "Failed to start enrollment", err)
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		"UPDATE users SET totp_secret = $1, totp_enabled = false, totp_last_step = 0, updated_at = CURRENT_TIMESTAMP WHERE id = $2",
+		secret, userID,
+	); err != nil {
+		apierr.Fail(c, "Failed to store secret", err)
+		return
+	}
+
+	// Replace any codes from a previous, unfinished enrollment
+	if _, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = $1", userID); err != nil {
+		apierr.Fail(c, "Failed to store recovery codes", err)
+		return
+	}
+	for _, code := range codes {
+		if _, err := tx.Exec(
+			"INSERT INTO recovery_codes (id, user_id, code_hash) VALUES ($1, $2, $3)",
+			uuid.New().String(), userID, auth.HashToken(code),
+		); err != nil {
+			apierr.Fail(c, "Failed to store recovery codes", err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		apierr.Fail(c, "Failed to complete enrollment", err)
+		return
+	}
+
+	recordAccountEvent(c, "users.2fa.enroll", userID)
+	c.JSON(200, TwoFactorEnrollResponse{
+		Secret:        secret,
+		OTPAuthURI:    auth.TOTPURI(secret, email),
+		RecoveryCodes: codes,
+		Message:       "Scan the URI with an authenticator app, then confirm a code via /auth/2fa/verify",
+	})
+}
+
+// VerifyTwoFactor confirms enrollment with a code and enables 2FA.
+// A fresh access token is returned so a 2FA-setup-restricted token is lifted immediately.
+func VerifyTwoFactor(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var req TwoFactorCodeRequest
+	if !bindJSON(c, &req, "Code is required") {
+		return
+	}
+
+	ok, err := checkTOTP(userID, req.Code)
+	if err != nil {
+		apierr.Fail(c, "Failed to verify code", err)
+		return
+	}
+	if !ok {
+		apierr.Unauthorized(c, "Invalid two-factor code")
+		return
+	}
+
+	if _, err := database.DB.Exec(
+		"UPDATE users SET totp_enabled = true, updated_at = CURRENT_TIMESTAMP WHERE id = $1",
+		userID,
+	); err != nil {
+		apierr.Fail(c, "Failed to enable two-factor authentication", err)
+		return
+	}
+
+	user, err := loadUser(userID)
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch user", err)
+		return
+	}
+	// Keep the current session: only the restriction on the access token changes
+	token, _, err := generateAccessToken(user, c.GetString("session_id"))
+	if err != nil {
+		apierr.Fail(c, "Failed to generate token", err)
+		return
+	}
+	setAuthCookies(c, token, "")
+
+	recordAccountEvent(c, "users.2fa.enable", userID)
+	c.JSON(200, gin.H{
+		"message": "Two-factor authentication enabled",
+		"token":   token,
+	})
+}
+
+// DisableTwoFactor turns 2FA off for the current user (not allowed when the role requires it)
+func DisableTwoFactor(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var req TwoFactorDisableRequest
+	if !bindJSON(c, &req, "Password and code are required") {
+		return
+	}
+
+	var passwordHash string
+	var require2FA bool
+	err := database.DB.QueryRow(`
+		SELECT u.password_hash, COALESCE(r.require_2fa, false)
+		FROM users u
+		LEFT JOIN roles r ON u.role_id = r.id
+		WHERE u.id = $1
+	`, userID).Scan(&passwordHash, &require2FA)
+	if err == sql.ErrNoRows {
+		apierr.NotFound(c, "User not found")
+		return
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch user", err)
+		return
+	}
+
+	if require2FA {
+		apierr.Forbidden(c, "Two-factor authentication is required for your role")
+		return
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(req.Password)) != nil {
+		apierr.Unauthorized(c, "Password is incorrect")
+		return
+	}
+
+	ok, err := checkTOTP(userID, req.Code)
+	if err != nil {
+		apierr.Fail(c, "Failed to verify code", err)
+		return
+	}
+	if !ok {
+		apierr.Unauthorized(c, "Invalid two-factor code")
+		return
+	}
+
+	if _, err := database.DB.Exec(
+		"UPDATE users SET totp_secret = NULL, totp_enabled = false, totp_last_step = 0, updated_at = CURRENT_TIMESTAMP WHERE id = $1",
+		userID,
+	); err != nil {
+		apierr.Fail(c, "Failed to disable two-factor authentication", err)
+		return
+	}
+	database.DB.Exec("DELETE FROM recovery_codes WHERE user_id = $1", userID)
+
+	recordAccountEvent(c, "users.2fa.disable", userID)
+	c.JSON(200, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// TwoFactorLogin completes a login step-up challenge with a TOTP or recovery code
+func TwoFactorLogin(c *gin.Context) {
+	var req TwoFactorLoginRequest
+	if !bindJSON(c, &req, "Challenge token and a code or recovery code are required") {
+		return
+	}
+	if req.Code == "" && req.RecoveryCode == "" {
+		apierr.Respond(c, apierr.New(400, apierr.CodeValidationFailed, "Challenge token and a code or recovery code are required").
+			WithDetails(apierr.FieldError{Field: "code", Code: "required", Message: "is required unless recovery_code is given"}))
+		return
+	}
+
+	claims, err := auth.ValidateChallengeToken(req.ChallengeToken)
+	if err != nil {
+		apierr.Unauthorized(c, "Invalid or expired challenge - please log in again")
+		return
+	}
+
+	// Codes count against the account like passwords, so new challenges do not give new guesses
+	limiter := auth.GetLoginLimiter()
+	if loginBlocked(c, limiter, auth.AccountKey(claims.UserID)) {
+		return
+	}
+	exhausted, err := auth.ChallengeExhausted(claims)
+	if err != nil {
+		apierr.Fail(c, "Failed to verify code", err)
+		return
+	}
+	if exhausted {
+		apierr.TooManyRequests(c, "Too many attempts - please log in again")
+		return
+	}
+	if used, err := auth.ChallengeUsed(claims); err != nil {
+		apierr.Fail(c, "Failed to verify code", err)
+		return
+	} else if used {
+		apierr.Unauthorized(c, "Invalid or expired challenge - please log in again")
+		return
+	}
+
+	user, err := loadUser(claims.UserID)
+	if err == sql.ErrNoRows || (err == nil && !user.Active) {
+		apierr.Forbidden(c, "Account is inactive")
+		return
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch user", err)
+		return
+	}
+
+	var ok bool
+	if req.Code != "" {
+		ok, err = checkTOTP(user.ID, req.Code)
+	} else {
+		ok, err = consumeRecoveryCode(user.ID, req.RecoveryCode)
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to verify code", err)
+		return
+	}
+	if !ok {
+		recordLoginFailure(limiter, user.ID, user.Username, c.ClientIP())
+		exhausted, err := auth.RecordChallengeFailure(claims)
+		if err != nil {
+			log.Printf("Failed to record two-factor failure for user %s: %v", user.ID, err)
+		}
+		if exhausted {
+			apierr.TooManyRequests(c, "Too many attempts - please log in again")
+			return
+		}
+		apierr.Unauthorized(c, "Invalid two-factor code")
+		return
+	}
+
+	// A challenge completes one login only, even if its code is captured and replayed
+	if consumed, err := auth.ConsumeChallenge(claims); err != nil {
+		apierr.Fail(c, "Failed to verify code", err)
+		return
+	} else if !consumed {
+		apierr.Unauthorized(c, "Invalid or expired challenge - please log in again")
+		return
+	}
+
+	// Both factors passed: only now are the account's failures forgotten
+	if err := limiter.Reset(auth.AccountKey(user.ID)); err != nil {
+		log.Printf("Failed to reset login attempts for user %s: %v", user.ID, err)
+	}
+	limiter.Reset(auth.ChallengeKey(claims.ID))
+
+	completeLogin(c, user, "Login successful")
+}
+
+// checkTOTP validates a code against the user's stored secret and records the
+// accepted time step so the same code cannot be used twice
+func checkTOTP(userID, code string) (bool, error) {
+	var secret sql.NullString
+	var lastStep int64
+	err := database.DB.QueryRow(
+		"SELECT totp_secret, COALESCE(totp_last_step, 0) FROM users WHERE id = $1",
+		userID,
+	).Scan(&secret, &lastStep)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !secret.Valid || secret.String == "" {
+		return false, nil
+	}
+
+	step, ok := auth.ValidateTOTP(secret.String, code, time.Now())
+	if !ok || step <= lastStep {
+		return false, nil
+	}
+
+	// Conditional update guards against two concurrent uses of the same code
+	result, err := database.DB.Exec(
+		"UPDATE users SET totp_last_step = $1 WHERE id = $2 AND COALESCE(totp_last_step, 0) < $1",
+		step, userID,
+	)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected == 1, nil
+}
+
+// consumeRecoveryCode marks a recovery code as used, reporting whether it was valid
+func consumeRecoveryCode(userID, code string) (bool, error) {
+	result, err := database.DB.Exec(`
+		UPDATE recovery_codes SET used_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+		  AND EXISTS (SELECT 1 FROM users WHERE id = $1 AND totp_enabled = true)
+	`, userID, auth.HashToken(auth.NormalizeRecoveryCode(code)))
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected == 1, nil
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"playtz-api/apierr"
 	"strings"
 	"time"
 	
@@ -31,7 +32,7 @@ func UploadImage(c *gin.Context) {
 	// Parse multipart form (10 MB max)
 	err := c.Request.ParseMultipartForm(10 << 20)
 	if err != nil {
-		c.JSON(400, gin.H{"error": "Failed to parse form"})
+		apierr.BadRequest(c, "Failed to parse form")
 		return
 	}
 
@@ -47,7 +48,7 @@ func UploadImage(c *gin.Context) {
 	// Get file from form
 	file, handler, err := c.Request.FormFile("image")
 	if err != nil {
-		c.JSON(400, gin.H{"error": "No image file provided"})
+		apierr.BadRequest(c, "No image file provided")
 		return
 	}
 	defer file.Close()
@@ -56,21 +57,21 @@ func UploadImage(c *gin.Context) {
 	ext := filepath.Ext(handler.Filename)
 	allowedExts := map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true}
 	if !allowedExts[ext] {
-		c.JSON(400, gin.H{"error": "Invalid file type. Only images are allowed"})
+		apierr.BadRequest(c, "Invalid file type. Only images are allowed")
 		return
 	}
 
 	// Read file into memory
 	fileBytes, err := io.ReadAll(file)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to read file"})
+		apierr.Fail(c, "Failed to read file", err)
 		return
 	}
 
 	// Convert to WebP
 	fileBytes, err = convertToWebP(fileBytes, ext)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Image processing failed: " + err.Error()})
+		apierr.Fail(c, "Image processing failed", err)
 		return
 	}
 
@@ -80,14 +81,7 @@ func UploadImage(c *gin.Context) {
 	apiSecret := os.Getenv("CLOUDINARY_API_SECRET")
 	
 	if cloudName == "" || apiKey == "" || apiSecret == "" {
-		c.JSON(500, gin.H{
-			"error": "Cloudinary configuration error: missing credentials",
-			"debug": map[string]interface{}{
-				"cloud_name_set": cloudName != "",
-				"api_key_set":    apiKey != "",
-				"api_secret_set": apiSecret != "",
-			},
-		})
+		apierr.Internal(c, "Cloudinary configuration error: missing credentials")
 		return
 	}
 	
@@ -96,25 +90,9 @@ func UploadImage(c *gin.Context) {
 	apiKey = strings.TrimSpace(apiKey)
 	apiSecret = strings.TrimSpace(apiSecret)
 	
-	// Debug: log what we're sending (first few chars only for security)
-	secretPreview := ""
-	if len(apiSecret) > 10 {
-		secretPreview = apiSecret[:10] + "..."
-	} else {
-		secretPreview = apiSecret
-	}
-	
 	cld, err := cloudinary.NewFromParams(cloudName, apiKey, apiSecret)
 	if err != nil {
-		c.JSON(500, gin.H{
-			"error": "Upload failed: " + err.Error(),
-			"debug": map[string]interface{}{
-				"cloud_name": cloudName,
-				"api_key": apiKey,
-				"api_secret_length": len(apiSecret),
-				"api_secret_preview": secretPreview,
-			},
-		})
+		apierr.Fail(c, "Upload failed", err)
 		return
 	}
 
@@ -137,27 +115,19 @@ func UploadImage(c *gin.Context) {
 	})
 
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Upload failed: " + err.Error()})
+		apierr.Fail(c, "Upload failed", err)
 		return
 	}
 
 	// Check if upload result is valid
 	if uploadResult == nil {
-		c.JSON(500, gin.H{"error": "Upload failed: empty response from Cloudinary"})
+		apierr.Internal(c, "Upload failed: empty response from Cloudinary")
 		return
 	}
 
 	// Check for empty URLs - this indicates a Cloudinary API issue
 	if uploadResult.SecureURL == "" && uploadResult.URL == "" {
-		c.JSON(500, gin.H{
-			"error": "Upload failed: Cloudinary returned empty URLs. This usually indicates invalid credentials or account restrictions.",
-			"debug": map[string]interface{}{
-				"public_id": uploadResult.PublicID,
-				"has_url":   uploadResult.URL != "",
-				"has_secure_url": uploadResult.SecureURL != "",
-				"result_type": fmt.Sprintf("%T", uploadResult),
-			},
-		})
+		apierr.Internal(c, "Upload failed: Cloudinary returned empty URLs. This usually indicates invalid credentials or account restrictions.")
 		return
 	}
 
@@ -175,7 +145,7 @@ func UploadImage(c *gin.Context) {
 func UploadMultipleImages(c *gin.Context) {
 	err := c.Request.ParseMultipartForm(50 << 20) // 50 MB max
 	if err != nil {
-		c.JSON(400, gin.H{"error": "Failed to parse form"})
+		apierr.BadRequest(c, "Failed to parse form")
 		return
 	}
 
@@ -191,7 +161,7 @@ func UploadMultipleImages(c *gin.Context) {
 	form := c.Request.MultipartForm
 	files := form.File["images"]
 	if len(files) == 0 {
-		c.JSON(400, gin.H{"error": "No images provided"})
+		apierr.BadRequest(c, "No images provided")
 		return
 	}
 
@@ -201,14 +171,7 @@ func UploadMultipleImages(c *gin.Context) {
 	apiSecret := os.Getenv("CLOUDINARY_API_SECRET")
 	
 	if cloudName == "" || apiKey == "" || apiSecret == "" {
-		c.JSON(500, gin.H{
-			"error": "Cloudinary configuration error: missing credentials",
-			"debug": map[string]interface{}{
-				"cloud_name_set": cloudName != "",
-				"api_key_set":    apiKey != "",
-				"api_secret_set": apiSecret != "",
-			},
-		})
+		apierr.Internal(c, "Cloudinary configuration error: missing credentials")
 		return
 	}
 	
@@ -217,25 +180,9 @@ func UploadMultipleImages(c *gin.Context) {
 	apiKey = strings.TrimSpace(apiKey)
 	apiSecret = strings.TrimSpace(apiSecret)
 	
-	// Debug: log what we're sending (first few chars only for security)
-	secretPreview := ""
-	if len(apiSecret) > 10 {
-		secretPreview = apiSecret[:10] + "..."
-	} else {
-		secretPreview = apiSecret
-	}
-	
 	cld, err := cloudinary.NewFromParams(cloudName, apiKey, apiSecret)
 	if err != nil {
-		c.JSON(500, gin.H{
-			"error": "Upload failed: " + err.Error(),
-			"debug": map[string]interface{}{
-				"cloud_name": cloudName,
-				"api_key": apiKey,
-				"api_secret_length": len(apiSecret),
-				"api_secret_preview": secretPreview,
-			},
-		})
+		apierr.Fail(c, "Upload failed", err)
 		return
 	}
 
@@ -286,7 +233,7 @@ func UploadMultipleImages(c *gin.Context) {
 	}
 
 	if len(responses) == 0 {
-		c.JSON(500, gin.H{"error": "Failed to upload images"})
+		apierr.Internal(c, "Failed to upload images")
 		return
 	}
 
@@ -1,10 +1,11 @@
 package handlers
 
 import (
-	"crypto/rand"
 	"database/sql"
-	"encoding/base64"
+	"playtz-api/apierr"
+	"playtz-api/auth"
 	"playtz-api/database"
+	"playtz-api/listing"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -14,26 +15,27 @@ import (
 
 // User represents a user account
 type User struct {
-	ID        string `json:"id"`
-	Email     string `json:"email"`
-	Username  string `json:"username"`
-	FirstName string `json:"first_name"`
-	LastName  string `json:"last_name"`
-	RoleID    string `json:"role_id"`
-	RoleName  string `json:"role_name,omitempty"`
-	Active    bool   `json:"active"`
-	CreatedAt string `json:"created_at,omitempty"`
-	UpdatedAt string `json:"updated_at,omitempty"`
+	ID          string `json:"id"`
+	Email       string `json:"email"`
+	Username    string `json:"username"`
+	FirstName   string `json:"first_name"`
+	LastName    string `json:"last_name"`
+	RoleID      string `json:"role_id"`
+	RoleName    string `json:"role_name,omitempty"`
+	Active      bool   `json:"active"`
+	AccountType string `json:"account_type,omitempty"` // "staff" or "listener" (self-registered)
+	CreatedAt   string `json:"created_at,omitempty"`
+	UpdatedAt   string `json:"updated_at,omitempty"`
 }
 
 // CreateUserRequest represents user creation request
 type CreateUserRequest struct {
-	Email     string `json:"email"`
-	Username  string `json:"username"`
+	Email     string `json:"email" binding:"required,email,max=255"`
+	Username  string `json:"username" binding:"required,notblank,max=100"`
 	Password  string `json:"password,omitempty"` // Optional - will generate default if not provided
-	FirstName string `json:"first_name"`
-	LastName  string `json:"last_name"`
-	RoleID    string `json:"role_id"`
+	FirstName string `json:"first_name" binding:"max=100"`
+	LastName  string `json:"last_name" binding:"max=100"`
+	RoleID    string `json:"role_id" binding:"required"`
 }
 
 // CreateUserResponse represents user creation response
@@ -43,16 +45,39 @@ type CreateUserResponse struct {
 	Message         string `json:"message,omitempty"`
 }
 
-// GetUsers returns all users
+// usersListing is what GET /users can be filtered and sorted by
+var usersListing = &listing.Spec{
+	From: "users u",
+	ID:   "u.id",
+	Filters: map[string]listing.Filter{
+		"account_type": {Column: "u.account_type"},
+		"role_id":      {Column: "u.role_id"},
+		"active":       {Column: "u.active", Type: listing.Bool},
+		"created":      {Column: "u.created_at", Type: listing.Time, Range: true},
+	},
+	Sorts: map[string]string{
+		"created_at": "u.created_at",
+		"username":   "u.username",
+		"email":      "u.email",
+	},
+	DefaultSort: "-created_at",
+}
+
+// GetUsers returns a page of users, optionally filtered by ?account_type=staff|listener
 func GetUsers(c *gin.Context) {
-	rows, err := database.DB.Query(`
-		SELECT u.id, u.email, u.username, u.first_name, u.last_name, u.role_id, u.active, u.created_at, u.updated_at, r.name as role_name
+	q, err := listing.Parse(c, usersListing)
+	if err != nil {
+		apierr.BadRequest(c, err.Error())
+		return
+	}
+
+	rows, err := q.Rows(`
+		SELECT u.id, u.email, u.username, u.first_name, u.last_name, u.role_id, u.active, u.account_type, u.created_at, u.updated_at, r.name as role_name
 		FROM users u
 		LEFT JOIN roles r ON u.role_id = r.id
-		ORDER BY u.created_at DESC
 	`)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to fetch users"})
+		apierr.Fail(c, "Failed to fetch users", err)
 		return
 	}
 	defer rows.Close()
@@ -62,7 +87,7 @@ func GetUsers(c *gin.Context) {
 		var user User
 		var createdAt, updatedAt time.Time
 		var roleName *string
-		err := rows.Scan(&user.ID, &user.Email, &user.Username, &user.FirstName, &user.LastName, &user.RoleID, &user.Active, &createdAt, &updatedAt, &roleName)
+		err := rows.Scan(&user.ID, &user.Email, &user.Username, &user.FirstName, &user.LastName, &user.RoleID, &user.Active, &user.AccountType, &createdAt, &updatedAt, &roleName)
 		if err != nil {
 			continue
 		}
@@ -74,7 +99,13 @@ func GetUsers(c *gin.Context) {
 		users = append(users, user)
 	}
 
-	c.JSON(200, users)
+	page, err := listing.Paginate(q, users, func(u User) string { return u.ID })
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch users", err)
+		return
+	}
+
+	c.JSON(200, page)
 }
 
 // GetUserByID returns a specific user
@@ -85,18 +116,18 @@ func GetUserByID(c *gin.Context) {
 	var createdAt, updatedAt time.Time
 	var roleName *string
 	err := database.DB.QueryRow(`
-		SELECT u.id, u.email, u.username, u.first_name, u.last_name, u.role_id, u.active, u.created_at, u.updated_at, r.name as role_name
+		SELECT u.id, u.email, u.username, u.first_name, u.last_name, u.role_id, u.active, u.account_type, u.created_at, u.updated_at, r.name as role_name
 		FROM users u
 		LEFT JOIN roles r ON u.role_id = r.id
 		WHERE u.id = $1
-	`, id).Scan(&user.ID, &user.Email, &user.Username, &user.FirstName, &user.LastName, &user.RoleID, &user.Active, &createdAt, &updatedAt, &roleName)
+	`, id).Scan(&user.ID, &user.Email, &user.Username, &user.FirstName, &user.LastName, &user.RoleID, &user.Active, &user.AccountType, &createdAt, &updatedAt, &roleName)
 
 	if err == sql.ErrNoRows {
-		c.JSON(404, gin.H{"error": "User not found"})
+		apierr.NotFound(c, "User not found")
 		return
 	}
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to fetch user"})
+		apierr.Fail(c, "Failed to fetch user", err)
 		return
 	}
 
@@ -109,25 +140,61 @@ func GetUserByID(c *gin.Context) {
 	c.JSON(200, user)
 }
 
-// generateDefaultPassword generates a secure random password
+// loadUser fetches a user with its role name by ID
+func loadUser(id string) (*User, error) {
+	var user User
+	var createdAt, updatedAt time.Time
+	var roleName *string
+	err := database.DB.QueryRow(`
+		SELECT u.id, u.email, u.username, u.first_name, u.last_name, u.role_id, u.active, u.account_type, u.created_at, u.updated_at, r.name as role_name
+		FROM users u
+		LEFT JOIN roles r ON u.role_id = r.id
+		WHERE u.id = $1
+	`, id).Scan(&user.ID, &user.Email, &user.Username, &user.FirstName, &user.LastName, &user.RoleID, &user.Active, &user.AccountType, &createdAt, &updatedAt, &roleName)
+	if err != nil {
+		return nil, err
+	}
+
+	if roleName != nil {
+		user.RoleName = *roleName
+	}
+	user.CreatedAt = createdAt.Format(time.RFC3339)
+	user.UpdatedAt = updatedAt.Format(time.RFC3339)
+
+	return &user, nil
+}
+
+// generateDefaultPassword generates a secure random password that meets the password policy
 func generateDefaultPassword() (string, error) {
-	// Generate 12 random bytes
-	bytes := make([]byte, 12)
-	if _, err := rand.Read(bytes); err != nil {
-		return "", err
-	}
-	// Convert to base64 and take first 12 characters, then add some special chars
-	password := base64.URLEncoding.EncodeToString(bytes)[:12]
-	// Add a number and special character for complexity
-	password = password + "1!"
-	return password, nil
+	return auth.GetPasswordPolicy().GeneratePassword()
+}
+
+// checkPasswordPolicy validates a new password for a user (empty userID for new users).
+// On failure it writes a 400 listing every violation and returns false.
+func checkPasswordPolicy(c *gin.Context, userID, password string) bool {
+	violations, err := auth.GetPasswordPolicy().Validate(userID, password)
+	if err != nil {
+		apierr.Fail(c, "Failed to validate password", err)
+		return false
+	}
+	if len(violations) > 0 {
+		e := apierr.New(400, apierr.CodeValidationFailed, "Password does not meet the password policy").With("violations", violations)
+		for _, violation := range violations {
+			e.WithDetails(apierr.FieldError{Field: "password", Code: violation.Code, Message: violation.Message})
+		}
+		apierr.Respond(c, e)
+		return false
+	}
+	return true
 }
 
 // CreateUser creates a new user
 func CreateUser(c *gin.Context) {
 	var req CreateUserRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": "Invalid request body"})
+	if !bindJSON(c, &req, "Invalid request body") {
+		return
+	}
+	if !requireAssignableRole(c, req.RoleID) {
 		return
 	}
 
@@ -138,16 +205,18 @@ func CreateUser(c *gin.Context) {
 		var err error
 		password, err = generateDefaultPassword()
 		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to generate default password"})
+			apierr.Fail(c, "Failed to generate default password", err)
 			return
 		}
 		passwordChangeRequired = true
+	} else if !checkPasswordPolicy(c, "", password) {
+		return
 	}
 
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to hash password"})
+		apierr.Fail(c, "Failed to hash password", err)
 		return
 	}
 
@@ -158,7 +227,7 @@ func CreateUser(c *gin.Context) {
 	)
 
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to create user: " + err.Error()})
+		apierr.Fail(c, "Failed to create user", err)
 		return
 	}
 
@@ -200,50 +269,93 @@ func CreateUser(c *gin.Context) {
 	}
 }
 
-// UpdateUser updates an existing user
-func UpdateUser(c *gin.Context) {
-	id := c.Param("id")
+// UpdateUserRequest represents user update request
+type UpdateUserRequest struct {
+	Email     string `json:"email" binding:"required,email,max=255"`
+	Username  string `json:"username" binding:"required,notblank,max=100"`
+	FirstName string `json:"first_name" binding:"max=100"`
+	LastName  string `json:"last_name" binding:"max=100"`
+	RoleID    string `json:"role_id" binding:"required"`
+	Active    bool   `json:"active"`
+}
 
-	var user User
-	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(400, gin.H{"error": "Invalid request body"})
+// userFields are the members a PUT of a user must send
+var userFields = []string{"email", "username", "first_name", "last_name", "role_id", "active"}
+
+// UpdateUser replaces a user's details
+func UpdateUser(c *gin.Context) {
+	var req UpdateUserRequest
+	if !replaceJSON(c, &req, "Invalid request body", userFields...) {
 		return
 	}
 
-	user.ID = id
-
-	_, err := database.DB.Exec(
-		"UPDATE users SET email = $1, username = $2, first_name = $3, last_name = $4, role_id = $5, updated_at = CURRENT_TIMESTAMP WHERE id = $6",
-		user.Email, user.Username, user.FirstName, user.LastName, user.RoleID, id,
-	)
+	saveUser(c, c.Param("id"), &req)
+}
 
+// PatchUser changes the details of a user sent in a JSON merge patch
+func PatchUser(c *gin.Context) {
+	user, err := loadUser(c.Param("id"))
+	if err == sql.ErrNoRows {
+		apierr.NotFound(c, "User not found")
+		return
+	}
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to update user: " + err.Error()})
+		apierr.Fail(c, "Failed to fetch user", err)
 		return
 	}
 
-	var createdAt, updatedAt time.Time
-	var roleName *string
-	err = database.DB.QueryRow(`
-		SELECT u.created_at, u.updated_at, r.name as role_name
-		FROM users u
-		LEFT JOIN roles r ON u.role_id = r.id
-		WHERE u.id = $1
-	`, id).Scan(&createdAt, &updatedAt, &roleName)
+	req := UpdateUserRequest{
+		Email:     user.Email,
+		Username:  user.Username,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		RoleID:    user.RoleID,
+		Active:    user.Active,
+	}
+	if !patchJSON(c, &req, "Invalid request body") {
+		return
+	}
 
-	if err == sql.ErrNoRows {
-		c.JSON(404, gin.H{"error": "User not found"})
+	saveUser(c, c.Param("id"), &req)
+}
+
+// saveUser writes req over the user with the ID and answers with the result
+func saveUser(c *gin.Context, id string, req *UpdateUserRequest) {
+	if !requireAssignableRole(c, req.RoleID) {
 		return
 	}
+
+	result, err := database.DB.Exec(
+		"UPDATE users SET email = $1, username = $2, first_name = $3, last_name = $4, role_id = $5, active = $6, updated_at = CURRENT_TIMESTAMP WHERE id = $7",
+		req.Email, req.Username, req.FirstName, req.LastName, req.RoleID, req.Active, id,
+	)
+
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to fetch updated user"})
+		apierr.Fail(c, "Failed to update user", err)
 		return
 	}
 
-	user.CreatedAt = createdAt.Format(time.RFC3339)
-	user.UpdatedAt = updatedAt.Format(time.RFC3339)
-	if roleName != nil {
-		user.RoleName = *roleName
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		apierr.NotFound(c, "User not found")
+		return
+	}
+
+	// role_id may have changed
+	auth.GetPermissionCache().InvalidateUser(id)
+
+	// Deactivated users lose every session immediately
+	if !req.Active {
+		if err := auth.RevokeAllUserTokens(id); err != nil {
+			apierr.Fail(c, "User deactivated but failed to revoke sessions", err)
+			return
+		}
+	}
+
+	user, err := loadUser(id)
+	if err != nil {
+		apierr.Fail(c, "Failed to fetch updated user", err)
+		return
 	}
 
 	c.JSON(200, user)
@@ -253,41 +365,109 @@ func UpdateUser(c *gin.Context) {
 func DeleteUser(c *gin.Context) {
 	id := c.Param("id")
 
+	// Revoke first: the revocation row outlives the user and blocks any token still in flight
+	if err := auth.RevokeAllUserTokens(id); err != nil {
+		apierr.Fail(c, "Failed to revoke user sessions", err)
+		return
+	}
+
 	result, err := database.DB.Exec("DELETE FROM users WHERE id = $1", id)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to delete user: " + err.Error()})
+		apierr.Fail(c, "Failed to delete user", err)
 		return
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		c.JSON(404, gin.H{"error": "User not found"})
+		apierr.NotFound(c, "User not found")
 		return
 	}
 
+	auth.GetPermissionCache().InvalidateUser(id)
+
 	c.JSON(200, gin.H{"message": "User deleted successfully"})
 }
 
+// UpdateUserRoleRequest assigns a user a role
+type UpdateUserRoleRequest struct {
+	RoleID string `json:"role_id" binding:"required"`
+}
+
 // UpdateUserRole updates a user's role
 func UpdateUserRole(c *gin.Context) {
 	id := c.Param("id")
 
-	var update struct {
-		RoleID string `json:"role_id"`
+	var update UpdateUserRoleRequest
+	if !bindJSON(c, &update, "Invalid request body") {
+		return
 	}
-	if err := c.ShouldBindJSON(&update); err != nil {
-		c.JSON(400, gin.H{"error": "Invalid request body"})
+	if !requireAssignableRole(c, update.RoleID) {
 		return
 	}
 
 	_, err := database.DB.Exec("UPDATE users SET role_id = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2", update.RoleID, id)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to update user role: " + err.Error()})
+		apierr.Fail(c, "Failed to update user role", err)
 		return
 	}
 
+	auth.GetPermissionCache().InvalidateUser(id)
+
 	c.JSON(200, gin.H{
 		"message": "User role updated",
 		"role_id": update.RoleID,
 	})
 }
+
+// UnlockUser clears a user's failed login attempts and ends any active lockout
+func UnlockUser(c *gin.Context) {
+	id := c.Param("id")
+
+	var exists bool
+	if err := database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)", id).Scan(&exists); err != nil {
+		apierr.Fail(c, "Failed to fetch user", err)
+		return
+	}
+	if !exists {
+		apierr.NotFound(c, "User not found")
+		return
+	}
+
+	if err := auth.GetLoginLimiter().Reset(auth.AccountKey(id)); err != nil {
+		apierr.Fail(c, "Failed to unlock user", err)
+		return
+	}
+
+	_, err := database.DB.Exec(`
+		UPDATE login_lockouts SET unlocked_at = CURRENT_TIMESTAMP, unlocked_by = $2
+		WHERE user_id = $1 AND unlocked_at IS NULL AND locked_until > CURRENT_TIMESTAMP
+	`, id, c.GetString("user_id"))
+	if err != nil {
+		apierr.Fail(c, "Failed to unlock user", err)
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "User unlocked"})
+}
+
+// RevokeUserSessions signs a user out everywhere: every session, refresh token and access token is revoked
+func RevokeUserSessions(c *gin.Context) {
+	id := c.Param("id")
+
+	var exists bool
+	if err := database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)", id).Scan(&exists); err != nil {
+		apierr.Fail(c, "Failed to fetch user", err)
+		return
+	}
+	if !exists {
+		apierr.NotFound(c, "User not found")
+		return
+	}
+
+	if err := auth.RevokeAllUserTokens(id); err != nil {
+		apierr.Fail(c, "Failed to revoke user sessions", err)
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "All sessions revoked"})
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"playtz-api/apierr"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// RegisterValidators adds the binding rules request types use beyond the validator's
+// built-in ones. Call it once at startup, before any request is bound.
+//
+//	notblank  the string has a non-space character
+//	date      a calendar date: 2006-01-02, or an RFC3339 timestamp
+//	clock     a time of day: 15:04 or 15:04:05
+func RegisterValidators() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	v.RegisterValidation("notblank", func(fl validator.FieldLevel) bool {
+		return strings.TrimSpace(fl.Field().String()) != ""
+	})
+	v.RegisterValidation("date", func(fl validator.FieldLevel) bool {
+		return parsesAs(fl.Field().String(), "2006-01-02", time.RFC3339)
+	})
+	v.RegisterValidation("clock", func(fl validator.FieldLevel) bool {
+		return parsesAs(fl.Field().String(), "15:04", "15:04:05")
+	})
+}
+
+// parsesAs reports whether value parses with one of the time layouts
+func parsesAs(value string, layouts ...string) bool {
+	for _, layout := range layouts {
+		if _, err := time.Parse(layout, value); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// bindJSON decodes the request body into req and checks its binding rules. On failure it
+// answers 400 listing every violation (see apierr.Bind) and returns false. A value of the
+// wrong JSON type only skips that field, so the rest of the body is still checked.
+func bindJSON(c *gin.Context, req interface{}, message string) bool {
+	body, err := readBody(c)
+	if err == nil {
+		err = decodeJSON(body, req)
+	}
+	if err != nil {
+		apierr.Bind(c, err, message)
+		return false
+	}
+	return true
+}
+
+// readBody reads the whole request body
+func readBody(c *gin.Context) ([]byte, error) {
+	if c.Request.Body == nil {
+		return nil, errors.New("missing request body")
+	}
+	return io.ReadAll(c.Request.Body)
+}
+
+// decodeJSON decodes data into req and checks its binding rules. The error joins a value
+// of the wrong JSON type with the rule violations, for apierr.Bind to report together.
+func decodeJSON(data []byte, req interface{}) error {
+	err := json.Unmarshal(data, req)
+	var typeErr *json.UnmarshalTypeError
+	if err != nil && !errors.As(err, &typeErr) {
+		return err
+	}
+	if invalid := binding.Validator.ValidateStruct(req); invalid != nil {
+		err = errors.Join(err, invalid)
+	}
+	return err
+}
@@ -0,0 +1,109 @@
+package listing
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"playtz-api/database"
+	"time"
+)
+
+// cursor points just after the last row of a page: the values of its sort keys
+// (ID last) under the sort order the page was fetched with
+type cursor struct {
+	Sort   string    `json:"s"`
+	Values []*string `json:"v"`
+}
+
+func decodeCursor(value string) (*cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	var cur cursor
+	if err := json.Unmarshal(raw, &cur); err != nil {
+		return nil, err
+	}
+	return &cur, nil
+}
+
+func (cur *cursor) encode() string {
+	raw, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// nextCursor builds the cursor for the rows after the row with the given ID
+func (q *Query) nextCursor(lastID string) (string, error) {
+	columns := ""
+	for i, field := range q.order {
+		if i > 0 {
+			columns += ", "
+		}
+		columns += field.expr
+	}
+
+	values := make([]interface{}, len(q.order))
+	dest := make([]interface{}, len(q.order))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	// From, the sort expressions and the ID column come from the Spec, never from the request
+	err := database.DB.QueryRow(
+		"SELECT "+columns+" FROM "+q.spec.From+" WHERE "+q.spec.idColumn()+" = $1",
+		lastID,
+	).Scan(dest...)
+	if err != nil {
+		return "", err
+	}
+
+	cur := &cursor{Sort: q.sort, Values: make([]*string, len(values))}
+	for i, value := range values {
+		cur.Values[i] = cursorValue(value)
+	}
+	return cur.encode(), nil
+}
+
+// cursorValue renders a scanned column as text that Postgres parses back into the column type
+func cursorValue(value interface{}) *string {
+	var s string
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		s = string(v)
+	case time.Time:
+		s = v.Format(time.RFC3339Nano)
+	default:
+		s = fmt.Sprint(v)
+	}
+	return &s
+}
+
+// keyset returns the condition selecting rows after the cursor, e.g. for "-date,title":
+//
+//	date < $1 OR (date = $1 AND title > $2) OR (date = $1 AND title = $2 AND id > $3)
+func (q *Query) keyset(arg func(interface{}) string) string {
+	placeholders := make([]string, len(q.order))
+	for i, value := range q.cursor.Values {
+		placeholders[i] = arg(value)
+	}
+
+	condition := ""
+	for i, field := range q.order {
+		term := ""
+		for j := 0; j < i; j++ {
+			term += q.order[j].expr + " = " + placeholders[j] + " AND "
+		}
+		operator := " > "
+		if field.desc {
+			operator = " < "
+		}
+		term += field.expr + operator + placeholders[i]
+
+		if i > 0 {
+			condition += " OR "
+		}
+		condition += "(" + term + ")"
+	}
+	return "(" + condition + ")"
+}
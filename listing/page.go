@@ -0,0 +1,110 @@
+package listing
+
+import (
+	"database/sql"
+	"fmt"
+	"playtz-api/database"
+	"sort"
+	"strings"
+)
+
+// Page is the envelope returned by list endpoints
+type Page[T any] struct {
+	Data       []T        `json:"data"`
+	Pagination Pagination `json:"pagination"`
+}
+
+// Pagination describes where a page sits in the full result
+type Pagination struct {
+	Limit      int    `json:"limit"`
+	Total      int    `json:"total"`                 // Rows matching the filters
+	Page       int    `json:"page,omitempty"`        // Offset pagination only
+	TotalPages int    `json:"total_pages,omitempty"` // Offset pagination only
+	HasMore    bool   `json:"has_more"`
+	NextCursor string `json:"next_cursor,omitempty"` // Pass as ?cursor= to fetch the next page
+}
+
+// where renders the WHERE clause, including the cursor condition when asked to
+func (q *Query) where(withCursor bool) (string, []interface{}) {
+	args := append([]interface{}{}, q.args...)
+	conditions := append([]string{}, q.conditions...)
+	if withCursor && q.cursor != nil {
+		conditions = append(conditions, q.keyset(func(value interface{}) string {
+			args = append(args, value)
+			return fmt.Sprintf("$%d", len(args))
+		}))
+	}
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// Rows runs selectFrom ("SELECT ... FROM ...") for the requested page. One row more than
+// the page size is fetched so Paginate can tell whether another page follows.
+func (q *Query) Rows(selectFrom string) (*sql.Rows, error) {
+	where, args := q.where(true)
+
+	order := make([]string, len(q.order))
+	for i, field := range q.order {
+		order[i] = field.expr
+		if field.desc {
+			order[i] += " DESC"
+		}
+	}
+
+	offset := 0
+	if q.cursor == nil {
+		offset = (q.page - 1) * q.limit
+	}
+
+	return database.DB.Query(
+		fmt.Sprintf("%s%s ORDER BY %s LIMIT %d OFFSET %d", selectFrom, where, strings.Join(order, ", "), q.limit+1, offset),
+		args...,
+	)
+}
+
+// Count returns how many rows match the filters, ignoring pagination
+func (q *Query) Count() (int, error) {
+	where, args := q.where(false)
+	var total int
+	err := database.DB.QueryRow("SELECT COUNT(*) FROM "+q.spec.From+where, args...).Scan(&total)
+	return total, err
+}
+
+// Paginate trims the rows fetched by Rows to the page size and wraps them in the list envelope
+func Paginate[T any](q *Query, items []T, id func(T) string) (*Page[T], error) {
+	if items == nil {
+		items = []T{}
+	}
+
+	total, err := q.Count()
+	if err != nil {
+		return nil, err
+	}
+
+	pagination := Pagination{Limit: q.limit, Total: total}
+	if len(items) > q.limit {
+		items = items[:q.limit]
+		pagination.HasMore = true
+		pagination.NextCursor, err = q.nextCursor(id(items[len(items)-1]))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if q.cursor == nil {
+		pagination.Page = q.page
+		pagination.TotalPages = (total + q.limit - 1) / q.limit
+	}
+
+	return &Page[T]{Data: items, Pagination: pagination}, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,258 @@
+// Package listing parses the pagination, filtering and sorting parameters shared by
+// list endpoints and builds the matching SQL.
+//
+// Query parameters:
+//
+//	?limit=50                 page size (default 50, max 200)
+//	?page=2                   offset pagination (1-based)
+//	?cursor=...               cursor pagination; takes precedence over page
+//	?sort=-date,title         comma separated sort keys, "-" for descending
+//	?status=pending,paid      exact filters; text filters accept several values
+//	?date_from=...&date_to=...  range filters (RFC3339 or YYYY-MM-DD, both inclusive)
+package listing
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+const (
+	// DefaultLimit is the page size when ?limit is not given
+	DefaultLimit = 50
+	// MaxLimit caps ?limit
+	MaxLimit = 200
+)
+
+// Type is the type of a filter value
+type Type int
+
+const (
+	Text Type = iota
+	Bool
+	Int
+	Number
+	Time
+)
+
+// Filter is an allow-listed filter on one column or expression
+type Filter struct {
+	Column string
+	Type   Type
+	// Range filters are set with ?<name>_from and ?<name>_to instead of ?<name>
+	Range bool
+}
+
+// Spec describes what a list endpoint can be filtered and sorted by
+type Spec struct {
+	// From is the table (with alias if the columns use one) that the filters and sort expressions refer to
+	From string
+	// ID is the unique column used as the final sort key (default "id")
+	ID string
+	// Filters maps query parameter names to filters
+	Filters map[string]Filter
+	// Sorts maps sort keys to SQL expressions. Nullable columns must be wrapped in COALESCE
+	// so cursors can compare them.
+	Sorts map[string]string
+	// DefaultSort is used when ?sort is not given, e.g. "-created_at"
+	DefaultSort string
+}
+
+func (s *Spec) idColumn() string {
+	if s.ID == "" {
+		return "id"
+	}
+	return s.ID
+}
+
+// sortField is one parsed sort key
+type sortField struct {
+	expr string
+	desc bool
+}
+
+// Query is a parsed list request
+type Query struct {
+	spec       *Spec
+	conditions []string
+	args       []interface{}
+	sort       string
+	order      []sortField
+	limit      int
+	page       int
+	cursor     *cursor
+}
+
+// Parse reads the listing parameters of a request. Errors are safe to show to the client.
+func Parse(c *gin.Context, spec *Spec) (*Query, error) {
+	q := &Query{spec: spec, limit: DefaultLimit, page: 1}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return nil, fmt.Errorf("limit must be a positive integer")
+		}
+		if limit > MaxLimit {
+			limit = MaxLimit
+		}
+		q.limit = limit
+	}
+
+	if err := q.parseSort(c.DefaultQuery("sort", spec.DefaultSort)); err != nil {
+		return nil, err
+	}
+
+	if err := q.parseFilters(c); err != nil {
+		return nil, err
+	}
+
+	if value := c.Query("cursor"); value != "" {
+		cur, err := decodeCursor(value)
+		if err != nil || cur.Sort != q.sort || len(cur.Values) != len(q.order) {
+			return nil, fmt.Errorf("cursor is invalid or does not match the sort order")
+		}
+		q.cursor = cur
+	} else if value := c.Query("page"); value != "" {
+		page, err := strconv.Atoi(value)
+		if err != nil || page < 1 {
+			return nil, fmt.Errorf("page must be a positive integer")
+		}
+		q.page = page
+	}
+
+	return q, nil
+}
+
+// Arg adds a query argument and returns its placeholder, for handler-specific conditions
+func (q *Query) Arg(value interface{}) string {
+	q.args = append(q.args, value)
+	return fmt.Sprintf("$%d", len(q.args))
+}
+
+// Where adds a condition that every row must match, e.g. q.Where("user_id = " + q.Arg(userID))
+func (q *Query) Where(condition string) {
+	q.conditions = append(q.conditions, condition)
+}
+
+func (q *Query) parseSort(value string) error {
+	q.sort = value
+	seen := map[string]bool{}
+	for _, key := range strings.Split(value, ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		desc := strings.HasPrefix(key, "-")
+		key = strings.TrimPrefix(key, "-")
+		expr, ok := q.spec.Sorts[key]
+		if !ok {
+			return fmt.Errorf("cannot sort by %q (allowed: %s)", key, strings.Join(sortedKeys(q.spec.Sorts), ", "))
+		}
+		if seen[key] {
+			return fmt.Errorf("sort key %q is repeated", key)
+		}
+		seen[key] = true
+		q.order = append(q.order, sortField{expr: expr, desc: desc})
+	}
+
+	// The unique ID makes the order total, which cursors rely on
+	last := false
+	if len(q.order) > 0 {
+		last = q.order[len(q.order)-1].desc
+	}
+	q.order = append(q.order, sortField{expr: q.spec.idColumn(), desc: last})
+	return nil
+}
+
+func (q *Query) parseFilters(c *gin.Context) error {
+	for _, name := range sortedKeys(q.spec.Filters) {
+		filter := q.spec.Filters[name]
+		if filter.Range {
+			if err := q.addRange(c, name, filter); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		if filter.Type == Text {
+			values := strings.Split(value, ",")
+			if len(values) > 1 {
+				q.Where(filter.Column + " = ANY(" + q.Arg(pq.Array(values)) + ")")
+			} else {
+				q.Where(filter.Column + " = " + q.Arg(value))
+			}
+			continue
+		}
+		parsed, err := parseValue(filter.Type, value)
+		if err != nil {
+			return fmt.Errorf("%s %s", name, err)
+		}
+		q.Where(filter.Column + " = " + q.Arg(parsed))
+	}
+	return nil
+}
+
+// addRange adds ?<name>_from (>=) and ?<name>_to (<=) conditions. A date-only _to on a
+// Time filter includes the whole day.
+func (q *Query) addRange(c *gin.Context, name string, filter Filter) error {
+	if value := c.Query(name + "_from"); value != "" {
+		parsed, err := parseValue(filter.Type, value)
+		if err != nil {
+			return fmt.Errorf("%s_from %s", name, err)
+		}
+		q.Where(filter.Column + " >= " + q.Arg(parsed))
+	}
+	if value := c.Query(name + "_to"); value != "" {
+		parsed, err := parseValue(filter.Type, value)
+		if err != nil {
+			return fmt.Errorf("%s_to %s", name, err)
+		}
+		if filter.Type == Time && len(value) == len("2006-01-02") {
+			q.Where(filter.Column + " < " + q.Arg(parsed.(time.Time).AddDate(0, 0, 1)))
+		} else {
+			q.Where(filter.Column + " <= " + q.Arg(parsed))
+		}
+	}
+	return nil
+}
+
+func parseValue(typ Type, value string) (interface{}, error) {
+	switch typ {
+	case Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("must be true or false")
+		}
+		return b, nil
+	case Int:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("must be an integer")
+		}
+		return n, nil
+	case Number:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("must be a number")
+		}
+		return f, nil
+	case Time:
+		if t, err := time.Parse("2006-01-02", value); err == nil {
+			return t, nil
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("must be a date (YYYY-MM-DD) or an RFC3339 timestamp")
+		}
+		return t, nil
+	}
+	return value, nil
+}
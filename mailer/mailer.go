@@ -0,0 +1,42 @@
+package mailer
+
+import (
+	"os"
+	"strings"
+	"sync"
+)
+
+// Message represents an outgoing plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails
+type Mailer interface {
+	Send(msg Message) error
+}
+
+var instance Mailer
+var once sync.Once
+
+// Get returns the configured mailer.
+// MAILER=smtp sends real emails; anything else (default) writes them to MAIL_LOG_FILE or the log.
+func Get() Mailer {
+	once.Do(func() {
+		switch strings.ToLower(os.Getenv("MAILER")) {
+		case "smtp":
+			instance = NewSMTPMailer(
+				os.Getenv("SMTP_HOST"),
+				os.Getenv("SMTP_PORT"),
+				os.Getenv("SMTP_USERNAME"),
+				os.Getenv("SMTP_PASSWORD"),
+				os.Getenv("MAIL_FROM"),
+			)
+		default:
+			instance = NewLogMailer(os.Getenv("MAIL_LOG_FILE"))
+		}
+	})
+	return instance
+}
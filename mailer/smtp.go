@@ -0,0 +1,54 @@
+package mailer
+
+import (
+	"fmt"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPMailer sends emails through an SMTP server
+type SMTPMailer struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     string
+}
+
+// NewSMTPMailer creates an SMTP mailer; port defaults to 587
+func NewSMTPMailer(host, port, username, password, from string) *SMTPMailer {
+	if port == "" {
+		port = "587"
+	}
+	if from == "" {
+		from = username
+	}
+	return &SMTPMailer{host: host, port: port, username: username, password: password, from: from}
+}
+
+// Send delivers the message, using STARTTLS when the server supports it
+func (m *SMTPMailer) Send(msg Message) error {
+	if m.host == "" {
+		return fmt.Errorf("SMTP_HOST is not configured")
+	}
+
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+
+	// Strip CR/LF from header values to prevent header injection
+	clean := strings.NewReplacer("\r", "", "\n", "")
+	headers := []string{
+		"From: " + clean.Replace(m.from),
+		"To: " + clean.Replace(msg.To),
+		"Subject: " + clean.Replace(msg.Subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+	}
+	body := strings.Join(headers, "\r\n") + "\r\n\r\n" + msg.Body
+
+	return smtp.SendMail(m.host+":"+m.port, auth, m.from, []string{msg.To}, []byte(body))
+}
@@ -3,10 +3,13 @@ package main
 import (
 	"fmt"
 	"os"
+	"playtz-api/apierr"
+	"playtz-api/audit"
+	"playtz-api/auth"
 	"playtz-api/database"
 	"playtz-api/handlers"
 	"playtz-api/middleware"
-	"strings"
+	"playtz-api/slugs"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -20,6 +23,12 @@ func main() {
 		fmt.Printf("Warning: .env file not found or couldn't be loaded: %v\n", err)
 	}
 
+	// Load JWT signing keys - refuses the default secret in production
+	if err := auth.CheckSigningConfig(); err != nil {
+		fmt.Printf("FATAL: Invalid JWT signing configuration: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Initialize database connection with retries
 	maxRetries := 5
 	retryDelay := 5 * time.Second
@@ -56,26 +65,73 @@ func main() {
 		fmt.Printf("WARNING: Failed to seed admin user: %v. Continuing anyway...\n", err)
 		// Don't exit on seed failure - admin might already exist
 	}
+	if err := database.SeedListenerRole(); err != nil {
+		fmt.Printf("WARNING: Failed to seed listener role: %v. Continuing anyway...\n", err)
+	}
+
+	// Give content created before slugs existed a slug
+	if err := slugs.Backfill(); err != nil {
+		fmt.Printf("WARNING: Failed to generate slugs: %v. Continuing anyway...\n", err)
+	}
+
+	// Prune expired token revocations and refresh tokens in the background
+	auth.StartTokenCleanup()
+
+	// Delete audit log entries older than AUDIT_RETENTION_DAYS in the background
+	audit.StartRetention()
+
+	// Request validation: custom rules, and errors naming fields as they are spelled in JSON
+	handlers.RegisterValidators()
+	apierr.UseJSONFieldNames()
+
+	// Initialize Gin router with every route
+	r := setupRouter()
+
+	// Client IPs from X-Forwarded-For are only believed when sent by a trusted proxy
+	if err := r.SetTrustedProxies(middleware.TrustedProxies()); err != nil {
+		fmt.Printf("FATAL: Invalid TRUSTED_PROXIES: %v\n", err)
+		os.Exit(1)
+	}
+	// Behind an untrusted proxy every client shares its IP, and one attacker's failures would
+	// lock everyone out: per-IP login limits stay off until the client IP can be relied on
+	if !middleware.ClientIPKnown() {
+		fmt.Printf("WARNING: TRUSTED_PROXIES is not set - per-IP login limits are off. Set it to the proxy's IPs, or to \"none\" without a proxy\n")
+		auth.GetLoginLimiter().MaxIPFailures = 0
+	}
 
+	// Get port from environment or use default
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+
+	// Bind to 0.0.0.0 to accept connections from all interfaces (required for Railway)
+	bindAddr := "0.0.0.0:" + port
+	
+	fmt.Printf("🚀 Server starting on %s\n", bindAddr)
+	fmt.Printf("✅ Health check available at http://%s/health\n", bindAddr)
+	fmt.Printf("📊 Database connected: %v\n", database.DB != nil)
+
+	if err := r.Run(bindAddr); err != nil {
+		fmt.Printf("FATAL: Server failed to start: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// setupRouter registers the middleware and every route. Kept apart from main so tests can
+// inspect the routes without a database.
+func setupRouter() *gin.Engine {
 	// Initialize Gin router
 	r := gin.Default()
 
+	// Tag every request with an ID for error responses and logs
+	r.Use(middleware.RequestID())
+
 	// Configure CORS middleware
 	config := cors.DefaultConfig()
 	
-	// Get allowed origins from environment or use defaults
-	allowedOrigins := os.Getenv("CORS_ORIGINS")
-	if allowedOrigins == "" {
-		// Default: allow localhost for development and Vercel production
-		allowedOrigins = "http://localhost:3000,http://localhost:3001,http://localhost:5173,http://localhost:8080,https://playtzadmin.vercel.app"
-	}
-	
-	// Parse allowed origins
-	origins := []string{}
-	for _, origin := range strings.Split(allowedOrigins, ",") {
-		origins = append(origins, strings.TrimSpace(origin))
-	}
-	config.AllowOrigins = origins
+	// Allowed origins come from CORS_ORIGINS or the defaults
+	config.AllowOrigins = middleware.AllowedOrigins()
 	
 	// Enable credentials (cookies, authorization headers)
 	config.AllowCredentials = true
@@ -87,6 +143,9 @@ func main() {
 	config.AllowHeaders = []string{
 		"Content-Type",
 		"Authorization",
+		"X-API-Key",
+		"X-CSRF-Token",
+		"X-Request-ID",
 		"Accept",
 		"Origin",
 		"X-Requested-With",
@@ -99,6 +158,7 @@ func main() {
 		"Content-Length",
 		"Content-Type",
 		"Authorization",
+		"X-Request-ID",
 	}
 	
 	// Max age for preflight requests (24 hours)
@@ -114,6 +174,15 @@ func main() {
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
+
+	// Public keys for verifying access tokens
+	r.GET("/.well-known/jwks.json", handlers.GetJWKS)
+
+	// OpenAPI document generated from the routes below, and a browsable UI for it
+	r.GET("/openapi.json", handlers.OpenAPISpec(r))
+	r.GET("/docs", func(c *gin.Context) {
+		c.HTML(200, "docs.html", nil)
+	})
 	
 	// Debug: Check Cloudinary env vars (remove in production)
 	r.GET("/debug/cloudinary", func(c *gin.Context) {
@@ -144,9 +213,9 @@ func main() {
 
 	// Admin page routes (protected)
 	admin := r.Group("/admin")
-	admin.Use(middleware.RequireAuth())
+	admin.Use(middleware.RequireAuth(), middleware.RequireStaff())
 	{
-		admin.GET("/dashboard", func(c *gin.Context) {
+		admin.GET("/dashboard", middleware.RequirePermission("admin.dashboard"), func(c *gin.Context) {
 			c.HTML(200, "dashboard.html", nil)
 		})
 	}
@@ -159,111 +228,179 @@ func main() {
 		{
 			auth.POST("/login", handlers.Login)
 			auth.POST("/logout", handlers.Logout)
+			auth.POST("/refresh", handlers.Refresh)
+			auth.GET("/csrf", handlers.GetCSRFToken)
+			auth.POST("/forgot-password", handlers.ForgotPassword)
+			auth.POST("/reset-password", handlers.ResetPassword)
+			auth.POST("/register", handlers.Register)
+			auth.POST("/verify-email", handlers.VerifyEmail)
+			auth.POST("/resend-verification", handlers.ResendVerification)
 			auth.GET("/me", handlers.GetCurrentUserOptional) // Optional auth - returns 200 with null if not authenticated
 			auth.POST("/change-password", middleware.RequireAuth(), handlers.ChangePassword)
+			auth.GET("/sessions", middleware.RequireAuth(), handlers.GetSessions)
+			auth.DELETE("/sessions", middleware.RequireAuth(), handlers.RevokeOtherSessions)
+			auth.DELETE("/sessions/:id", middleware.RequireAuth(), handlers.RevokeSession)
+
+			// Two-factor authentication
+			auth.POST("/2fa/login", handlers.TwoFactorLogin)
+			auth.POST("/2fa/enroll", middleware.RequireAuth(), handlers.EnrollTwoFactor)
+			auth.POST("/2fa/verify", middleware.RequireAuth(), handlers.VerifyTwoFactor)
+			auth.POST("/2fa/disable", middleware.RequireAuth(), handlers.DisableTwoFactor)
+
+			// Single sign-on (OpenID Connect)
+			auth.GET("/oidc/login", handlers.OIDCLogin)
+			auth.GET("/oidc/callback", handlers.OIDCCallback)
 		}
 	}
 
-	// Protected API routes - All require authentication
+	// Public routes - No authentication, published/active content only (station website and apps)
+	// Responses carry ETags and may be cached for a minute
+	public := r.Group("/api/v1/public")
+	{
+		public.GET("/news", middleware.PublicCache("news"), handlers.GetPublicNews)
+		public.GET("/news/:id", middleware.PublicCache("news"), handlers.GetPublicNewsByID)
+		public.GET("/news/slug/:slug", middleware.PublicCache("news"), handlers.GetPublicNewsBySlug)
+		public.GET("/events", middleware.PublicCache("events"), handlers.GetPublicEvents)
+		public.GET("/events/:id", middleware.PublicCache("events"), handlers.GetPublicEventByID)
+		public.GET("/events/slug/:slug", middleware.PublicCache("events"), handlers.GetPublicEventBySlug)
+		public.GET("/rooms", middleware.PublicCache("rooms"), handlers.GetPublicRooms)
+		public.GET("/rooms/:id", middleware.PublicCache("rooms", "mixes"), handlers.GetPublicRoomByID)
+		public.GET("/rooms/slug/:slug", middleware.PublicCache("rooms", "mixes"), handlers.GetPublicRoomBySlug)
+		public.GET("/mixes", middleware.PublicCache("mixes", "rooms"), handlers.GetPublicMixes)
+		public.GET("/mixes/:id", middleware.PublicCache("mixes", "rooms"), handlers.GetPublicMixByID)
+		public.GET("/mixes/slug/:slug", middleware.PublicCache("mixes", "rooms"), handlers.GetPublicMixBySlug)
+		public.GET("/merch", middleware.PublicCache("merchandise"), handlers.GetPublicMerch)
+		public.GET("/merch/:id", middleware.PublicCache("merchandise"), handlers.GetPublicMerchByID)
+		public.GET("/careers", middleware.PublicCache("careers"), handlers.GetPublicCareers)
+		public.GET("/careers/:id", middleware.PublicCache("careers"), handlers.GetPublicCareerByID)
+	}
+
+	// Search - No authentication, only published/active content is indexed as visible
+	r.GET("/api/v1/search", handlers.Search)
+
+	// Account routes - Any signed-in account, listeners included
+	account := r.Group("/api/v1")
+	account.Use(middleware.RequireAuth())
+	{
+		account.GET("/account/profile", handlers.GetProfile)
+		account.PUT("/account/profile", handlers.UpdateProfile)
+		account.PATCH("/account/profile", handlers.PatchProfile)
+
+		// Shopping Cart routes - carts belong to a signed-in user
+		account.GET("/cart", middleware.RequireUser(), handlers.GetCart)
+		account.POST("/cart/add", middleware.RequireUser(), handlers.AddToCart)
+		account.PUT("/cart/update", middleware.RequireUser(), handlers.UpdateCartItem)
+		account.DELETE("/cart/remove", middleware.RequireUser(), handlers.RemoveFromCart)
+		account.DELETE("/cart/clear", middleware.RequireUser(), handlers.ClearCart)
+
+		// Checkout and Orders routes - orders.read sees every order, others only their own
+		account.POST("/checkout", middleware.RequireUser(), handlers.CreateOrder)
+		account.GET("/orders", handlers.GetOrders)
+		account.GET("/orders/:id", handlers.GetOrder)
+	}
+
+	// Protected API routes - Staff only, each requires the permission named on the route
+	// Content reads answer conditional requests with 304 (see middleware.Cache)
 	protected := r.Group("/api/v1")
-	protected.Use(middleware.RequireAuth())
+	protected.Use(middleware.RequireAuth(), middleware.RequireStaff(), middleware.Audit())
 	{
 		// Admin API routes (protected)
-		protected.GET("/admin/dashboard", handlers.GetAdminDashboard)
+		protected.GET("/admin/dashboard", middleware.RequirePermission("admin.dashboard"), handlers.GetAdminDashboard)
 
 		// News routes - All protected
-		protected.GET("/news", handlers.GetNews)
-		protected.GET("/news/:id", handlers.GetNewsByID)
-		protected.POST("/news", handlers.CreateNews)
-		protected.PUT("/news/:id", handlers.UpdateNews)
-		protected.DELETE("/news/:id", handlers.DeleteNews)
+		protected.GET("/news", middleware.RequirePermission("news.read"), middleware.StaffCache("news"), handlers.GetNews)
+		protected.GET("/news/:id", middleware.RequirePermission("news.read"), middleware.StaffCache("news"), handlers.GetNewsByID)
+		protected.GET("/news/slug/:slug", middleware.RequirePermission("news.read"), middleware.StaffCache("news"), handlers.GetNewsBySlug)
+		protected.POST("/news", middleware.RequirePermission("news.write"), handlers.CreateNews)
+		protected.PUT("/news/:id", middleware.RequirePermission("news.write"), handlers.UpdateNews)
+		protected.PATCH("/news/:id", middleware.RequirePermission("news.write"), handlers.PatchNews)
+		protected.DELETE("/news/:id", middleware.RequirePermission("news.delete"), handlers.DeleteNews)
 
 		// Events routes - All protected
-		protected.GET("/events", handlers.GetEvents)
-		protected.GET("/events/:id", handlers.GetEventByID)
-		protected.POST("/events", handlers.CreateEvent)
-		protected.PUT("/events/:id", handlers.UpdateEvent)
-		protected.DELETE("/events/:id", handlers.DeleteEvent)
+		protected.GET("/events", middleware.RequirePermission("events.read"), middleware.StaffCache("events"), handlers.GetEvents)
+		protected.GET("/events/:id", middleware.RequirePermission("events.read"), middleware.StaffCache("events"), handlers.GetEventByID)
+		protected.GET("/events/slug/:slug", middleware.RequirePermission("events.read"), middleware.StaffCache("events"), handlers.GetEventBySlug)
+		protected.POST("/events", middleware.RequirePermission("events.write"), handlers.CreateEvent)
+		protected.PUT("/events/:id", middleware.RequirePermission("events.write"), handlers.UpdateEvent)
+		protected.PATCH("/events/:id", middleware.RequirePermission("events.write"), handlers.PatchEvent)
+		protected.DELETE("/events/:id", middleware.RequirePermission("events.delete"), handlers.DeleteEvent)
 
 		// Merchandise routes - All protected
-		protected.GET("/merch", handlers.GetMerch)
-		protected.GET("/merch/:id", handlers.GetMerchByID)
-		protected.POST("/merch", handlers.CreateMerch)
-		protected.PUT("/merch/:id", handlers.UpdateMerch)
-		protected.DELETE("/merch/:id", handlers.DeleteMerch)
+		protected.GET("/merch", middleware.RequirePermission("merchandise.read"), middleware.StaffCache("merchandise"), handlers.GetMerch)
+		protected.GET("/merch/:id", middleware.RequirePermission("merchandise.read"), middleware.StaffCache("merchandise"), handlers.GetMerchByID)
+		protected.POST("/merch", middleware.RequirePermission("merchandise.write"), handlers.CreateMerch)
+		protected.PUT("/merch/:id", middleware.RequirePermission("merchandise.write"), handlers.UpdateMerch)
+		protected.PATCH("/merch/:id", middleware.RequirePermission("merchandise.write"), handlers.PatchMerch)
+		protected.DELETE("/merch/:id", middleware.RequirePermission("merchandise.delete"), handlers.DeleteMerch)
 
 		// Careers routes - All protected
-		protected.GET("/careers", handlers.GetCareers)
-		protected.GET("/careers/:id", handlers.GetCareerByID)
-		protected.POST("/careers", handlers.CreateCareer)
-		protected.PUT("/careers/:id", handlers.UpdateCareer)
-		protected.DELETE("/careers/:id", handlers.DeleteCareer)
-
-		// Shopping Cart routes - All protected
-		protected.GET("/cart", handlers.GetCart)
-		protected.POST("/cart/add", handlers.AddToCart)
-		protected.PUT("/cart/update", handlers.UpdateCartItem)
-		protected.DELETE("/cart/remove", handlers.RemoveFromCart)
-		protected.DELETE("/cart/clear", handlers.ClearCart)
-
-		// Checkout and Orders routes - All protected
-		protected.POST("/checkout", handlers.CreateOrder)
-		protected.GET("/orders", handlers.GetOrders)
-		protected.GET("/orders/:id", handlers.GetOrder)
-		protected.PUT("/orders/:id/status", handlers.UpdateOrderStatus)
+		protected.GET("/careers", middleware.RequirePermission("careers.read"), middleware.StaffCache("careers"), handlers.GetCareers)
+		protected.GET("/careers/:id", middleware.RequirePermission("careers.read"), middleware.StaffCache("careers"), handlers.GetCareerByID)
+		protected.POST("/careers", middleware.RequirePermission("careers.write"), handlers.CreateCareer)
+		protected.PUT("/careers/:id", middleware.RequirePermission("careers.write"), handlers.UpdateCareer)
+		protected.PATCH("/careers/:id", middleware.RequirePermission("careers.write"), handlers.PatchCareer)
+		protected.DELETE("/careers/:id", middleware.RequirePermission("careers.delete"), handlers.DeleteCareer)
+
+		// Orders routes - All protected
+		protected.PUT("/orders/:id/status", middleware.RequirePermission("orders.write"), handlers.UpdateOrderStatus)
 
 		// Rooms routes - All protected
-		protected.GET("/rooms", handlers.GetRooms)
-		protected.GET("/rooms/:id", handlers.GetRoomByID)
-		protected.POST("/rooms", handlers.CreateRoom)
-		protected.PUT("/rooms/:id", handlers.UpdateRoom)
-		protected.DELETE("/rooms/:id", handlers.DeleteRoom)
+		protected.GET("/rooms", middleware.RequirePermission("rooms.read"), middleware.StaffCache("rooms"), handlers.GetRooms)
+		protected.GET("/rooms/:id", middleware.RequirePermission("rooms.read"), middleware.StaffCache("rooms"), handlers.GetRoomByID)
+		protected.GET("/rooms/slug/:slug", middleware.RequirePermission("rooms.read"), middleware.StaffCache("rooms"), handlers.GetRoomBySlug)
+		protected.POST("/rooms", middleware.RequirePermission("rooms.write"), handlers.CreateRoom)
+		protected.PUT("/rooms/:id", middleware.RequirePermission("rooms.write"), handlers.UpdateRoom)
+		protected.PATCH("/rooms/:id", middleware.RequirePermission("rooms.write"), handlers.PatchRoom)
+		protected.DELETE("/rooms/:id", middleware.RequirePermission("rooms.delete"), handlers.DeleteRoom)
 
 		// Mixes routes - All protected
-		protected.GET("/mixes", handlers.GetMixes)
-		protected.GET("/mixes/:id", handlers.GetMixByID)
-		protected.POST("/mixes", handlers.CreateMix)
-		protected.PUT("/mixes/:id", handlers.UpdateMix)
-		protected.DELETE("/mixes/:id", handlers.DeleteMix)
-		protected.POST("/mixes/:id/tracks", handlers.AddTrackToMix)
-		protected.POST("/mixes/:id/tracks/bulk", handlers.AddTracksToMix)
-		protected.DELETE("/mixes/:id/tracks", handlers.RemoveTrackFromMix)
+		protected.GET("/mixes", middleware.RequirePermission("mixes.read"), middleware.StaffCache("mixes"), handlers.GetMixes)
+		protected.GET("/mixes/:id", middleware.RequirePermission("mixes.read"), middleware.StaffCache("mixes"), handlers.GetMixByID)
+		protected.GET("/mixes/slug/:slug", middleware.RequirePermission("mixes.read"), middleware.StaffCache("mixes"), handlers.GetMixBySlug)
+		protected.POST("/mixes", middleware.RequirePermission("mixes.write"), handlers.CreateMix)
+		protected.PUT("/mixes/:id", middleware.RequirePermission("mixes.write"), handlers.UpdateMix)
+		protected.PATCH("/mixes/:id", middleware.RequirePermission("mixes.write"), handlers.PatchMix)
+		protected.DELETE("/mixes/:id", middleware.RequirePermission("mixes.delete"), handlers.DeleteMix)
+		protected.POST("/mixes/:id/tracks", middleware.RequirePermission("mixes.write"), handlers.AddTrackToMix)
+		protected.POST("/mixes/:id/tracks/bulk", middleware.RequirePermission("mixes.write"), handlers.AddTracksToMix)
+		protected.DELETE("/mixes/:id/tracks", middleware.RequirePermission("mixes.write"), handlers.RemoveTrackFromMix)
 
 		// Upload routes - All protected
-		protected.POST("/upload", handlers.UploadImage)
-		protected.POST("/upload/multiple", handlers.UploadMultipleImages)
+		protected.POST("/upload", middleware.RequirePermission("uploads.write"), handlers.UploadImage)
+		protected.POST("/upload/multiple", middleware.RequirePermission("uploads.write"), handlers.UploadMultipleImages)
 
 		// Users routes - All protected
-		protected.GET("/users", handlers.GetUsers)
-		protected.POST("/users", handlers.CreateUser)
-		protected.GET("/users/:id", handlers.GetUserByID)
-		protected.PUT("/users/:id", handlers.UpdateUser)
-		protected.DELETE("/users/:id", handlers.DeleteUser)
-		protected.PUT("/users/:id/role", handlers.UpdateUserRole)
+		protected.GET("/users", middleware.RequirePermission("users.read"), handlers.GetUsers)
+		protected.POST("/users", middleware.RequirePermission("users.write"), handlers.CreateUser)
+		protected.GET("/users/:id", middleware.RequirePermission("users.read"), handlers.GetUserByID)
+		protected.PUT("/users/:id", middleware.RequirePermission("users.write"), handlers.UpdateUser)
+		protected.PATCH("/users/:id", middleware.RequirePermission("users.write"), handlers.PatchUser)
+		protected.DELETE("/users/:id", middleware.RequirePermission("users.delete"), handlers.DeleteUser)
+		protected.PUT("/users/:id/role", middleware.RequirePermission("users.write"), handlers.UpdateUserRole)
+		protected.POST("/users/:id/unlock", middleware.RequirePermission("users.write"), handlers.UnlockUser)
+		protected.DELETE("/users/:id/sessions", middleware.RequirePermission("users.write"), handlers.RevokeUserSessions)
 
 		// Roles routes - All protected
-		protected.GET("/roles", handlers.GetRoles)
-		protected.POST("/roles", handlers.CreateRole)
-		protected.GET("/roles/:id", handlers.GetRoleByID)
-		protected.PUT("/roles/:id", handlers.UpdateRole)
-		protected.DELETE("/roles/:id", handlers.DeleteRole)
-	}
+		protected.GET("/roles", middleware.RequirePermission("roles.read"), handlers.GetRoles)
+		protected.POST("/roles", middleware.RequirePermission("roles.write"), handlers.CreateRole)
+		protected.GET("/roles/:id", middleware.RequirePermission("roles.read"), handlers.GetRoleByID)
+		protected.PUT("/roles/:id", middleware.RequirePermission("roles.write"), handlers.UpdateRole)
+		protected.PATCH("/roles/:id", middleware.RequirePermission("roles.write"), handlers.PatchRole)
+		protected.DELETE("/roles/:id", middleware.RequirePermission("roles.delete"), handlers.DeleteRole)
 
-	// Get port from environment or use default
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+		// API keys for machine clients - All protected
+		protected.GET("/api-keys", middleware.RequirePermission("apikeys.read"), handlers.GetAPIKeys)
+		protected.POST("/api-keys", middleware.RequirePermission("apikeys.write"), handlers.CreateAPIKey)
+		protected.DELETE("/api-keys/:id", middleware.RequirePermission("apikeys.delete"), handlers.RevokeAPIKey)
+
+		// Audit log
+		protected.GET("/audit", middleware.RequirePermission("audit.read"), handlers.GetAuditLogs)
 	}
 
-	// Bind to 0.0.0.0 to accept connections from all interfaces (required for Railway)
-	bindAddr := "0.0.0.0:" + port
-	
-	fmt.Printf("🚀 Server starting on %s\n", bindAddr)
-	fmt.Printf("✅ Health check available at http://%s/health\n", bindAddr)
-	fmt.Printf("📊 Database connected: %v\n", database.DB != nil)
+	// Unknown routes get the same error envelope as everything else
+	r.NoRoute(func(c *gin.Context) {
+		apierr.NotFound(c, "Route not found")
+	})
 
-	if err := r.Run(bindAddr); err != nil {
-		fmt.Printf("FATAL: Server failed to start: %v\n", err)
-		os.Exit(1)
-	}
+	return r
 }
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"playtz-api/handlers"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Every route registered in setupRouter must have an entry in apiOperations (handlers/openapi.go)
+func TestEveryRouteIsDocumented(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	_, undocumented := handlers.BuildOpenAPI(setupRouter().Routes())
+	for _, route := range undocumented {
+		t.Errorf("route missing from apiOperations in handlers/openapi.go: %s", route)
+	}
+}
@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"playtz-api/audit"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// auditVerbs names the action of a mutating request on a resource
+var auditVerbs = map[string]string{
+	"POST":   "create",
+	"PUT":    "update",
+	"PATCH":  "update",
+	"DELETE": "delete",
+}
+
+// auditWriter keeps a copy of the response body so the ID of a created resource can be read
+type auditWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *auditWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *auditWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Audit records every mutating request with its actor, the resource it targets and the
+// fields it changed. Must run after RequireAuth.
+func Audit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		verb, mutating := auditVerbs[c.Request.Method]
+		if !mutating {
+			c.Next()
+			return
+		}
+
+		route := c.FullPath()
+		resourceType, action := auditAction(route, c.Request.Method, verb)
+		resourceID := c.Param("id")
+		before := audit.Snapshot(resourceType, resourceID)
+
+		// Creates carry the new ID in the response body
+		var writer *auditWriter
+		if resourceID == "" {
+			writer = &auditWriter{ResponseWriter: c.Writer}
+			c.Writer = writer
+		}
+
+		c.Next()
+
+		status := c.Writer.Status()
+		if writer != nil && status < 300 {
+			resourceID = createdResourceID(writer.body.Bytes())
+		}
+
+		entry := audit.Entry{
+			Action:       action,
+			Method:       c.Request.Method,
+			Route:        route,
+			ResourceType: resourceType,
+			ResourceID:   resourceID,
+			StatusCode:   status,
+			IP:           c.ClientIP(),
+			UserAgent:    c.Request.UserAgent(),
+		}
+		if status < 400 {
+			entry.Before, entry.After = audit.Diff(before, audit.Snapshot(resourceType, resourceID))
+		}
+		if userID := c.GetString("user_id"); userID != "" {
+			entry.ActorID, entry.ActorType, entry.ActorName = userID, "user", c.GetString("username")
+		} else if keyID := c.GetString("api_key_id"); keyID != "" {
+			entry.ActorID, entry.ActorType, entry.ActorName = keyID, "api_key", c.GetString("api_key_name")
+		}
+
+		audit.Record(entry)
+	}
+}
+
+// auditAction derives the resource type and action name from a route pattern, e.g.
+//
+//	DELETE /api/v1/news/:id          -> news, news.delete
+//	PUT    /api/v1/orders/:id/status -> orders, orders.status.update
+//	POST   /api/v1/users/:id/unlock  -> users, users.unlock
+func auditAction(route, method, verb string) (string, string) {
+	var resourceType string
+	var parts []string
+	hasID := false
+	for _, segment := range strings.Split(strings.TrimPrefix(route, "/api/v1/"), "/") {
+		switch {
+		case segment == "":
+		case strings.HasPrefix(segment, ":"):
+			hasID = true
+		case resourceType == "":
+			resourceType = segment
+			parts = append(parts, segment)
+		default:
+			parts = append(parts, segment)
+		}
+	}
+
+	// POST on a sub-path of an existing resource is a named action (unlock, tracks...)
+	if !(method == "POST" && hasID && len(parts) > 1) {
+		parts = append(parts, verb)
+	}
+	return resourceType, strings.Join(parts, ".")
+}
+
+// createdResourceID reads the ID from a create response ({"id": ...} or {"user": {"id": ...}})
+func createdResourceID(body []byte) string {
+	var response struct {
+		ID   string `json:"id"`
+		User struct {
+			ID string `json:"id"`
+		} `json:"user"`
+	}
+	if json.Unmarshal(body, &response) != nil {
+		return ""
+	}
+	if response.ID != "" {
+		return response.ID
+	}
+	return response.User.ID
+}
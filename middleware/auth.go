@@ -1,23 +1,56 @@
 package middleware
 
 import (
+	"log"
+	"playtz-api/apierr"
 	"playtz-api/auth"
+	"playtz-api/models"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-// RequireAuth middleware checks if user is authenticated using JWT tokens
+// twoFactorSetupRoutes are reachable with a token restricted to 2FA enrollment
+var twoFactorSetupRoutes = map[string]bool{
+	"/api/v1/auth/2fa/enroll":      true,
+	"/api/v1/auth/2fa/verify":      true,
+	"/api/v1/auth/change-password": true,
+}
+
+// passwordChangeRoutes are reachable with a token restricted to a forced password change.
+// /auth/me and /auth/logout do not require auth and stay reachable too.
+var passwordChangeRoutes = map[string]bool{
+	"/api/v1/auth/change-password": true,
+}
+
+// apiKeyExcludedPrefixes act on the signed-in user and cannot be called with an API key
+var apiKeyExcludedPrefixes = []string{
+	"/admin/",
+	"/api/v1/admin/",
+	"/api/v1/auth/",
+	"/api/v1/account/",
+	"/api/v1/cart",
+	"/api/v1/checkout",
+}
+
+// RequireAuth middleware checks if user is authenticated using JWT tokens,
+// or a machine client using an X-API-Key header
 func RequireAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Try to get token from cookie
-		token, err := c.Cookie("token")
-		if err != nil || token == "" {
-			// Try Authorization header as fallback
-			authHeader := c.GetHeader("Authorization")
-			token = auth.ExtractTokenFromHeader(authHeader)
-			if token == "" {
-				c.JSON(401, gin.H{"error": "Authentication required"})
-				c.Abort()
+		if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+			authenticateAPIKey(c, apiKey)
+			return
+		}
+
+		// A Bearer header is sent deliberately by the client, so it wins over the cookie,
+		// which browsers also attach to cross-site requests
+		token := auth.ExtractTokenFromHeader(c.GetHeader("Authorization"))
+		fromCookie := false
+		if token == "" {
+			token, _ = c.Cookie("token")
+			fromCookie = token != ""
+			if !fromCookie {
+				apierr.Unauthorized(c, "Authentication required")
 				return
 			}
 		}
@@ -25,8 +58,35 @@ func RequireAuth() gin.HandlerFunc {
 		// Validate JWT token
 		claims, err := auth.ValidateToken(token)
 		if err != nil {
-			c.JSON(401, gin.H{"error": "Invalid or expired token"})
-			c.Abort()
+			apierr.Unauthorized(c, "Invalid or expired token")
+			return
+		}
+
+		// Reject tokens revoked by logout, password change or deactivation
+		revoked, err := auth.IsTokenRevoked(claims)
+		if err != nil {
+			apierr.Fail(c, "Failed to verify token", err)
+			return
+		}
+		if revoked {
+			apierr.Unauthorized(c, "Token has been revoked")
+			return
+		}
+
+		// Browsers attach the cookie to cross-site requests; require proof the request came from our frontend
+		if fromCookie && !CheckCSRF(c) {
+			return
+		}
+
+		// Users created with a generated password must change it before anything else
+		if claims.PasswordChangeRequired && !passwordChangeRoutes[c.FullPath()] {
+			apierr.Respond(c, apierr.New(403, apierr.CodePasswordChangeRequired, "Password change required").With("password_change_required", true))
+			return
+		}
+
+		// Users whose role requires 2FA may only enroll until they have set it up
+		if claims.TwoFactorSetupRequired && !twoFactorSetupRoutes[c.FullPath()] {
+			apierr.Respond(c, apierr.New(403, apierr.CodeTwoFactorSetupRequired, "Two-factor authentication setup required").With("two_factor_setup_required", true))
 			return
 		}
 
@@ -36,8 +96,87 @@ func RequireAuth() gin.HandlerFunc {
 		c.Set("email", claims.Email)
 		c.Set("role_id", claims.RoleID)
 		c.Set("role_name", claims.RoleName)
+		c.Set("account_type", claims.AccountType)
+		c.Set("session_id", claims.SessionID)
 		c.Set("claims", claims)
 
+		if claims.SessionID != "" {
+			if err := auth.TouchSession(claims.SessionID, c.ClientIP()); err != nil {
+				log.Printf("Failed to update session %s: %v", claims.SessionID, err)
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// authenticateAPIKey validates an API key and stores its scopes in the context.
+// Scopes are limited to what the key's owner currently holds, so a key loses a permission
+// as soon as its owner does; a key whose owner is gone or deactivated has none.
+func authenticateAPIKey(c *gin.Context, key string) {
+	for _, prefix := range apiKeyExcludedPrefixes {
+		if strings.HasPrefix(c.FullPath(), prefix) {
+			apierr.Forbidden(c, "API keys cannot be used on this endpoint")
+			return
+		}
+	}
+
+	apiKey, err := auth.ValidateAPIKey(key)
+	if err == auth.ErrAPIKeyInvalid {
+		apierr.Unauthorized(c, "Invalid or expired API key")
+		return
+	}
+	if err != nil {
+		apierr.Fail(c, "Failed to verify API key", err)
+		return
+	}
+
+	ownerPermissions := map[string]bool{}
+	if apiKey.OwnerID != "" {
+		ownerPermissions, err = auth.GetPermissionCache().Permissions(apiKey.OwnerID)
+		if err != nil {
+			apierr.Fail(c, "Failed to verify API key", err)
+			return
+		}
+	}
+
+	scopes := make(map[string]bool, len(apiKey.Scopes))
+	for _, scope := range apiKey.Scopes {
+		if ownerPermissions[scope] {
+			scopes[scope] = true
+		}
+	}
+
+	c.Set("api_key_id", apiKey.ID)
+	c.Set("api_key_name", apiKey.Name)
+	c.Set("api_key_owner_id", apiKey.OwnerID)
+	c.Set("api_key_scopes", scopes)
+
+	c.Next()
+}
+
+// RequireStaff middleware rejects listener accounts, whatever their role.
+// Must run after RequireAuth; API keys are machine clients and pass.
+func RequireStaff() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("account_type") == models.AccountTypeListener {
+			apierr.Forbidden(c, "Staff account required")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireUser middleware rejects callers that are not a signed-in user, such as API keys.
+// Must run after RequireAuth; routes acting on the caller's own data use it.
+func RequireUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("user_id") == "" {
+			apierr.Forbidden(c, "A signed-in user is required")
+			return
+		}
+
 		c.Next()
 	}
 }
@@ -47,8 +186,7 @@ func RequireRole(roleNames ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		roleName, exists := c.Get("role_name")
 		if !exists {
-			c.JSON(403, gin.H{"error": "Access denied"})
-			c.Abort()
+			apierr.Forbidden(c, "Access denied")
 			return
 		}
 
@@ -62,8 +200,7 @@ func RequireRole(roleNames ...string) gin.HandlerFunc {
 		}
 
 		if !hasRole {
-			c.JSON(403, gin.H{"error": "Insufficient permissions"})
-			c.Abort()
+			apierr.Forbidden(c, "Insufficient permissions")
 			return
 		}
 
@@ -74,9 +211,52 @@ func RequireRole(roleNames ...string) gin.HandlerFunc {
 // RequirePermission middleware checks if user has required permission
 func RequirePermission(permission string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// This would require fetching role permissions from database
-		// For now, we'll use role-based access
+		_, isUser := c.Get("user_id")
+		_, isAPIKey := c.Get("api_key_id")
+		if !isUser && !isAPIKey {
+			apierr.Forbidden(c, "Access denied")
+			return
+		}
+
+		permissions, err := CallerPermissions(c)
+		if err != nil {
+			apierr.Fail(c, "Failed to load permissions", err)
+			return
+		}
+
+		if !permissions[permission] {
+			apierr.MissingPermission(c, permission)
+			return
+		}
+
 		c.Next()
 	}
 }
 
+// CallerPermissions returns the permissions of the authenticated caller:
+// the scopes of an API key, or the permissions of the user's role (cached per user)
+func CallerPermissions(c *gin.Context) (map[string]bool, error) {
+	if scopes, exists := c.Get("api_key_scopes"); exists {
+		return scopes.(map[string]bool), nil
+	}
+
+	userID := c.GetString("user_id")
+	if userID == "" {
+		return map[string]bool{}, nil
+	}
+	return auth.GetPermissionCache().Permissions(userID)
+}
+
+// HasPermission reports whether the authenticated caller holds a permission.
+// Listener accounts hold none, whatever their role.
+func HasPermission(c *gin.Context, permission string) bool {
+	if c.GetString("account_type") == models.AccountTypeListener {
+		return false
+	}
+
+	permissions, err := CallerPermissions(c)
+	if err != nil {
+		return false
+	}
+	return permissions[permission]
+}
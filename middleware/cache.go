@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"playtz-api/database"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CachePolicy describes how a GET route is cached
+type CachePolicy struct {
+	// Tables hold the data the response is built from. On /:id routes the first table is
+	// the resource itself and its row's updated_at is used; every other table contributes
+	// its row count and latest updated_at, so creates, updates and deletes all change the validators.
+	Tables []string
+	// MaxAge lets clients reuse a response without revalidating; 0 means revalidate every time
+	MaxAge time.Duration
+	// Public allows shared caches (CDNs, proxies) to store the response. Only for unauthenticated routes.
+	Public bool
+}
+
+// StaffCache revalidates on every request, so staff see edits immediately while unchanged
+// data costs only a 304
+func StaffCache(tables ...string) gin.HandlerFunc {
+	return Cache(CachePolicy{Tables: tables})
+}
+
+// PublicCache lets browsers and CDNs reuse public responses for a minute
+func PublicCache(tables ...string) gin.HandlerFunc {
+	return Cache(CachePolicy{Tables: tables, MaxAge: time.Minute, Public: true})
+}
+
+// Cache answers conditional GET requests: it sets ETag, Last-Modified and Cache-Control from
+// the updated_at columns of the policy's tables and responds 304 Not Modified without running
+// the handler when If-None-Match or If-Modified-Since show the client's copy is current.
+// Must run after authentication and permission checks.
+func Cache(policy CachePolicy) gin.HandlerFunc {
+	cacheControl := "private"
+	if policy.Public {
+		cacheControl = "public"
+	}
+	if policy.MaxAge > 0 {
+		cacheControl += fmt.Sprintf(", max-age=%d", int(policy.MaxAge.Seconds()))
+	} else {
+		cacheControl += ", no-cache"
+	}
+
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.Next()
+			return
+		}
+
+		id := c.Param("id")
+		version, lastModified, err := resourceVersion(policy.Tables, id)
+		if err == sql.ErrNoRows {
+			c.Next() // Let the handler return its 404
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to compute cache validators for %s: %v", c.FullPath(), err)
+			c.Next()
+			return
+		}
+
+		// The same data looks different per route and per filter/page
+		sum := sha256.Sum256([]byte(version + "|" + c.Request.URL.Path + "?" + c.Request.URL.RawQuery))
+		etag := `W/"` + hex.EncodeToString(sum[:12]) + `"`
+
+		c.Header("ETag", etag)
+		c.Header("Cache-Control", cacheControl)
+		if !lastModified.IsZero() {
+			c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+		}
+
+		// A delete does not move MAX(updated_at), so only single rows are trusted with If-Modified-Since
+		if !(id != "" && len(policy.Tables) == 1) {
+			lastModified = time.Time{}
+		}
+		if notModified(c.Request, etag, lastModified) {
+			c.AbortWithStatus(http.StatusNotModified)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// resourceVersion summarizes the state of the given tables. With an id, the first table is
+// read as that single row (sql.ErrNoRows if it does not exist).
+func resourceVersion(tables []string, id string) (string, time.Time, error) {
+	var parts []string
+	var lastModified time.Time
+
+	for i, table := range tables {
+		// Table names come from the route policies, never from the request
+		var count int64
+		var updatedAt sql.NullTime
+		var err error
+		if i == 0 && id != "" {
+			count = 1
+			err = database.DB.QueryRow("SELECT updated_at FROM "+table+" WHERE id = $1", id).Scan(&updatedAt)
+		} else {
+			err = database.DB.QueryRow("SELECT COUNT(*), MAX(updated_at) FROM "+table).Scan(&count, &updatedAt)
+		}
+		if err != nil {
+			return "", time.Time{}, err
+		}
+
+		parts = append(parts, fmt.Sprintf("%s:%d:%d", table, count, updatedAt.Time.UnixNano()))
+		if updatedAt.Valid && updatedAt.Time.After(lastModified) {
+			lastModified = updatedAt.Time
+		}
+	}
+
+	return strings.Join(parts, ","), lastModified, nil
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since when no ETag was sent (RFC 7232)
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if header := r.Header.Get("If-None-Match"); header != "" {
+		for _, candidate := range strings.Split(header, ",") {
+			candidate = strings.TrimSpace(candidate)
+			// Weak comparison: W/"x" matches "x"
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	if header := r.Header.Get("If-Modified-Since"); header != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(header)
+		return err == nil && !lastModified.Truncate(time.Second).After(since)
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"os"
+	"strings"
+)
+
+// defaultOrigins allows localhost for development and the Vercel admin frontend
+const defaultOrigins = "http://localhost:3000,http://localhost:3001,http://localhost:5173,http://localhost:8080,https://playtzadmin.vercel.app"
+
+// AllowedOrigins returns the frontend origins from CORS_ORIGINS (comma separated) or the defaults
+func AllowedOrigins() []string {
+	allowedOrigins := os.Getenv("CORS_ORIGINS")
+	if allowedOrigins == "" {
+		allowedOrigins = defaultOrigins
+	}
+
+	origins := []string{}
+	for _, origin := range strings.Split(allowedOrigins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// IsAllowedOrigin reports whether origin (scheme://host[:port]) is one of the allowed origins
+func IsAllowedOrigin(origin string) bool {
+	for _, allowed := range AllowedOrigins() {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
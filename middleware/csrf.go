@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/url"
+	"playtz-api/apierr"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// CSRFCookieName holds the CSRF token issued by GET /auth/csrf
+	CSRFCookieName = "csrf_token"
+	// CSRFHeaderName must echo the CSRF cookie on unsafe cookie-authenticated requests
+	CSRFHeaderName = "X-CSRF-Token"
+)
+
+// csrfSafeMethods never change state and are not checked
+var csrfSafeMethods = map[string]bool{
+	"GET":     true,
+	"HEAD":    true,
+	"OPTIONS": true,
+}
+
+// CheckCSRF enforces double-submit CSRF protection on a request authenticated by a cookie
+// (the token cookie, or the refresh_token cookie on /auth/refresh and /auth/logout): the Origin
+// (when sent) must be allowed and the X-CSRF-Token header must match the csrf_token cookie.
+// Aborts the request and returns false when the check fails.
+func CheckCSRF(c *gin.Context) bool {
+	if csrfSafeMethods[c.Request.Method] {
+		return true
+	}
+
+	if origin := c.GetHeader("Origin"); origin != "" && !csrfOriginAllowed(origin, c.Request.Host) {
+		apierr.Respond(c, apierr.New(403, apierr.CodeCSRFFailed, "Cross-origin request blocked").With("csrf_failed", true))
+		return false
+	}
+
+	cookie, err := c.Cookie(CSRFCookieName)
+	header := c.GetHeader(CSRFHeaderName)
+	if err != nil || cookie == "" || subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) != 1 {
+		apierr.Respond(c, apierr.New(403, apierr.CodeCSRFFailed, "Invalid or missing CSRF token").With("csrf_failed", true))
+		return false
+	}
+
+	return true
+}
+
+// csrfOriginAllowed accepts the CORS allow list and the API's own host (the admin pages it serves)
+func csrfOriginAllowed(origin, host string) bool {
+	if IsAllowedOrigin(origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host != "" && strings.EqualFold(u.Host, host)
+}
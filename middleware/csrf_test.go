@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestIsAllowedOrigin(t *testing.T) {
+	t.Setenv("CORS_ORIGINS", "https://playtz.com/, http://localhost:3000")
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://playtz.com", true},
+		{"HTTPS://PLAYTZ.COM", true},
+		{"http://localhost:3000", true},
+		{"http://playtz.com", false},
+		{"https://playtz.com.evil.example", false},
+		{"http://localhost:3001", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsAllowedOrigin(tt.origin); got != tt.want {
+			t.Errorf("IsAllowedOrigin(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+}
+
+func TestIsAllowedOriginDefaults(t *testing.T) {
+	t.Setenv("CORS_ORIGINS", "")
+
+	if !IsAllowedOrigin("https://playtzadmin.vercel.app") {
+		t.Error("default origins should include the admin frontend")
+	}
+	if IsAllowedOrigin("https://evil.example") {
+		t.Error("default origins should not include other sites")
+	}
+}
+
+func TestCheckCSRF(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("CORS_ORIGINS", "https://playtz.com")
+
+	tests := []struct {
+		name   string
+		method string
+		origin string
+		cookie string
+		header string
+		want   bool
+	}{
+		{name: "safe method", method: "GET", want: true},
+		{name: "matching token", method: "POST", cookie: "abc", header: "abc", want: true},
+		{name: "matching token from allowed origin", method: "POST", origin: "https://playtz.com", cookie: "abc", header: "abc", want: true},
+		{name: "matching token from the API's own host", method: "POST", origin: "https://api.playtz.com", cookie: "abc", header: "abc", want: true},
+		{name: "missing header", method: "POST", cookie: "abc", want: false},
+		{name: "missing cookie", method: "POST", header: "abc", want: false},
+		{name: "wrong header", method: "DELETE", cookie: "abc", header: "abd", want: false},
+		{name: "foreign origin", method: "POST", origin: "https://evil.example", cookie: "abc", header: "abc", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(tt.method, "https://api.playtz.com/api/v1/auth/refresh", nil)
+			if tt.origin != "" {
+				c.Request.Header.Set("Origin", tt.origin)
+			}
+			if tt.cookie != "" {
+				c.Request.AddCookie(&http.Cookie{Name: CSRFCookieName, Value: tt.cookie})
+			}
+			if tt.header != "" {
+				c.Request.Header.Set(CSRFHeaderName, tt.header)
+			}
+
+			if got := CheckCSRF(c); got != tt.want {
+				t.Fatalf("CheckCSRF = %v, want %v", got, tt.want)
+			}
+			if !tt.want && (w.Code != 403 || !c.IsAborted()) {
+				t.Errorf("rejected request answered %d, aborted %v; want 403 and aborted", w.Code, c.IsAborted())
+			}
+		})
+	}
+}
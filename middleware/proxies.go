@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"os"
+	"strings"
+)
+
+// TrustedProxies returns the proxies whose X-Forwarded-For header c.ClientIP() believes, from
+// TRUSTED_PROXIES (comma separated IPs or CIDRs, or "none" when clients connect directly).
+// None are trusted when it is unset, so a client cannot choose the IP that login limits and
+// lockouts are keyed by.
+func TrustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" && proxy != "none" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+// ClientIPKnown reports whether TRUSTED_PROXIES is configured. Until it is, the API may be
+// behind a proxy whose address c.ClientIP() returns for every client.
+func ClientIPKnown() bool {
+	return strings.TrimSpace(os.Getenv("TRUSTED_PROXIES")) != ""
+}
@@ -0,0 +1,10 @@
+package models
+
+// Account types stored in users.account_type
+const (
+	AccountTypeStaff    = "staff"    // Station staff, managed through /users
+	AccountTypeListener = "listener" // Self-registered listeners and shoppers
+)
+
+// ListenerRoleName is the role given to self-registered listeners. It has no permissions.
+const ListenerRoleName = "listener"
@@ -0,0 +1,29 @@
+package models
+
+// AllPermissions lists every permission string understood by the API.
+// The admin role is seeded with all of them.
+var AllPermissions = []string{
+	"users.read", "users.write", "users.delete",
+	"roles.read", "roles.write", "roles.delete",
+	"news.read", "news.write", "news.delete",
+	"events.read", "events.write", "events.delete",
+	"mixes.read", "mixes.write", "mixes.delete",
+	"merchandise.read", "merchandise.write", "merchandise.delete",
+	"orders.read", "orders.write", "orders.delete",
+	"careers.read", "careers.write", "careers.delete",
+	"rooms.read", "rooms.write", "rooms.delete",
+	"apikeys.read", "apikeys.write", "apikeys.delete",
+	"uploads.write",
+	"audit.read",
+	"admin.dashboard", "admin.settings",
+}
+
+// IsValidPermission reports whether permission is part of AllPermissions
+func IsValidPermission(permission string) bool {
+	for _, p := range AllPermissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
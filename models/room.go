@@ -3,12 +3,13 @@ package models
 // Room represents a music room/genre
 type Room struct {
 	ID          string `json:"id"`
-	Name        string `json:"name"`
-	Genre       string `json:"genre"`
+	Slug        string `json:"slug"` // Generated from the name when empty
+	Name        string `json:"name" binding:"required,notblank,max=255"`
+	Genre       string `json:"genre" binding:"max=100"`
 	Description string `json:"description"`
-	Gradient    string `json:"gradient"`
-	TextColor   string `json:"text_color"`
-	Image       string `json:"image,omitempty"`
+	Gradient    string `json:"gradient" binding:"max=100"`
+	TextColor   string `json:"text_color" binding:"max=50"`
+	Image       string `json:"image,omitempty" binding:"omitempty,url"`
 	Active      bool   `json:"active"`
 	CreatedAt   string `json:"created_at,omitempty"`
 	UpdatedAt   string `json:"updated_at,omitempty"`
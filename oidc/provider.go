@@ -0,0 +1,314 @@
+package oidc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Config describes the OpenID provider and this API as its client
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string // Callback URL registered with the provider (.../api/v1/auth/oidc/callback)
+	Scopes       []string
+	GroupsClaim  string // Claim holding group names (default "groups")
+	TrustEmail   bool   // Treat email as verified when the provider omits email_verified (e.g. Microsoft Entra)
+}
+
+// Identity is the verified result of an SSO login
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	EmailAssumed  bool // EmailVerified only because of TrustEmail, not a claim from the provider
+	GivenName     string
+	FamilyName    string
+	Groups        []string
+}
+
+// discoveryDocument is the subset of /.well-known/openid-configuration we use
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider talks to one OpenID Connect provider
+type Provider struct {
+	config Config
+	client *http.Client
+
+	mutex       sync.Mutex
+	discovery   *discoveryDocument
+	keys        map[string]interface{}
+	keysFetched time.Time
+}
+
+// keysMinRefresh limits how often an unknown kid triggers a JWKS refetch
+const keysMinRefresh = 1 * time.Minute
+
+var provider *Provider
+var providerOnce sync.Once
+
+// Get returns the provider configured from OIDC_* environment variables, or nil when
+// OIDC_ISSUER is not set (single sign-on disabled)
+func Get() *Provider {
+	providerOnce.Do(func() {
+		issuer := strings.TrimSuffix(os.Getenv("OIDC_ISSUER"), "/")
+		if issuer == "" {
+			return
+		}
+
+		scopes := strings.Fields(os.Getenv("OIDC_SCOPES"))
+		if len(scopes) == 0 {
+			scopes = []string{"openid", "email", "profile"}
+		}
+		groupsClaim := os.Getenv("OIDC_GROUPS_CLAIM")
+		if groupsClaim == "" {
+			groupsClaim = "groups"
+		}
+		trustEmail, _ := strconv.ParseBool(os.Getenv("OIDC_ASSUME_EMAIL_VERIFIED"))
+
+		provider = NewProvider(Config{
+			Issuer:       issuer,
+			ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+			Scopes:       scopes,
+			GroupsClaim:  groupsClaim,
+			TrustEmail:   trustEmail,
+		})
+	})
+	return provider
+}
+
+// NewProvider creates a provider; discovery happens on first use
+func NewProvider(config Config) *Provider {
+	return &Provider{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// AuthCodeURL returns the provider URL that starts an authorization-code + PKCE login
+func (p *Provider) AuthCodeURL(state, nonce, codeChallenge, loginHint string) (string, error) {
+	doc, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+	if loginHint != "" {
+		params.Set("login_hint", loginHint)
+	}
+
+	separator := "?"
+	if strings.Contains(doc.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return doc.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange redeems an authorization code and returns the raw ID token
+func (p *Provider) Exchange(code, codeVerifier string) (string, error) {
+	doc, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := p.client.PostForm(doc.TokenEndpoint, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"client_id":     {p.config.ClientID},
+		"client_secret": {p.config.ClientSecret},
+		"code_verifier": {codeVerifier},
+	})
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint returned %d: %s", resp.StatusCode, body)
+	}
+
+	var token struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", fmt.Errorf("invalid token response: %w", err)
+	}
+	if token.IDToken == "" {
+		return "", errors.New("token response has no id_token")
+	}
+	return token.IDToken, nil
+}
+
+// VerifyIDToken checks the ID token signature, issuer, audience, expiry and nonce
+func (p *Provider) VerifyIDToken(rawIDToken, nonce string) (*Identity, error) {
+	doc, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(rawIDToken, claims, p.keyFunc,
+		jwt.WithIssuer(doc.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "ES256", "ES384", "ES512", "EdDSA"}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+
+	if claimString(claims, "nonce") != nonce {
+		return nil, errors.New("id_token nonce mismatch")
+	}
+	// With several audiences the token must have been issued to us
+	if aud, _ := claims.GetAudience(); len(aud) > 1 && claimString(claims, "azp") != p.config.ClientID {
+		return nil, errors.New("id_token authorized party mismatch")
+	}
+
+	identity := &Identity{
+		Issuer:     doc.Issuer,
+		Subject:    claimString(claims, "sub"),
+		Email:      strings.ToLower(claimString(claims, "email")),
+		GivenName:  claimString(claims, "given_name"),
+		FamilyName: claimString(claims, "family_name"),
+		Groups:     claimStrings(claims, p.config.GroupsClaim),
+	}
+	if identity.Subject == "" {
+		return nil, errors.New("id_token has no subject")
+	}
+
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = verified == "true"
+	case nil:
+		identity.EmailVerified = p.config.TrustEmail && identity.Email != ""
+		identity.EmailAssumed = identity.EmailVerified
+	}
+
+	return identity, nil
+}
+
+// discover fetches and caches the provider's discovery document
+func (p *Provider) discover() (*discoveryDocument, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var doc discoveryDocument
+	if err := p.getJSON(p.config.Issuer+"/.well-known/openid-configuration", &doc); err != nil {
+		return nil, fmt.Errorf("OIDC discovery failed: %w", err)
+	}
+	if strings.TrimSuffix(doc.Issuer, "/") != p.config.Issuer {
+		return nil, fmt.Errorf("OIDC discovery issuer %q does not match %q", doc.Issuer, p.config.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, errors.New("OIDC discovery document is incomplete")
+	}
+
+	p.discovery = &doc
+	return p.discovery, nil
+}
+
+// keyFunc returns the provider key named by the token's kid, refetching the JWKS
+// when the kid is unknown (the provider rotated its keys)
+func (p *Provider) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetched) < keysMinRefresh {
+		return nil, errors.New("unknown signing key")
+	}
+
+	var set jwkSet
+	if err := p.getJSON(p.discovery.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("failed to fetch provider keys: %w", err)
+	}
+	p.keys = set.publicKeys()
+	p.keysFetched = time.Now()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, errors.New("unknown signing key")
+}
+
+// getJSON fetches a URL and decodes its JSON body
+func (p *Provider) getJSON(u string, v interface{}) error {
+	resp, err := p.client.Get(u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %d", u, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// claimString returns a string claim or ""
+func claimString(claims jwt.MapClaims, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
+
+// claimStrings returns a claim holding a list of strings (or a single string)
+func claimStrings(claims jwt.MapClaims, name string) []string {
+	switch value := claims[name].(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		values := []string{}
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package oidc
+
+import (
+	"os"
+	"strings"
+)
+
+// roleRule maps an email domain or a group to a role name
+type roleRule struct {
+	kind  string // "domain" or "group"
+	value string
+	role  string
+}
+
+// RoleFor returns the role name for an identity using OIDC_ROLE_MAPPINGS, e.g.
+//
+//	group:station-admins=admin,domain:playtz.com=editor
+//
+// Rules are tried in order and the first match wins. Without a match OIDC_DEFAULT_ROLE
+// is used; "" means the identity is not allowed to sign up.
+func RoleFor(identity *Identity) string {
+	domain := ""
+	if at := strings.LastIndex(identity.Email, "@"); at >= 0 && identity.EmailVerified {
+		domain = strings.ToLower(identity.Email[at+1:])
+	}
+
+	for _, rule := range roleRules() {
+		switch rule.kind {
+		case "domain":
+			if domain != "" && domain == rule.value {
+				return rule.role
+			}
+		case "group":
+			for _, group := range identity.Groups {
+				if group == rule.value {
+					return rule.role
+				}
+			}
+		}
+	}
+
+	return strings.TrimSpace(os.Getenv("OIDC_DEFAULT_ROLE"))
+}
+
+// roleRules parses OIDC_ROLE_MAPPINGS, skipping malformed entries
+func roleRules() []roleRule {
+	rules := []roleRule{}
+	for _, entry := range strings.Split(os.Getenv("OIDC_ROLE_MAPPINGS"), ",") {
+		match, role, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			continue
+		}
+		kind, value, ok := strings.Cut(match, ":")
+		if !ok || (kind != "domain" && kind != "group") || value == "" || role == "" {
+			continue
+		}
+		if kind == "domain" {
+			value = strings.ToLower(value)
+		}
+		rules = append(rules, roleRule{kind: kind, value: value, role: strings.TrimSpace(role)})
+	}
+	return rules
+}
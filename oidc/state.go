@@ -0,0 +1,92 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"playtz-api/database"
+	"time"
+)
+
+// StateTTL is how long a user has to finish logging in at the provider
+const StateTTL = 10 * time.Minute
+
+// StateCookieName is the cookie binding a login state to the browser that started it
+const StateCookieName = "sso_state"
+
+// ErrStateInvalid is returned for unknown, used or expired login states
+var ErrStateInvalid = errors.New("invalid or expired login state")
+
+// LoginState is what the callback needs to finish a login started by this server
+type LoginState struct {
+	Nonce        string
+	CodeVerifier string
+	RedirectTo   string // Frontend URL to return to
+}
+
+// StartLogin creates a single-use state with a nonce and PKCE verifier.
+// It returns the state and the S256 code challenge to send to the provider.
+func StartLogin(redirectTo string) (state string, login *LoginState, codeChallenge string, err error) {
+	if state, err = randomString(); err != nil {
+		return "", nil, "", err
+	}
+	login = &LoginState{RedirectTo: redirectTo}
+	if login.Nonce, err = randomString(); err != nil {
+		return "", nil, "", err
+	}
+	if login.CodeVerifier, err = randomString(); err != nil {
+		return "", nil, "", err
+	}
+
+	_, err = database.DB.Exec(
+		"INSERT INTO oidc_states (state, nonce, code_verifier, redirect_to, expires_at) VALUES ($1, $2, $3, $4, $5)",
+		state, login.Nonce, login.CodeVerifier, login.RedirectTo, time.Now().Add(StateTTL),
+	)
+	if err != nil {
+		return "", nil, "", err
+	}
+
+	sum := sha256.Sum256([]byte(login.CodeVerifier))
+	return state, login, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// ConsumeState deletes a login state and returns it if it was valid
+func ConsumeState(state string) (*LoginState, error) {
+	var login LoginState
+	err := database.DB.QueryRow(
+		"DELETE FROM oidc_states WHERE state = $1 AND expires_at > CURRENT_TIMESTAMP RETURNING nonce, code_verifier, redirect_to",
+		state,
+	).Scan(&login.Nonce, &login.CodeVerifier, &login.RedirectTo)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrStateInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &login, nil
+}
+
+// StateHash is the value of the state cookie: the state itself is only sent to the provider
+func StateHash(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// StateMatches reports whether a state cookie was set for this state
+func StateMatches(state, cookie string) bool {
+	return state != "" && subtle.ConstantTimeCompare([]byte(StateHash(state)), []byte(cookie)) == 1
+}
+
+// randomString returns 32 random bytes, base64url encoded (also a valid PKCE verifier)
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
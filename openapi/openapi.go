@@ -0,0 +1,407 @@
+// Package openapi builds an OpenAPI 3.1 document from the Gin route table and the Go types
+// that handlers bind and return. Every registered route appears in the document; routes
+// without an Operation are marked x-undocumented so scripts/test_openapi.sh fails on them.
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"playtz-api/apierr"
+	"playtz-api/listing"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Version is the OpenAPI version of the generated document
+const Version = "3.1.0"
+
+// Auth is what a route needs to be called
+type Auth int
+
+const (
+	// Public routes need no credentials
+	Public Auth = iota
+	// Optional routes behave differently for signed-in callers
+	Optional
+	// Account routes need any signed-in account, listeners included
+	Account
+	// User routes act on the signed-in user's own data; callers without one, such as API keys, get 403
+	User
+	// Staff routes need a staff account or an API key with the operation's Permission
+	Staff
+)
+
+// Operation documents one route
+type Operation struct {
+	Summary     string
+	Description string
+	Tag         string
+	Auth        Auth
+	// Permission is the permission Staff routes require
+	Permission string
+	// Query lists query parameters; list endpoints get theirs from Listing instead
+	Query []Param
+	// Listing is the filter and sort spec of paginated list endpoints
+	Listing *listing.Spec
+	// Request is a value of the JSON body type, nil for routes without a body
+	Request interface{}
+	// MergePatch marks routes that take a JSON merge patch (RFC 7396) of Request: any of
+	// its fields, null resetting one
+	MergePatch bool
+	// Multipart is the form field of routes that take a multipart/form-data upload instead of JSON
+	Multipart string
+	// MultipleFiles allows several files in the Multipart field
+	MultipleFiles bool
+	// Response is a value of the success response type, nil for an untyped JSON object
+	Response interface{}
+	// Status is the success status, 200 when zero
+	Status int
+	// HTML marks routes that serve a page instead of JSON
+	HTML bool
+}
+
+// Param is a query parameter
+type Param struct {
+	Name        string
+	Description string
+	Required    bool
+}
+
+// Message is the response of routes that only confirm what they did
+type Message struct {
+	Message string `json:"message"`
+}
+
+// Error is the body of every error response (see package apierr)
+type Error struct {
+	Error     string              `json:"error" binding:"required"`
+	Code      string              `json:"code" binding:"required"`
+	Details   []apierr.FieldError `json:"details,omitempty"`
+	RequestID string              `json:"request_id,omitempty"`
+}
+
+// Info describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Document is an OpenAPI document
+type Document struct {
+	OpenAPI    string                        `json:"openapi"`
+	Info       Info                          `json:"info"`
+	Paths      map[string]map[string]*PathOp `json:"paths"`
+	Components Components                    `json:"components"`
+	Tags       []Tag                         `json:"tags,omitempty"`
+}
+
+// Tag groups operations
+type Tag struct {
+	Name string `json:"name"`
+}
+
+// Components holds the schemas and security schemes operations refer to
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+// SecurityScheme is a way of authenticating
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// PathOp is an operation in the document
+type PathOp struct {
+	OperationID  string                `json:"operationId"`
+	Summary      string                `json:"summary,omitempty"`
+	Description  string                `json:"description,omitempty"`
+	Tags         []string              `json:"tags,omitempty"`
+	Parameters   []Parameter           `json:"parameters,omitempty"`
+	RequestBody  *RequestBody          `json:"requestBody,omitempty"`
+	Responses    map[string]Response   `json:"responses"`
+	Security     []map[string][]string `json:"security,omitempty"`
+	Permission   string                `json:"x-permission,omitempty"`
+	Undocumented bool                  `json:"x-undocumented,omitempty"`
+}
+
+// Parameter is a path or query parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody is the body an operation accepts
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response is one possible response of an operation
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType is the schema of a body
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// securitySchemes are the credentials RequireAuth accepts
+var securitySchemes = map[string]SecurityScheme{
+	"bearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT", Description: "Access token from /api/v1/auth/login"},
+	"cookieAuth": {Type: "apiKey", In: "cookie", Name: "token", Description: "Access token cookie set by login; writes also need the X-CSRF-Token header"},
+	"apiKeyAuth": {Type: "apiKey", In: "header", Name: "X-API-Key", Description: "API key for machine clients, limited to its scopes"},
+}
+
+// Build returns the document for routes. operations is keyed by "METHOD /path" as registered
+// with Gin (e.g. "GET /api/v1/mixes/:id"). It also returns the routes missing from operations.
+// Static file routes are left out.
+func Build(info Info, routes gin.RoutesInfo, operations map[string]Operation) (*Document, []string) {
+	s := newSchemas()
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   map[string]map[string]*PathOp{},
+	}
+
+	var undocumented []string
+	tags := map[string]bool{}
+	operationIDs := map[string]bool{}
+	for _, route := range routes {
+		if route.Method == http.MethodHead || strings.Contains(route.Path, "*") {
+			continue
+		}
+		key := route.Method + " " + route.Path
+		op, ok := operations[key]
+		if !ok {
+			undocumented = append(undocumented, key)
+		}
+
+		path, pathParams := convertPath(route.Path)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*PathOp{}
+		}
+		pathOp := buildOperation(s, route, op, pathParams)
+		pathOp.Undocumented = !ok
+		if operationIDs[pathOp.OperationID] {
+			pathOp.OperationID = routeID(route) // One handler serving several routes
+		}
+		operationIDs[pathOp.OperationID] = true
+		doc.Paths[path][strings.ToLower(route.Method)] = pathOp
+		if op.Tag != "" {
+			tags[op.Tag] = true
+		}
+	}
+
+	for tag := range tags {
+		doc.Tags = append(doc.Tags, Tag{Name: tag})
+	}
+	sort.Slice(doc.Tags, func(i, j int) bool { return doc.Tags[i].Name < doc.Tags[j].Name })
+	sort.Strings(undocumented)
+
+	s.of(Error{})
+	doc.Components = Components{Schemas: s.components, SecuritySchemes: securitySchemes}
+	return doc, undocumented
+}
+
+func buildOperation(s *schemas, route gin.RouteInfo, op Operation, pathParams []string) *PathOp {
+	pathOp := &PathOp{
+		OperationID: operationID(route),
+		Summary:     op.Summary,
+		Description: op.Description,
+		Responses:   map[string]Response{},
+		Permission:  op.Permission,
+	}
+	if op.Tag != "" {
+		pathOp.Tags = []string{op.Tag}
+	}
+
+	for _, name := range pathParams {
+		pathOp.Parameters = append(pathOp.Parameters, Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+	}
+	for _, param := range op.Query {
+		pathOp.Parameters = append(pathOp.Parameters, Parameter{
+			Name: param.Name, In: "query", Description: param.Description, Required: param.Required, Schema: &Schema{Type: "string"},
+		})
+	}
+	if op.Listing != nil {
+		pathOp.Parameters = append(pathOp.Parameters, listingParams(op.Listing)...)
+	}
+
+	switch {
+	case op.Multipart != "":
+		file := &Schema{Type: "string", Format: "binary"}
+		if op.MultipleFiles {
+			file = &Schema{Type: "array", Items: file}
+		}
+		pathOp.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{
+			"multipart/form-data": {Schema: &Schema{
+				Type:       "object",
+				Properties: map[string]*Schema{op.Multipart: file},
+				Required:   []string{op.Multipart},
+			}},
+		}}
+	case op.Request != nil && op.MergePatch:
+		patch := s.patch(op.Request)
+		pathOp.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{
+			"application/merge-patch+json": {Schema: patch},
+			"application/json":             {Schema: patch},
+		}}
+	case op.Request != nil:
+		pathOp.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{
+			"application/json": {Schema: s.of(op.Request)},
+		}}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = 200
+	}
+	success := Response{Description: http.StatusText(status)}
+	switch {
+	case op.HTML:
+		success.Content = map[string]MediaType{"text/html": {Schema: &Schema{Type: "string"}}}
+	case status >= 300 && status < 400:
+		// Redirects have no body
+	case op.Response != nil:
+		success.Content = map[string]MediaType{"application/json": {Schema: s.of(op.Response)}}
+	default:
+		success.Content = map[string]MediaType{"application/json": {Schema: &Schema{Type: "object"}}}
+	}
+	pathOp.Responses[fmt.Sprint(status)] = success
+
+	errorResponse := func(status int) {
+		pathOp.Responses[fmt.Sprint(status)] = Response{
+			Description: http.StatusText(status),
+			Content:     map[string]MediaType{"application/json": {Schema: s.of(Error{})}},
+		}
+	}
+	if pathOp.RequestBody != nil || op.Listing != nil || len(op.Query) > 0 {
+		errorResponse(400)
+	}
+	switch op.Auth {
+	case Account:
+		pathOp.Security = []map[string][]string{{"bearerAuth": {}}, {"cookieAuth": {}}}
+		errorResponse(401)
+	case User:
+		pathOp.Security = []map[string][]string{{"bearerAuth": {}}, {"cookieAuth": {}}}
+		errorResponse(401)
+		errorResponse(403)
+	case Staff:
+		pathOp.Security = []map[string][]string{{"bearerAuth": {}}, {"cookieAuth": {}}, {"apiKeyAuth": {}}}
+		errorResponse(401)
+		errorResponse(403)
+	case Optional:
+		pathOp.Security = []map[string][]string{{}, {"bearerAuth": {}}, {"cookieAuth": {}}}
+	}
+	if len(pathParams) > 0 {
+		errorResponse(404)
+	}
+	return pathOp
+}
+
+// listingParams documents the query parameters parsed by listing.Parse for spec
+func listingParams(spec *listing.Spec) []Parameter {
+	params := []Parameter{
+		{Name: "limit", In: "query", Description: fmt.Sprintf("Page size (default %d, max %d)", listing.DefaultLimit, listing.MaxLimit), Schema: &Schema{Type: "integer"}},
+		{Name: "page", In: "query", Description: "Page number for offset pagination (1-based)", Schema: &Schema{Type: "integer"}},
+		{Name: "cursor", In: "query", Description: "next_cursor of the previous page; takes precedence over page", Schema: &Schema{Type: "string"}},
+	}
+
+	sorts := make([]string, 0, len(spec.Sorts))
+	for key := range spec.Sorts {
+		sorts = append(sorts, key)
+	}
+	sort.Strings(sorts)
+	params = append(params, Parameter{
+		Name: "sort", In: "query",
+		Description: fmt.Sprintf("Comma separated keys of %s, - prefix for descending (default %s)", strings.Join(sorts, ", "), spec.DefaultSort),
+		Schema:      &Schema{Type: "string"},
+	})
+
+	names := make([]string, 0, len(spec.Filters))
+	for name := range spec.Filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		filter := spec.Filters[name]
+		schema := filterSchema(filter.Type)
+		if filter.Range {
+			params = append(params,
+				Parameter{Name: name + "_from", In: "query", Description: "Inclusive lower bound", Schema: schema},
+				Parameter{Name: name + "_to", In: "query", Description: "Inclusive upper bound", Schema: schema},
+			)
+			continue
+		}
+		description := "Exact match"
+		if filter.Type == listing.Text {
+			description = "Exact match; comma separated values match any"
+		}
+		params = append(params, Parameter{Name: name, In: "query", Description: description, Schema: schema})
+	}
+	return params
+}
+
+func filterSchema(t listing.Type) *Schema {
+	switch t {
+	case listing.Bool:
+		return &Schema{Type: "boolean"}
+	case listing.Int:
+		return &Schema{Type: "integer"}
+	case listing.Number:
+		return &Schema{Type: "number"}
+	case listing.Time:
+		return &Schema{Type: "string", Description: "RFC3339 timestamp or YYYY-MM-DD"}
+	}
+	return &Schema{Type: "string"}
+}
+
+// convertPath turns Gin's /mixes/:id into OpenAPI's /mixes/{id} and returns the parameter names
+func convertPath(path string) (string, []string) {
+	var params []string
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			params = append(params, segment[1:])
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// operationID is the handler's function name, e.g. "GetMixByID" for playtz-api/handlers.GetMixByID.
+// Handlers written inline in main.go are named after the route instead ("getAdminLogin").
+func operationID(route gin.RouteInfo) string {
+	parts := strings.Split(route.Handler[strings.LastIndexByte(route.Handler, '/')+1:], ".")
+	for len(parts) > 1 && strings.HasPrefix(parts[len(parts)-1], "func") {
+		parts = parts[:len(parts)-1] // Closures: handlers.OpenAPISpec.func1
+	}
+	if len(parts) > 1 && parts[len(parts)-1] != "main" {
+		return parts[len(parts)-1]
+	}
+	return routeID(route)
+}
+
+// routeID names a route after its method and path: GET /debug/cloudinary is "getDebugCloudinary"
+func routeID(route gin.RouteInfo) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(route.Method))
+	for _, word := range strings.FieldsFunc(route.Path, func(r rune) bool { return r == '/' || r == '-' || r == '.' || r == ':' }) {
+		b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return b.String()
+}
@@ -0,0 +1,248 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is a JSON Schema as used by OpenAPI 3.1
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 interface{}        `json:"type,omitempty"` // A type name, or a list of them for nullable values
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemas generates schemas from Go types. Named structs become components referenced by $ref.
+type schemas struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+func newSchemas() *schemas {
+	return &schemas{components: map[string]*Schema{}, names: map[reflect.Type]string{}}
+}
+
+// of returns the schema of the type of v, or nil for a nil v
+func (s *schemas) of(v interface{}) *Schema {
+	if v == nil {
+		return nil
+	}
+	return s.schema(reflect.TypeOf(v))
+}
+
+func (s *schemas) schema(t reflect.Type) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case rawMessageType:
+		return &Schema{Description: "Any JSON value"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := s.schema(t.Elem())
+		if name, ok := schema.Type.(string); ok {
+			schema.Type = []string{name, "null"}
+		}
+		return schema
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: s.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		return s.ref(t)
+	}
+	return &Schema{} // interface{}: any value
+}
+
+// patch describes a merge patch of the type of v: an object with any of the type's fields,
+// each of which may be null
+func (s *schemas) patch(v interface{}) *Schema {
+	schema := s.object(reflect.TypeOf(v))
+	schema.Required = nil
+	for _, property := range schema.Properties {
+		if name, ok := property.Type.(string); ok {
+			property.Type = []string{name, "null"}
+		}
+	}
+	return schema
+}
+
+// ref registers a named struct as a component and returns a reference to it
+func (s *schemas) ref(t reflect.Type) *Schema {
+	name, ok := s.names[t]
+	if !ok {
+		base := componentName(t)
+		name = base
+		for n := 2; s.components[name] != nil; n++ {
+			name = fmt.Sprintf("%s%d", base, n) // Same name in another package
+		}
+		s.names[t] = name
+		s.components[name] = &Schema{} // Placeholder, so recursive types terminate
+		*s.components[name] = *s.object(t)
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// object describes a struct's JSON fields. Fields with binding:"required" are required.
+func (s *schemas) object(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				inner := s.object(embedded)
+				for property, propertySchema := range inner.Properties {
+					schema.Properties[property] = propertySchema
+				}
+				schema.Required = append(schema.Required, inner.Required...)
+				continue
+			}
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema.Properties[name] = s.schema(field.Type)
+		if strings.Contains(options, "string") {
+			schema.Properties[name] = &Schema{Type: "string"}
+		}
+		if binding := field.Tag.Get("binding"); binding != "" {
+			if hasRule(binding, "required") {
+				schema.Required = append(schema.Required, name)
+			}
+			applyRules(schema.Properties[name], binding)
+		}
+	}
+	return schema
+}
+
+// applyRules documents the validator rules in a binding tag on a field's schema. Rules after
+// "dive" apply to the items of a list.
+func applyRules(schema *Schema, binding string) {
+	if schema.Ref != "" {
+		return
+	}
+	rules := strings.Split(binding, ",")
+	for i, rule := range rules {
+		if rule == "dive" {
+			if schema.Items != nil {
+				applyRules(schema.Items, strings.Join(rules[i+1:], ","))
+			}
+			return
+		}
+
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "oneof":
+			schema.Enum = strings.Fields(param)
+		case "email":
+			schema.Format = "email"
+		case "url", "http_url":
+			schema.Format = "uri"
+		case "min", "gte", "max", "lte":
+			bound, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				continue
+			}
+			lower := name == "min" || name == "gte"
+			switch typeName(schema) {
+			case "string":
+				if n := int(bound); lower {
+					schema.MinLength = &n
+				} else {
+					schema.MaxLength = &n
+				}
+			case "array":
+				if n := int(bound); lower {
+					schema.MinItems = &n
+				}
+			case "integer", "number":
+				if lower {
+					schema.Minimum = &bound
+				} else {
+					schema.Maximum = &bound
+				}
+			}
+		}
+	}
+}
+
+// typeName is the schema's type, ignoring "null" in the types of nullable values
+func typeName(schema *Schema) string {
+	switch t := schema.Type.(type) {
+	case string:
+		return t
+	case []string:
+		return t[0]
+	}
+	return ""
+}
+
+// hasRule reports whether a comma separated validator tag contains rule
+func hasRule(tag, rule string) bool {
+	for _, r := range strings.Split(tag, ",") {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// componentName is the capitalized type name, with generic instances such as
+// listing.Page[handlers.Mix] named after their argument ("MixPage")
+func componentName(t reflect.Type) string {
+	name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+	open := strings.IndexByte(name, '[')
+	if open < 0 {
+		return name
+	}
+	argument := strings.TrimSuffix(name[open+1:], "]")
+	if dot := strings.LastIndexByte(argument, '.'); dot >= 0 {
+		argument = argument[dot+1:]
+	}
+	return argument + name[:open]
+}
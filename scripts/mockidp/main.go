@@ -0,0 +1,219 @@
+// Mock OpenID Connect provider for testing single sign-on locally.
+//
+// It approves every login without a prompt, using login_hint (or MOCKIDP_EMAIL) as the email.
+//
+//	go run ./scripts/mockidp
+//
+// Then start the API with:
+//
+//	OIDC_ISSUER=http://localhost:9999
+//	OIDC_CLIENT_ID=playtz-api
+//	OIDC_CLIENT_SECRET=mock-secret
+//	OIDC_REDIRECT_URL=http://localhost:8080/api/v1/auth/oidc/callback
+//
+// Environment: MOCKIDP_PORT (9999), MOCKIDP_EMAIL, MOCKIDP_GROUPS (comma separated),
+// MOCKIDP_EMAIL_VERIFIED (true), MOCKIDP_CLIENT_ID, MOCKIDP_CLIENT_SECRET.
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const keyID = "mockidp-1"
+
+// authorization is a code waiting to be redeemed at /token
+type authorization struct {
+	clientID      string
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+	email         string
+	expires       time.Time
+}
+
+var (
+	issuer       string
+	clientID     = envOr("MOCKIDP_CLIENT_ID", "playtz-api")
+	clientSecret = envOr("MOCKIDP_CLIENT_SECRET", "mock-secret")
+	signingKey   *rsa.PrivateKey
+
+	codes      = map[string]authorization{}
+	codesMutex sync.Mutex
+)
+
+func main() {
+	port := envOr("MOCKIDP_PORT", "9999")
+	issuer = envOr("MOCKIDP_ISSUER", "http://localhost:"+port)
+
+	var err error
+	signingKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		log.Fatalf("Failed to generate signing key: %v", err)
+	}
+
+	http.HandleFunc("/.well-known/openid-configuration", discovery)
+	http.HandleFunc("/authorize", authorize)
+	http.HandleFunc("/token", token)
+	http.HandleFunc("/jwks", jwks)
+
+	log.Printf("Mock OpenID provider running at %s (client_id=%s)", issuer, clientID)
+	log.Fatal(http.ListenAndServe(":"+port, nil))
+}
+
+func discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, 200, map[string]interface{}{
+		"issuer":                                issuer,
+		"authorization_endpoint":                issuer + "/authorize",
+		"token_endpoint":                        issuer + "/token",
+		"jwks_uri":                              issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+// authorize approves the login immediately and redirects back with a code
+func authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("response_type") != "code" || q.Get("client_id") != clientID {
+		http.Error(w, "invalid authorization request", 400)
+		return
+	}
+	if q.Get("code_challenge") == "" || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "PKCE (S256) is required", 400)
+		return
+	}
+
+	redirectURI, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || redirectURI.Host == "" {
+		http.Error(w, "invalid redirect_uri", 400)
+		return
+	}
+
+	email := q.Get("login_hint")
+	if email == "" {
+		email = envOr("MOCKIDP_EMAIL", "staff@playtz.com")
+	}
+
+	code := randomString()
+	codesMutex.Lock()
+	codes[code] = authorization{
+		clientID:      clientID,
+		redirectURI:   redirectURI.String(),
+		nonce:         q.Get("nonce"),
+		codeChallenge: q.Get("code_challenge"),
+		email:         strings.ToLower(email),
+		expires:       time.Now().Add(time.Minute),
+	}
+	codesMutex.Unlock()
+
+	params := redirectURI.Query()
+	params.Set("code", code)
+	params.Set("state", q.Get("state"))
+	redirectURI.RawQuery = params.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+// token redeems a code (client_secret_post + PKCE) for an RS256 ID token
+func token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil {
+		writeJSON(w, 400, map[string]string{"error": "invalid_request"})
+		return
+	}
+	if r.PostForm.Get("client_id") != clientID || r.PostForm.Get("client_secret") != clientSecret {
+		writeJSON(w, 401, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	codesMutex.Lock()
+	auth, ok := codes[r.PostForm.Get("code")]
+	delete(codes, r.PostForm.Get("code"))
+	codesMutex.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+	if !ok || time.Now().After(auth.expires) || auth.redirectURI != r.PostForm.Get("redirect_uri") || auth.codeChallenge != challenge {
+		writeJSON(w, 400, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            issuer,
+		"sub":            "mock|" + auth.email,
+		"aud":            auth.clientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"nonce":          auth.nonce,
+		"email":          auth.email,
+		"email_verified": envOr("MOCKIDP_EMAIL_VERIFIED", "true") == "true",
+		"given_name":     "Mock",
+		"family_name":    strings.Split(auth.email, "@")[0],
+	}
+	if groups := os.Getenv("MOCKIDP_GROUPS"); groups != "" {
+		claims["groups"] = strings.Split(groups, ",")
+	}
+
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	idToken.Header["kid"] = keyID
+	signed, err := idToken.SignedString(signingKey)
+	if err != nil {
+		writeJSON(w, 500, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, 200, map[string]interface{}{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     signed,
+	})
+}
+
+func jwks(w http.ResponseWriter, r *http.Request) {
+	pub := signingKey.PublicKey
+	writeJSON(w, 200, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": keyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func randomString() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,94 @@
+#!/bin/bash
+
+# Test CSRF protection of cookie-authenticated requests
+#
+# Start the API with an explicit allow list, e.g.
+#   CORS_ORIGINS=http://localhost:3000,https://playtzadmin.vercel.app go run .
+# then: ADMIN_PASS=<admin password> ./scripts/test_csrf.sh
+
+BASE_URL="http://localhost:8080"
+ADMIN_USER="${ADMIN_USER:-admin}"
+ADMIN_PASS="${ADMIN_PASS:?Set ADMIN_PASS to the admin password}"
+ALLOWED_ORIGIN="${ALLOWED_ORIGIN:-http://localhost:3000}"
+FOREIGN_ORIGIN="${FOREIGN_ORIGIN:-https://evil.example.com}"
+COOKIE_FILE="/tmp/test_csrf_cookies.txt"
+
+echo "🧪 Testing CSRF Protection"
+echo "=========================="
+echo ""
+
+GREEN='\033[0;32m'
+RED='\033[0;31m'
+NC='\033[0m' # No Color
+
+rm -f "$COOKIE_FILE"
+
+# expect <description> <expected status> <curl args...>
+expect() {
+    local description=$1
+    local expected=$2
+    shift 2
+    local code
+    code=$(curl -s -o /dev/null -w "%{http_code}" "$@")
+    if [[ $code == "$expected" ]]; then
+        echo -e "${GREEN}✅ $description ($code)${NC}"
+    else
+        echo -e "${RED}❌ $description: expected $expected, got $code${NC}"
+        exit 1
+    fi
+}
+
+# Test 1: Login sets the token cookie
+echo "1️⃣  Logging in..."
+LOGIN_RESPONSE=$(curl -s -X POST "$BASE_URL/api/v1/auth/login" \
+  -H "Content-Type: application/json" \
+  -H "Origin: $ALLOWED_ORIGIN" \
+  -d "{\"username\":\"$ADMIN_USER\",\"password\":\"$ADMIN_PASS\"}" \
+  -c "$COOKIE_FILE")
+ACCESS_TOKEN=$(echo "$LOGIN_RESPONSE" | grep -o '"token":"[^"]*' | cut -d'"' -f4)
+if [ -n "$ACCESS_TOKEN" ]; then
+    echo -e "${GREEN}✅ Logged in${NC}"
+else
+    echo -e "${RED}❌ Login failed: $LOGIN_RESPONSE${NC}"
+    exit 1
+fi
+echo ""
+
+# Test 2: Issue a CSRF token
+echo "2️⃣  Fetching CSRF token..."
+CSRF_TOKEN=$(curl -s "$BASE_URL/api/v1/auth/csrf" \
+  -H "Origin: $ALLOWED_ORIGIN" \
+  -b "$COOKIE_FILE" -c "$COOKIE_FILE" | grep -o '"csrf_token":"[^"]*' | cut -d'"' -f4)
+if [ -n "$CSRF_TOKEN" ] && grep -q "csrf_token" "$COOKIE_FILE"; then
+    echo -e "${GREEN}✅ CSRF token issued${NC}"
+else
+    echo -e "${RED}❌ No CSRF token issued${NC}"
+    exit 1
+fi
+echo ""
+
+# An unsafe request that does nothing when it succeeds: unlock a user that does not exist
+TARGET="$BASE_URL/api/v1/users/00000000-0000-0000-0000-000000000000/unlock"
+
+echo "3️⃣  Cookie-authenticated requests..."
+expect "GET needs no CSRF token" 200 \
+  -b "$COOKIE_FILE" -H "Origin: $FOREIGN_ORIGIN" "$BASE_URL/api/v1/auth/sessions"
+expect "POST without CSRF token is rejected" 403 \
+  -X POST -b "$COOKIE_FILE" -H "Origin: $ALLOWED_ORIGIN" "$TARGET"
+expect "POST with a wrong CSRF token is rejected" 403 \
+  -X POST -b "$COOKIE_FILE" -H "Origin: $ALLOWED_ORIGIN" -H "X-CSRF-Token: wrong" "$TARGET"
+expect "POST with CSRF token from an allowed origin passes" 404 \
+  -X POST -b "$COOKIE_FILE" -H "Origin: $ALLOWED_ORIGIN" -H "X-CSRF-Token: $CSRF_TOKEN" "$TARGET"
+expect "POST with CSRF token from a foreign origin is rejected" 403 \
+  -X POST -b "$COOKIE_FILE" -H "Origin: $FOREIGN_ORIGIN" -H "X-CSRF-Token: $CSRF_TOKEN" "$TARGET"
+expect "POST with CSRF token from the API's own origin passes" 404 \
+  -X POST -b "$COOKIE_FILE" -H "Origin: $BASE_URL" -H "X-CSRF-Token: $CSRF_TOKEN" "$TARGET"
+echo ""
+
+echo "4️⃣  Authorization header requests..."
+expect "Bearer token needs no CSRF token" 404 \
+  -X POST -H "Authorization: Bearer $ACCESS_TOKEN" -H "Origin: $FOREIGN_ORIGIN" "$TARGET"
+echo ""
+
+echo -e "${GREEN}🎉 All CSRF tests passed${NC}"
+rm -f "$COOKIE_FILE"
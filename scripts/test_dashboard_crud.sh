@@ -4,8 +4,8 @@
 # Tests Create, Read, Update, Delete for all resources
 
 API_BASE="https://playtzapi-production.up.railway.app/api/v1"
-ADMIN_USER="admin"
-ADMIN_PASS="admin123"
+ADMIN_USER="${ADMIN_USER:-admin}"
+ADMIN_PASS="${ADMIN_PASS:?Set ADMIN_PASS to the admin password}"
 
 echo "🧪 Dashboard CRUD Test Suite"
 echo "=============================="
@@ -36,6 +36,16 @@ fi
 
 echo ""
 
+# Cookie-authenticated writes must echo the CSRF token
+CSRF_TOKEN=$(curl -s "$API_BASE/auth/csrf" \
+  -H "Origin: https://playtzadmin.vercel.app" \
+  -b /tmp/dashboard_cookies.txt \
+  -c /tmp/dashboard_cookies.txt | grep -o '"csrf_token":"[^"]*' | cut -d'"' -f4)
+if [ -z "$CSRF_TOKEN" ]; then
+  echo -e "${RED}❌ Failed to get CSRF token${NC}"
+  exit 1
+fi
+
 # Function to test CRUD operations
 test_crud() {
   local resource=$1
@@ -51,6 +61,7 @@ test_crud() {
   CREATE_RESPONSE=$(curl -s -X POST "$API_BASE/$resource" \
     -H "Content-Type: application/json" \
     -H "Origin: https://playtzadmin.vercel.app" \
+    -H "X-CSRF-Token: $CSRF_TOKEN" \
     -b /tmp/dashboard_cookies.txt \
     -d "$create_data")
   
@@ -105,6 +116,7 @@ test_crud() {
     UPDATE_RESPONSE=$(curl -s -X PUT "$API_BASE/$resource/$RESOURCE_ID" \
       -H "Content-Type: application/json" \
       -H "Origin: https://playtzadmin.vercel.app" \
+      -H "X-CSRF-Token: $CSRF_TOKEN" \
       -b /tmp/dashboard_cookies.txt \
       -d "$update_data")
     
@@ -123,6 +135,7 @@ test_crud() {
   DELETE_RESPONSE=$(curl -s -X DELETE "$API_BASE/$resource/$RESOURCE_ID" \
     -H "Content-Type: application/json" \
     -H "Origin: https://playtzadmin.vercel.app" \
+    -H "X-CSRF-Token: $CSRF_TOKEN" \
     -b /tmp/dashboard_cookies.txt)
   
   if echo "$DELETE_RESPONSE" | grep -qE '"message"|"success"|200'; then
@@ -138,17 +151,17 @@ test_crud() {
 # Test News
 test_crud "news" \
   '{"title":"Test News Article","content":"This is a test article","author":"Test Author","published":true}' \
-  '{"title":"Updated News Article","content":"Updated content","author":"Test Author","published":true}'
+  '{"title":"Updated News Article","content":"Updated content","author":"Test Author","image":"","published":true}'
 
 # Test Events
 test_crud "events" \
   '{"title":"Test Event","description":"Test event description","date":"2026-12-31","time":"18:00:00","location":"Test Location","active":true}' \
-  '{"title":"Updated Event","description":"Updated description","date":"2026-12-31","time":"19:00:00","location":"Updated Location","active":true}'
+  '{"title":"Updated Event","description":"Updated description","date":"2026-12-31","time":"19:00:00","location":"Updated Location","image":"","active":true}'
 
 # Test Merchandise
 test_crud "merch" \
   '{"name":"Test Product","description":"Test product description","price":29.99,"stock":100,"active":true}' \
-  '{"name":"Updated Product","description":"Updated description","price":39.99,"stock":50,"active":true}'
+  '{"name":"Updated Product","description":"Updated description","price":39.99,"image":"","stock":50,"active":true}'
 
 # Test Careers
 test_crud "careers" \
@@ -158,7 +171,7 @@ test_crud "careers" \
 # Test Rooms
 test_crud "rooms" \
   '{"name":"Test Room","genre":"Test Genre","description":"Test room description","gradient":"linear-gradient(45deg, #ff0000, #00ff00)","text_color":"#ffffff","active":true}' \
-  '{"name":"Updated Room","genre":"Updated Genre","description":"Updated description","gradient":"linear-gradient(45deg, #0000ff, #ffff00)","text_color":"#000000","active":true}'
+  '{"name":"Updated Room","genre":"Updated Genre","description":"Updated description","gradient":"linear-gradient(45deg, #0000ff, #ffff00)","text_color":"#000000","image":"","active":true}'
 
 # Test Mixes (requires room_id, so we'll create a room first)
 echo "━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━"
@@ -169,6 +182,7 @@ echo "━━━━━━━━━━━━━━━━━━━━━━━━
 ROOM_RESPONSE=$(curl -s -X POST "$API_BASE/rooms" \
   -H "Content-Type: application/json" \
   -H "Origin: https://playtzadmin.vercel.app" \
+  -H "X-CSRF-Token: $CSRF_TOKEN" \
   -b /tmp/dashboard_cookies.txt \
   -d '{"name":"Test Room for Mix","genre":"Test","description":"Test","gradient":"#ff0000","text_color":"#ffffff","active":true}')
 
@@ -180,12 +194,13 @@ fi
 if [ -n "$ROOM_ID" ]; then
   test_crud "mixes" \
     "{\"room_id\":\"$ROOM_ID\",\"title\":\"Test Mix\",\"artist\":\"Test Artist\",\"description\":\"Test mix description\",\"duration\":\"60:00\",\"tracks\":10,\"active\":true}" \
-    "{\"room_id\":\"$ROOM_ID\",\"title\":\"Updated Mix\",\"artist\":\"Updated Artist\",\"description\":\"Updated description\",\"duration\":\"75:00\",\"tracks\":15,\"active\":true}"
+    "{\"room_id\":\"$ROOM_ID\",\"title\":\"Updated Mix\",\"artist\":\"Updated Artist\",\"description\":\"Updated description\",\"duration\":\"75:00\",\"color\":\"\",\"text_color\":\"\",\"border_color\":\"\",\"image\":\"\",\"audio_url\":\"\",\"active\":true}"
   
   # Clean up room
   curl -s -X DELETE "$API_BASE/rooms/$ROOM_ID" \
     -H "Content-Type: application/json" \
     -H "Origin: https://playtzadmin.vercel.app" \
+    -H "X-CSRF-Token: $CSRF_TOKEN" \
     -b /tmp/dashboard_cookies.txt > /dev/null
 else
   echo -e "${RED}❌ Failed to create room for mix test${NC}"
@@ -210,6 +225,7 @@ if [ -z "$ROLE_ID" ]; then
   ROLE_CREATE=$(curl -s -X POST "$API_BASE/roles" \
     -H "Content-Type: application/json" \
     -H "Origin: https://playtzadmin.vercel.app" \
+    -H "X-CSRF-Token: $CSRF_TOKEN" \
     -b /tmp/dashboard_cookies.txt \
     -d '{"name":"Test Role","description":"Test role","permissions":["test.read"],"active":true}')
   ROLE_ID=$(echo "$ROLE_CREATE" | grep -o '"id":"[^"]*' | head -1 | cut -d'"' -f4)
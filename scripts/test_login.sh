@@ -1,9 +1,11 @@
 #!/bin/bash
 
 # Test Login and Dashboard Access Script
-# Usage: ./scripts/test_login.sh
+# Usage: ADMIN_PASS=<admin password> ./scripts/test_login.sh
 
 BASE_URL="http://localhost:8080"
+ADMIN_USER="${ADMIN_USER:-admin}"
+ADMIN_PASS="${ADMIN_PASS:?Set ADMIN_PASS to the admin password}"
 COOKIE_FILE="/tmp/test_login_cookies.txt"
 
 echo "🧪 Testing Login and Dashboard Access"
@@ -31,7 +33,7 @@ echo ""
 echo "2️⃣  Testing Login API..."
 LOGIN_RESPONSE=$(curl -s -X POST "$BASE_URL/api/v1/auth/login" \
   -H "Content-Type: application/json" \
-  -d '{"username":"admin","password":"admin123"}' \
+  -d "{\"username\":\"$ADMIN_USER\",\"password\":\"$ADMIN_PASS\"}" \
   -c "$COOKIE_FILE" \
   -w "\n%{http_code}")
 
@@ -85,9 +87,12 @@ else
 fi
 echo ""
 
-# Test 5: Logout
+# Test 5: Logout (cookie-authenticated, so it needs the CSRF token)
 echo "5️⃣  Testing Logout..."
+CSRF_TOKEN=$(curl -s "$BASE_URL/api/v1/auth/csrf" -b "$COOKIE_FILE" -c "$COOKIE_FILE" | \
+  python3 -c "import sys, json; print(json.load(sys.stdin)['csrf_token'])" 2>/dev/null)
 LOGOUT_RESPONSE=$(curl -s -X POST "$BASE_URL/api/v1/auth/logout" \
+  -H "X-CSRF-Token: $CSRF_TOKEN" \
   -b "$COOKIE_FILE" \
   -c "$COOKIE_FILE" \
   -w "\n%{http_code}")
@@ -110,7 +115,7 @@ echo "   Dashboard: $BASE_URL/admin/dashboard"
 echo ""
 echo "🔐 Test Credentials:"
 echo "   Username: admin"
-echo "   Password: admin123"
+echo "   Password: \$ADMIN_PASS"
 echo ""
 
 # Cleanup
@@ -4,6 +4,8 @@
 # Tests the Railway production login endpoint
 
 BASE_URL="https://playtzapi-production.up.railway.app"
+ADMIN_USER="${ADMIN_USER:-admin}"
+ADMIN_PASS="${ADMIN_PASS:?Set ADMIN_PASS to the admin password}"
 COOKIE_FILE="/tmp/railway_test_cookies.txt"
 
 echo "🧪 Testing Login Endpoint"
@@ -50,7 +52,7 @@ echo "3️⃣  Testing Login with Valid Credentials..."
 LOGIN_RESPONSE=$(curl -s -X POST "$BASE_URL/api/v1/auth/login" \
   -H "Content-Type: application/json" \
   -H "Origin: http://localhost:3001" \
-  -d '{"username":"admin","password":"admin123"}' \
+  -d "{\"username\":\"$ADMIN_USER\",\"password\":\"$ADMIN_PASS\"}" \
   -c "$COOKIE_FILE" \
   -w "\n%{http_code}")
 
@@ -142,7 +144,7 @@ echo "7️⃣  Checking CORS Headers..."
 CORS_HEADERS=$(curl -s -X POST "$BASE_URL/api/v1/auth/login" \
   -H "Content-Type: application/json" \
   -H "Origin: http://localhost:3001" \
-  -d '{"username":"admin","password":"admin123"}' \
+  -d "{\"username\":\"$ADMIN_USER\",\"password\":\"$ADMIN_PASS\"}" \
   -v 2>&1 | grep -i "access-control")
 
 if [ -n "$CORS_HEADERS" ]; then
@@ -165,7 +167,7 @@ echo "Example Request:"
 echo 'curl -X POST '"$BASE_URL/api/v1/auth/login"' \'
 echo '  -H "Content-Type: application/json" \'
 echo '  -H "Origin: http://localhost:3001" \'
-echo '  -d '"'"'{"username":"admin","password":"admin123"}'"'"' \'
+echo '  -d '"'"'{"username":"admin","password":"your-password"}'"'"' \'
 echo '  -c cookies.txt'
 echo ""
 
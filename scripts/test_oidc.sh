@@ -0,0 +1,107 @@
+#!/bin/bash
+
+# Test OpenID Connect single sign-on against the mock identity provider
+#
+# 1. go run ./scripts/mockidp
+# 2. Start the API with:
+#      OIDC_ISSUER=http://localhost:9999 OIDC_CLIENT_ID=playtz-api OIDC_CLIENT_SECRET=mock-secret \
+#      OIDC_REDIRECT_URL=http://localhost:8080/api/v1/auth/oidc/callback \
+#      OIDC_ROLE_MAPPINGS=domain:playtz.com=editor CORS_ORIGINS=http://localhost:3000
+# 3. ./scripts/test_oidc.sh
+
+BASE_URL="http://localhost:8080"
+FRONTEND_URL="${FRONTEND_URL:-http://localhost:3000/}"
+SSO_EMAIL="${SSO_EMAIL:-sso.tester@playtz.com}"
+COOKIE_FILE="/tmp/test_oidc_cookies.txt"
+
+echo "🧪 Testing OIDC Single Sign-On"
+echo "=============================="
+echo ""
+
+GREEN='\033[0;32m'
+RED='\033[0;31m'
+NC='\033[0m' # No Color
+
+rm -f "$COOKIE_FILE"
+
+# Test 1: Login redirects to the provider with PKCE
+echo "1️⃣  Starting SSO login..."
+PROVIDER_URL=$(curl -s -o /dev/null -w "%{redirect_url}" -c "$COOKIE_FILE" \
+  "$BASE_URL/api/v1/auth/oidc/login?redirect_to=$FRONTEND_URL&login_hint=$SSO_EMAIL")
+if [[ $PROVIDER_URL == *"code_challenge_method=S256"* ]]; then
+    echo -e "${GREEN}✅ Redirected to provider${NC}"
+else
+    echo -e "${RED}❌ Expected a redirect to the provider, got: $PROVIDER_URL${NC}"
+    exit 1
+fi
+echo ""
+
+# Test 2: Provider approves and redirects back to the API callback
+echo "2️⃣  Authorizing at the provider..."
+CALLBACK_URL=$(curl -s -o /dev/null -w "%{redirect_url}" "$PROVIDER_URL")
+if [[ $CALLBACK_URL == *"/oidc/callback?"* ]]; then
+    echo -e "${GREEN}✅ Provider returned a code${NC}"
+else
+    echo -e "${RED}❌ Provider did not redirect to the callback: $CALLBACK_URL${NC}"
+    exit 1
+fi
+echo ""
+
+# Test 3: The callback is refused in a browser that did not start the login
+echo "3️⃣  Completing login without the state cookie..."
+FOREIGN_CODE=$(curl -s -o /dev/null -w "%{http_code}" "$CALLBACK_URL")
+if [[ $FOREIGN_CODE == "400" ]]; then
+    echo -e "${GREEN}✅ Callback without the state cookie rejected${NC}"
+else
+    echo -e "${RED}❌ Callback without the state cookie returned $FOREIGN_CODE${NC}"
+    exit 1
+fi
+echo ""
+
+# Test 4: Callback sets the auth cookies and returns to the frontend
+echo "4️⃣  Completing login..."
+FINAL_URL=$(curl -s -o /dev/null -w "%{redirect_url}" -b "$COOKIE_FILE" -c "$COOKIE_FILE" "$CALLBACK_URL")
+if [[ $FINAL_URL == "$FRONTEND_URL" ]] && grep -q "	token	" "$COOKIE_FILE"; then
+    echo -e "${GREEN}✅ Logged in, redirected to $FINAL_URL${NC}"
+else
+    echo -e "${RED}❌ Login failed, redirected to: $FINAL_URL${NC}"
+    exit 1
+fi
+echo ""
+
+# Test 5: Session belongs to the SSO user
+echo "5️⃣  Checking /auth/me..."
+ME=$(curl -s -b "$COOKIE_FILE" "$BASE_URL/api/v1/auth/me")
+if [[ $ME == *"$SSO_EMAIL"* ]]; then
+    echo -e "${GREEN}✅ Authenticated as $SSO_EMAIL${NC}"
+else
+    echo -e "${RED}❌ Unexpected /auth/me response: $ME${NC}"
+    exit 1
+fi
+echo ""
+
+# Test 6: The state is single use
+echo "6️⃣  Replaying the callback..."
+REPLAY_CODE=$(curl -s -o /dev/null -w "%{http_code}" -b "$COOKIE_FILE" "$CALLBACK_URL")
+if [[ $REPLAY_CODE == "400" ]]; then
+    echo -e "${GREEN}✅ Replayed state rejected${NC}"
+else
+    echo -e "${RED}❌ Replayed state returned $REPLAY_CODE${NC}"
+    exit 1
+fi
+echo ""
+
+# Test 7: Redirects to other origins are refused
+echo "7️⃣  Checking redirect_to validation..."
+OPEN_REDIRECT=$(curl -s -o /dev/null -w "%{http_code}" \
+  "$BASE_URL/api/v1/auth/oidc/login?redirect_to=https://evil.example.com/")
+if [[ $OPEN_REDIRECT == "400" ]]; then
+    echo -e "${GREEN}✅ Foreign redirect_to rejected${NC}"
+else
+    echo -e "${RED}❌ Foreign redirect_to returned $OPEN_REDIRECT${NC}"
+    exit 1
+fi
+echo ""
+
+echo -e "${GREEN}🎉 All SSO tests passed${NC}"
+rm -f "$COOKIE_FILE"
@@ -0,0 +1,127 @@
+#!/bin/bash
+
+# Test PUT (full replacement) and PATCH (JSON merge patch) on a merchandise item
+#
+# A PUT missing fields must be rejected, and a PATCH must change only the fields it sends.
+# Start the API (go run .) and run: ADMIN_PASS=... ./scripts/test_patch.sh
+
+BASE_URL="${BASE_URL:-http://localhost:8080}"
+API_BASE="$BASE_URL/api/v1"
+ADMIN_USER="${ADMIN_USER:-admin}"
+ADMIN_PASS="${ADMIN_PASS:?Set ADMIN_PASS to the admin password}"
+
+echo "🧪 Testing PUT and PATCH"
+echo "========================"
+echo ""
+
+GREEN='\033[0;32m'
+RED='\033[0;31m'
+NC='\033[0m' # No Color
+
+FAILED=0
+
+# field NAME reads a field of the JSON response on stdin
+field() {
+    python3 -c "import sys, json; print(json.load(sys.stdin).get('$1', ''))" 2>/dev/null
+}
+
+# Test 1: Log in for a bearer token (no CSRF token needed)
+echo "1️⃣  Logging in..."
+TOKEN=$(curl -s -X POST "$API_BASE/auth/login" \
+  -H "Content-Type: application/json" \
+  -d "{\"username\":\"$ADMIN_USER\",\"password\":\"$ADMIN_PASS\"}" | field token)
+if [ -z "$TOKEN" ]; then
+    echo -e "${RED}❌ Login failed${NC}"
+    exit 1
+fi
+echo -e "${GREEN}✅ Logged in${NC}"
+echo ""
+
+# Test 2: Create an item to change
+echo "2️⃣  Creating a merchandise item..."
+ITEM_ID=$(curl -s -X POST "$API_BASE/merch" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{"name":"Patch Test Shirt","description":"Black","price":25,"image":"https://example.com/shirt.png","stock":10}' | field id)
+if [ -z "$ITEM_ID" ]; then
+    echo -e "${RED}❌ Create failed${NC}"
+    exit 1
+fi
+echo -e "${GREEN}✅ Created $ITEM_ID${NC}"
+echo ""
+
+# Test 3: A PUT leaving fields out is rejected and changes nothing
+echo "3️⃣  PUT with only a name..."
+RESPONSE=$(curl -s -X PUT "$API_BASE/merch/$ITEM_ID" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{"name":"Renamed"}' \
+  -w "\n%{http_code}")
+CODE=$(echo "$RESPONSE" | tail -n1)
+MISSING=$(echo "$RESPONSE" | sed '$d' | python3 -c "
+import sys, json
+print(' '.join(sorted(d['field'] for d in json.load(sys.stdin).get('details', []) if d['code'] == 'required')))
+" 2>/dev/null)
+if [[ $CODE == "400" && "$MISSING" == "active description image price stock" ]]; then
+    echo -e "${GREEN}✅ Rejected, missing: $MISSING${NC}"
+else
+    echo -e "${RED}❌ Expected 400 listing the missing fields, got $CODE with [$MISSING]${NC}"
+    FAILED=1
+fi
+echo ""
+
+# Test 4: A PATCH changes only what it sends
+echo "4️⃣  PATCH the stock..."
+RESPONSE=$(curl -s -X PATCH "$API_BASE/merch/$ITEM_ID" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/merge-patch+json" \
+  -d '{"stock":7}')
+SUMMARY=$(echo "$RESPONSE" | python3 -c "
+import sys, json
+item = json.load(sys.stdin)
+print(item.get('name'), item.get('stock'), item.get('active'), item.get('image', ''))
+" 2>/dev/null)
+if [[ "$SUMMARY" == "Patch Test Shirt 7 True https://example.com/shirt.png" ]]; then
+    echo -e "${GREEN}✅ Stock changed, name, active and image kept${NC}"
+else
+    echo -e "${RED}❌ Unexpected item: $SUMMARY${NC}"
+    FAILED=1
+fi
+echo ""
+
+# Test 5: null resets a field
+echo "5️⃣  PATCH the image to null..."
+IMAGE=$(curl -s -X PATCH "$API_BASE/merch/$ITEM_ID" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/merge-patch+json" \
+  -d '{"image":null}' | field image)
+if [ -z "$IMAGE" ]; then
+    echo -e "${GREEN}✅ Image cleared${NC}"
+else
+    echo -e "${RED}❌ Image is still $IMAGE${NC}"
+    FAILED=1
+fi
+echo ""
+
+# Test 6: The patched item must still pass validation
+echo "6️⃣  PATCH the name to null..."
+CODE=$(curl -s -o /dev/null -w "%{http_code}" -X PATCH "$API_BASE/merch/$ITEM_ID" \
+  -H "Authorization: Bearer $TOKEN" \
+  -H "Content-Type: application/merge-patch+json" \
+  -d '{"name":null}')
+if [[ $CODE == "400" ]]; then
+    echo -e "${GREEN}✅ Rejected: name is required${NC}"
+else
+    echo -e "${RED}❌ Expected 400, got $CODE${NC}"
+    FAILED=1
+fi
+echo ""
+
+curl -s -o /dev/null -X DELETE "$API_BASE/merch/$ITEM_ID" -H "Authorization: Bearer $TOKEN"
+
+if [ $FAILED -eq 0 ]; then
+    echo -e "${GREEN}🎉 All PUT and PATCH tests passed${NC}"
+else
+    echo -e "${RED}❌ Some PUT and PATCH tests failed${NC}"
+    exit 1
+fi
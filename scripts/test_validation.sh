@@ -0,0 +1,81 @@
+#!/bin/bash
+
+# Test request validation on create endpoints
+#
+# Each invalid body must be rejected with 400 validation_failed, listing every
+# offending field in details - nothing is created.
+# Start the API (go run .) and run: ADMIN_PASS=... ./scripts/test_validation.sh
+
+BASE_URL="${BASE_URL:-http://localhost:8080}"
+API_BASE="$BASE_URL/api/v1"
+ADMIN_USER="${ADMIN_USER:-admin}"
+ADMIN_PASS="${ADMIN_PASS:?Set ADMIN_PASS to the admin password}"
+
+echo "🧪 Testing Request Validation"
+echo "============================="
+echo ""
+
+GREEN='\033[0;32m'
+RED='\033[0;31m'
+NC='\033[0m' # No Color
+
+FAILED=0
+
+# Test 1: Log in for a bearer token (no CSRF token needed)
+echo "1️⃣  Logging in..."
+TOKEN=$(curl -s -X POST "$API_BASE/auth/login" \
+  -H "Content-Type: application/json" \
+  -d "{\"username\":\"$ADMIN_USER\",\"password\":\"$ADMIN_PASS\"}" |
+  python3 -c "import sys, json; print(json.load(sys.stdin).get('token', ''))" 2>/dev/null)
+if [ -z "$TOKEN" ]; then
+    echo -e "${RED}❌ Login failed${NC}"
+    exit 1
+fi
+echo -e "${GREEN}✅ Logged in${NC}"
+echo ""
+
+# expect_violations PATH BODY FIELD... posts BODY and checks that exactly FIELDs are reported
+expect_violations() {
+    local path=$1 body=$2
+    shift 2
+    local response
+    response=$(curl -s -X POST "$API_BASE$path" \
+      -H "Authorization: Bearer $TOKEN" \
+      -H "Content-Type: application/json" \
+      -d "$body" \
+      -w "\n%{http_code}")
+    local code=$(echo "$response" | tail -n1)
+    local fields=$(echo "$response" | sed '$d' | python3 -c "
+import sys, json
+body = json.load(sys.stdin)
+if body.get('code') == 'validation_failed':
+    print(' '.join(sorted(d['field'] for d in body.get('details', []))))
+" 2>/dev/null)
+    local expected=$(printf '%s\n' "$@" | sort | tr '\n' ' ' | sed 's/ $//')
+
+    if [[ $code == "400" && "$fields" == "$expected" ]]; then
+        echo -e "${GREEN}✅ POST $path rejected: $fields${NC}"
+    else
+        echo -e "${RED}❌ POST $path: expected 400 with [$expected], got $code with [$fields]${NC}"
+        FAILED=1
+    fi
+}
+
+# Test 2: Every violation is reported at once
+echo "2️⃣  Posting invalid bodies..."
+expect_violations /news '{"title":"   ","image":"not a url"}' title image
+expect_violations /events '{"title":"Launch","date":"31/12/2026","time":"6pm"}' date time
+expect_violations /merch '{"name":"","price":-5,"stock":-1}' name price stock
+expect_violations /merch '{"name":"Shirt","price":"free","stock":-1}' price stock
+expect_violations /careers '{"title":"DJ","type":"gig"}' type
+expect_violations /rooms '{"name":"","image":"ftp//x"}' name image
+expect_violations /mixes '{"title":"","audio_url":"nope"}' room_id title audio_url
+expect_violations /users '{"email":"not-an-email","username":""}' email username role_id
+echo ""
+
+if [ $FAILED -eq 0 ]; then
+    echo -e "${GREEN}🎉 All validation tests passed${NC}"
+else
+    echo -e "${RED}❌ Some validation tests failed${NC}"
+    exit 1
+fi
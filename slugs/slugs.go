@@ -0,0 +1,286 @@
+// Package slugs gives news, events, mixes and rooms unique, human-readable addresses.
+//
+// Slugs are generated from titles (transliterated to ASCII, "-2", "-3"... appended on
+// collision) and can be edited. A renamed resource keeps its former slugs as aliases in
+// slug_aliases so old links can be redirected to the current slug.
+package slugs
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"playtz-api/database"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxLength caps generated slugs; longer titles are cut at a word boundary
+const MaxLength = 80
+
+var (
+	// ErrInvalid is returned for requested slugs with no letters or digits
+	ErrInvalid = errors.New("slug must contain letters or digits")
+	// ErrTaken is returned when a requested slug is the slug or alias of another resource
+	ErrTaken = errors.New("slug is already in use")
+)
+
+// Resource is a table addressable by slug
+type Resource struct {
+	// Type is the resource_type stored in slug_aliases
+	Type string
+	// Table holds the slug column
+	Table string
+	// TitleColumn is what slugs are generated from
+	TitleColumn string
+}
+
+var (
+	News   = Resource{Type: "news", Table: "news", TitleColumn: "title"}
+	Events = Resource{Type: "events", Table: "events", TitleColumn: "title"}
+	Mixes  = Resource{Type: "mixes", Table: "mixes", TitleColumn: "title"}
+	Rooms  = Resource{Type: "rooms", Table: "rooms", TitleColumn: "name"}
+)
+
+// Resources lists every resource with slugs
+var Resources = []Resource{News, Events, Mixes, Rooms}
+
+// querier is a database or a transaction
+type querier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// transliterations covers Latin letters that do not decompose into ASCII plus an accent
+var transliterations = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'đ': "d", 'ð': "d", 'þ': "th",
+	'ł': "l", 'ı': "i", 'ħ': "h", 'ŋ': "ng", '&': " and ",
+}
+
+// Make turns a title into a slug: lowercase ASCII letters and digits separated by single
+// hyphens, e.g. "Café Nights: Drum & Bass" becomes "cafe-nights-drum-and-bass".
+// It returns "" when the title has no usable characters.
+func Make(title string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range norm.NFKD.String(strings.ToLower(title)) {
+		if unicode.Is(unicode.Mn, r) {
+			continue // Accents split off by NFKD
+		}
+		if t, ok := transliterations[r]; ok {
+			for _, tr := range t {
+				if tr == ' ' {
+					hyphen = b.Len() > 0
+					continue
+				}
+				if hyphen {
+					b.WriteByte('-')
+					hyphen = false
+				}
+				b.WriteRune(tr)
+			}
+			continue
+		}
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if hyphen {
+				b.WriteByte('-')
+				hyphen = false
+			}
+			b.WriteRune(r)
+			continue
+		}
+		hyphen = b.Len() > 0
+	}
+
+	slug := b.String()
+	if len(slug) > MaxLength {
+		slug = slug[:MaxLength]
+		if i := strings.LastIndexByte(slug, '-'); i > MaxLength/2 {
+			slug = slug[:i]
+		}
+		slug = strings.TrimRight(slug, "-")
+	}
+	return slug
+}
+
+// Assign picks the slug for a new resource. A requested slug is normalized with Make and
+// must be free; otherwise the slug is generated from the title with a numeric suffix on
+// collision. Titles without usable characters fall back to the start of the ID.
+func Assign(resource Resource, id, requested, title string) (string, error) {
+	if requested != "" {
+		slug := Make(requested)
+		if slug == "" {
+			return "", ErrInvalid
+		}
+		taken, err := isTaken(database.DB, resource, slug, id)
+		if err != nil {
+			return "", err
+		}
+		if taken {
+			return "", ErrTaken
+		}
+		return slug, nil
+	}
+
+	base := Make(title)
+	if base == "" {
+		base = Make(id)
+		if len(base) > 8 {
+			base = strings.TrimRight(base[:8], "-")
+		}
+	}
+	return unique(resource, base, id)
+}
+
+// unique returns base, or base with the first free numeric suffix
+func unique(resource Resource, base, id string) (string, error) {
+	for n := 1; n <= 1000; n++ {
+		candidate := base
+		if n > 1 {
+			suffix := fmt.Sprintf("-%d", n)
+			if len(base)+len(suffix) > MaxLength {
+				candidate = strings.TrimRight(base[:MaxLength-len(suffix)], "-")
+			}
+			candidate += suffix
+		}
+		taken, err := isTaken(database.DB, resource, candidate, id)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no free slug for %q", base)
+}
+
+// isTaken reports whether slug is the current slug or a former slug of a resource other than id
+func isTaken(q querier, resource Resource, slug, id string) (bool, error) {
+	var taken bool
+	err := q.QueryRow(fmt.Sprintf(`
+		SELECT EXISTS (SELECT 1 FROM %s WHERE slug = $1 AND id <> $2)
+		    OR EXISTS (SELECT 1 FROM slug_aliases WHERE resource_type = $3 AND slug = $1 AND resource_id <> $2)
+	`, resource.Table), slug, id, resource.Type).Scan(&taken)
+	return taken, err
+}
+
+// Change renames the slug of an existing resource and returns the slug it has afterwards.
+// An empty requested slug keeps the current one. The former slug becomes an alias; renaming
+// back to a former slug removes that alias. Returns sql.ErrNoRows if the resource does not exist.
+// It runs in tx, so the caller can commit the slug together with the rest of its update.
+func Change(tx *sql.Tx, resource Resource, id, requested string) (string, error) {
+	var current sql.NullString
+	err := tx.QueryRow("SELECT slug FROM "+resource.Table+" WHERE id = $1 FOR UPDATE", id).Scan(&current)
+	if err != nil {
+		return "", err
+	}
+	if requested == "" {
+		return current.String, nil
+	}
+
+	slug := Make(requested)
+	if slug == "" {
+		return "", ErrInvalid
+	}
+	if slug == current.String {
+		return slug, nil
+	}
+	taken, err := isTaken(tx, resource, slug, id)
+	if err != nil {
+		return "", err
+	}
+	if taken {
+		return "", ErrTaken
+	}
+
+	if _, err := tx.Exec("UPDATE "+resource.Table+" SET slug = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2", slug, id); err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec("DELETE FROM slug_aliases WHERE resource_type = $1 AND slug = $2", resource.Type, slug); err != nil {
+		return "", err
+	}
+	if current.Valid && current.String != "" {
+		_, err := tx.Exec(
+			"INSERT INTO slug_aliases (resource_type, slug, resource_id) VALUES ($1, $2, $3) ON CONFLICT (resource_type, slug) DO UPDATE SET resource_id = EXCLUDED.resource_id",
+			resource.Type, current.String, id,
+		)
+		if err != nil {
+			return "", err
+		}
+	}
+	return slug, nil
+}
+
+// Resolve finds the resource addressed by slug, either by its current slug or a former one,
+// and returns its ID and current slug. A current slug different from the one asked for means
+// the caller should redirect. visible is an SQL condition on the resource's columns limiting
+// which rows can be found ("true" for all). Returns sql.ErrNoRows if nothing matches.
+func Resolve(resource Resource, slug, visible string) (id, current string, err error) {
+	slug = Make(slug)
+	if slug == "" {
+		return "", "", sql.ErrNoRows
+	}
+
+	err = database.DB.QueryRow(
+		"SELECT id, slug FROM "+resource.Table+" WHERE slug = $1 AND ("+visible+")",
+		slug,
+	).Scan(&id, &current)
+	if err != sql.ErrNoRows {
+		return id, current, err
+	}
+
+	err = database.DB.QueryRow(fmt.Sprintf(`
+		SELECT t.id, t.slug
+		FROM slug_aliases a
+		JOIN %s t ON t.id = a.resource_id
+		WHERE a.resource_type = $1 AND a.slug = $2 AND (%s)
+	`, resource.Table, visible), resource.Type, slug).Scan(&id, &current)
+	return id, current, err
+}
+
+// Forget removes the former slugs of a deleted resource so they can be reused
+func Forget(resource Resource, id string) error {
+	_, err := database.DB.Exec("DELETE FROM slug_aliases WHERE resource_type = $1 AND resource_id = $2", resource.Type, id)
+	return err
+}
+
+// Backfill gives every row created before slugs existed a slug, oldest rows first so they
+// get the unsuffixed slugs
+func Backfill() error {
+	for _, resource := range Resources {
+		rows, err := database.DB.Query(fmt.Sprintf(
+			"SELECT id, COALESCE(%s, '') FROM %s WHERE slug IS NULL ORDER BY created_at, id",
+			resource.TitleColumn, resource.Table,
+		))
+		if err != nil {
+			return fmt.Errorf("failed to list %s without slugs: %w", resource.Table, err)
+		}
+		type pending struct{ id, title string }
+		var missing []pending
+		for rows.Next() {
+			var p pending
+			if err := rows.Scan(&p.id, &p.title); err != nil {
+				rows.Close()
+				return err
+			}
+			missing = append(missing, p)
+		}
+		rows.Close()
+
+		for _, p := range missing {
+			slug, err := Assign(resource, p.id, "", p.title)
+			if err != nil {
+				return fmt.Errorf("failed to generate slug for %s %s: %w", resource.Type, p.id, err)
+			}
+			// updated_at is left alone: only the address is new, not the content
+			if _, err := database.DB.Exec("UPDATE "+resource.Table+" SET slug = $1 WHERE id = $2 AND slug IS NULL", slug, p.id); err != nil {
+				return fmt.Errorf("failed to set slug for %s %s: %w", resource.Type, p.id, err)
+			}
+		}
+		if len(missing) > 0 {
+			log.Printf("Generated slugs for %d %s", len(missing), resource.Type)
+		}
+	}
+	return nil
+}